@@ -48,12 +48,20 @@ func RedactMongoLog(jsonStr string) (*orderedmap.OrderedMap[string, any], error)
 	if err != nil {
 		return nil, err
 	}
-	if redactIPs {
-		if remote, ok := entry.Get("attr"); ok {
-			if attrMap, ok := remote.(*orderedmap.OrderedMap[string, any]); ok {
-				if remoteVal, ok := attrMap.Get("remote"); ok {
-					if _, ok := remoteVal.(string); ok {
+	cVal, _ := entry.Get("c")
+	msgVal, _ := entry.Get("msg")
+	c, _ := cVal.(string)
+	msg, _ := msgVal.(string)
+	SetCurrentComponent(c)
+	SetCurrentNamespace("")
+	if remote, ok := entry.Get("attr"); ok {
+		if attrMap, ok := remote.(*orderedmap.OrderedMap[string, any]); ok {
+			if remoteVal, ok := attrMap.Get("remote"); ok {
+				if _, ok := remoteVal.(string); ok {
+					reportIPDetected("remote")
+					if redactIPs {
 						attrMap.Set("remote", "255.255.255.255:65535")
+						reportRedactedValue([]string{"remote"}, nil)
 					}
 				}
 			}
@@ -69,20 +77,14 @@ func RedactMongoLog(jsonStr string) (*orderedmap.OrderedMap[string, any], error)
 		return entry, nil
 	}
 
-	cVal, _ := entry.Get("c")
-	msgVal, _ := entry.Get("msg")
-	c, _ := cVal.(string)
-	msg, _ := msgVal.(string)
+	if ns, ok := attr.Get("ns"); ok {
+		nsStr, _ := ns.(string)
+		SetCurrentNamespace(nsStr)
+	}
 	if c == "COMMAND" || c == "QUERY" || c == "WRITE" || msg == "Slow query" {
-		shouldEagerRedact := false
-		for _, path := range eagerRedactionPaths {
-			s, _ := attr.Get("ns")
-			ns, _ := s.(string)
-			if strings.HasPrefix(ns, path) {
-				shouldEagerRedact = true
-				break
-			}
-		}
+		ns := entryNamespace(attr)
+		SetCurrentNamespace(ns)
+		shouldEagerRedact := isEagerRedactionNamespace(ns)
 		originatingCommand, ocOk := attr.Get("originatingCommand")
 		if ocOk {
 			if ocMap, ok := originatingCommand.(*orderedmap.OrderedMap[string, any]); ok {
@@ -137,6 +139,40 @@ func RedactMongoLog(jsonStr string) (*orderedmap.OrderedMap[string, any], error)
 	return entry, nil
 }
 
+// entryNamespace returns the namespace a log entry's command operates on. Write commands are
+// logged against '<db>.$cmd', so the collection is taken from the command itself in that case.
+func entryNamespace(attr *orderedmap.OrderedMap[string, any]) string {
+	s, _ := attr.Get("ns")
+	ns, _ := s.(string)
+	db, coll, found := strings.Cut(ns, ".")
+	if !found || coll != "$cmd" {
+		return ns
+	}
+	command, _ := attr.Get("command")
+	cmdMap, ok := command.(*orderedmap.OrderedMap[string, any])
+	if !ok {
+		return ns
+	}
+	for _, field := range []string{"insert", "update", "delete", "findAndModify"} {
+		if value, ok := cmdMap.Get(field); ok {
+			if collName, ok := value.(string); ok {
+				return db + "." + collName
+			}
+		}
+	}
+	return ns
+}
+
+// isEagerRedactionNamespace reports whether the field names of commands on ns are redacted too.
+func isEagerRedactionNamespace(ns string) bool {
+	for _, path := range eagerRedactionPaths {
+		if MatchesNamespace(path, ns) {
+			return true
+		}
+	}
+	return false
+}
+
 func redactNamespace(cmd *orderedmap.OrderedMap[string, any]) {
 	searchedFields := []string{"ns", "aggregate", "insert", "find", "update", "collection", "delete", "$db", "count", "findAndModify", "findOneAndDelete", "replace", "findOneAndReplace", "findOneAndUpdate", "getIndexes", "countDocuments"}
 	for _, field := range searchedFields {
@@ -173,9 +209,11 @@ func redactCommand(cmd *orderedmap.OrderedMap[string, any], shouldEagerRedact bo
 			cmd.Set("update", redactQueryValues(updateMap, shouldEagerRedact, false, nil, []string{}))
 		}
 	}
-	if updates, ok := cmd.Get("updates"); ok {
-		if updatesArr, ok := updates.([]any); ok {
-			cmd.Set("updates", redactArrayValues(updatesArr, shouldEagerRedact, false, false, []string{}))
+	for _, field := range []string{"updates", "deletes"} {
+		if statements, ok := cmd.Get(field); ok {
+			if statementsArr, ok := statements.([]any); ok {
+				cmd.Set(field, redactWriteStatements(statementsArr, shouldEagerRedact))
+			}
 		}
 	}
 	if update, ok := cmd.Get("q"); ok {
@@ -197,14 +235,52 @@ func redactCommand(cmd *orderedmap.OrderedMap[string, any], shouldEagerRedact bo
 	}
 	if pipeline, ok := cmd.Get("pipeline"); ok {
 		if pipelineArr, ok := pipeline.([]any); ok {
-			newPipeline := make([]any, len(pipelineArr))
-			for i, stage := range pipelineArr {
-				inSearchStage := isInSearchStage(stage)
-				newPipeline[i] = redactPipelineStage(stage, shouldEagerRedact, []string{}, inSearchStage)
+			cmd.Set("pipeline", redactPipeline(pipelineArr, shouldEagerRedact))
+		}
+	}
+}
+
+// redactWriteStatements redacts the statements of an update or delete command. The q and u fields of
+// each statement address the documents of the collection, so they're redacted from the document root
+// for field rules to match.
+func redactWriteStatements(statements []any, shouldEagerRedact bool) []any {
+	for i, statement := range statements {
+		statementMap, ok := statement.(*orderedmap.OrderedMap[string, any])
+		if !ok {
+			continue
+		}
+		redacted := orderedmap.NewOrderedMap[string, any]()
+		for el := statementMap.Front(); el != nil; el = el.Next() {
+			switch value := el.Value.(type) {
+			case *orderedmap.OrderedMap[string, any]:
+				if el.Key == "q" || el.Key == "u" {
+					redacted.Set(el.Key, redactQueryValues(value, shouldEagerRedact, false, nil, []string{}))
+					continue
+				}
+			case []any:
+				if el.Key == "u" {
+					redacted.Set(el.Key, redactPipeline(value, shouldEagerRedact))
+					continue
+				}
+			}
+			field := orderedmap.NewOrderedMap[string, any]()
+			field.Set(el.Key, el.Value)
+			for redactedEl := redactQueryValues(field, shouldEagerRedact, false, nil, []string{}).Front(); redactedEl != nil; redactedEl = redactedEl.Next() {
+				redacted.Set(redactedEl.Key, redactedEl.Value)
 			}
-			cmd.Set("pipeline", newPipeline)
 		}
+		statements[i] = redacted
 	}
+	return statements
+}
+
+func redactPipeline(pipeline []any, redactFieldNames bool) []any {
+	newPipeline := make([]any, len(pipeline))
+	for i, stage := range pipeline {
+		inSearchStage := isInSearchStage(stage)
+		newPipeline[i] = redactPipelineStage(stage, redactFieldNames, []string{}, inSearchStage)
+	}
+	return newPipeline
 }
 
 func redactFieldNamesFromPlanSummary(planSummary string) string {
@@ -324,6 +400,13 @@ func redactPipelineStage(stage interface{}, redactFieldNames bool, keyPath []str
 			redactedKey := k
 			newKeyPath := append(keyPath, k)
 			opMeta, isOp := getOp(newKeyPath, inSearchStage)
+			if !isOp && isUnknownOperator(k) {
+				recordUnknownOperator(k)
+				if strictOperators {
+					newMap.Set(k, redactedString)
+					continue
+				}
+			}
 			if redactFieldNames && (!isOp || (isOp && opMeta == nil)) {
 				redactedKey = HashName(k)
 			}
@@ -541,6 +624,13 @@ func redactQueryValues(obj *orderedmap.OrderedMap[string, any], redactFieldNames
 		} else {
 			coreOp, isOp = CoreOperators.Get(k)
 		}
+		if !isOp && isUnknownOperator(k) {
+			recordUnknownOperator(k)
+			if strictOperators {
+				newObj.Set(k, redactedString)
+				continue
+			}
+		}
 		if redactFieldNames {
 			if !isOp {
 				redactedKey = HashName(k)
@@ -612,7 +702,11 @@ func redactArrayValuesWithKey(parentKey string, arr []any, redactFieldNames bool
 						arr[i] = item
 					}
 				} else {
-					arr[i] = redactScalarValue([]string{parentKey}, item, isSearchStage, isSelectivelyRedactable)
+					scalarKeyPath := []string{parentKey}
+					if _, hasRule := fieldRuleFor(keyPath); hasRule {
+						scalarKeyPath = keyPath
+					}
+					arr[i] = redactScalarValue(scalarKeyPath, item, isSearchStage, isSelectivelyRedactable)
 				}
 			}
 		}
@@ -648,6 +742,16 @@ func reMatchesAnyKeyInPath(keyPath *[]string, pattern *regexp.Regexp) bool {
 }
 
 func redactScalarValue(keyPath []string, v interface{}, isSearchStage bool, isSelectivelyRedactable bool) interface{} {
+	if rule, ok := fieldRuleFor(keyPath); ok {
+		switch rule.Action {
+		case FieldActionKeep:
+			return v
+		case FieldActionHash:
+			return reportRedactedValue(keyPath, HashValue(v))
+		case FieldActionRedact:
+			isSelectivelyRedactable = true
+		}
+	}
 	parentKey := ""
 	grandParentKey := ""
 
@@ -678,33 +782,41 @@ func redactScalarValue(keyPath []string, v interface{}, isSearchStage bool, isSe
 	parentKey = keyPath[len(keyPath)-1]
 	switch parentKey {
 	case "$date":
-		return redactString(v.(string), RedactedISODate)
+		return reportRedactedValue(keyPath, redactString(v.(string), RedactedISODate))
 	case "$oid":
-		return redactString(v.(string), RedactedObjectId)
+		return reportRedactedValue(keyPath, redactString(v.(string), RedactedObjectId))
+	case "$uuid":
+		return reportRedactedValue(keyPath, redactString(v.(string), RedactedUUIDString))
+	case "options":
+		// The flags of a regular expression tell nothing about the data it matches.
+		if grandParentKey == "$regularExpression" {
+			return v
+		}
 	case "base64":
 		if grandParentKey == "$binary" {
-			return redactString(v.(string), RedactedUUID)
+			return reportRedactedValue(keyPath, redactString(v.(string), RedactedUUID))
 		}
 	}
 	switch v.(type) {
 	case string:
 		str := v.(string)
 		if IsEmail(str) {
-			return redactString(v.(string), "redacted@redacted.com")
+			reportEmailDetected(keyPath)
+			return reportRedactedValue(keyPath, redactString(v.(string), "redacted@redacted.com"))
 		}
-		return redactString(v.(string), redactedString)
+		return reportRedactedValue(keyPath, redactString(v.(string), redactedString))
 	case float64, int, int64, json.Number:
 		if redactNumbers {
-			return RedactedNumber
+			return reportRedactedValue(keyPath, RedactedNumber)
 		}
 		return v
 	case bool:
 		if redactBooleans {
-			return RedactedBoolean
+			return reportRedactedValue(keyPath, RedactedBoolean)
 		}
 		return v
 	default:
-		return redactedString
+		return reportRedactedValue(keyPath, redactedString)
 	}
 }
 
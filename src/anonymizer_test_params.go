@@ -551,7 +551,201 @@ func AnonymizerTestParams() []RedactTestCase {
 				"command.u.$unset.timestamp":    RedactedString,
 			},
 		},
+		{
+			Name:      "Simple find with a keep field rule",
+			InputFile: "simple_find.json",
+			Options: func() {
+				setOptionsRedactedStrings()
+				SetFieldRules(mustParseFieldRules("my_db.my_coll:foo=keep"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.filter.foo": "simple string",
+				"command.filter.bar": RedactedString,
+			},
+		},
+		{
+			Name:      "Simple find with a field rule for another namespace",
+			InputFile: "simple_find.json",
+			Options: func() {
+				setOptionsRedactedStrings()
+				SetFieldRules(mustParseFieldRules("my_db.my_coll_archive:foo=keep", "my_d:foo=keep"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.filter.foo": RedactedString,
+				"command.filter.bar": RedactedString,
+			},
+		},
+		{
+			Name:      "Simple find with a hash field rule",
+			InputFile: "simple_find.json",
+			Options: func() {
+				setOptionsRedactedStrings()
+				SetFieldRules(mustParseFieldRules("my_db.*:bar=hash"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.filter.foo": RedactedString,
+				"command.filter.bar": HashValue("another simple string"),
+			},
+		},
+		{
+			Name:      "Simple find with a redact field rule and redacted fields regexp",
+			InputFile: "simple_find.json",
+			Options: func() {
+				setOptionsRedactedStrings()
+				SetRedactedFieldsRegexp("^foo$")
+				SetFieldRules(mustParseFieldRules("my_db.my_coll:bar=redact"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.filter.foo": RedactedString,
+				"command.filter.bar": RedactedString,
+			},
+		},
+		{
+			Name:      "Update with nested logical query and exact field rules",
+			InputFile: "update_with_nested_logical_query.json",
+			Options: func() {
+				setOptionsRedactedAll()
+				SetFieldRules(mustParseFieldRules("my_db:cAt=keep", "my_db.my_coll:active=keep"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.query.$and.0.name":                 RedactedString,
+				"command.query.$and.0.active.$ne":           true,
+				"command.query.$and.1.$or.0.cAt.$lte.$date": "2025-05-30T09:38:12.155Z",
+				"command.query.$and.1.$or.1.uAt.$lte.$date": RedactedISODate,
+			},
+		},
+		{
+			Name:      "Inserts with globbed field rules",
+			InputFile: "inserts.json",
+			Options: func() {
+				setOptionsRedactedAll()
+				SetFieldRules(mustParseFieldRules("*.*:emb_doc_arr.**=keep", "my_db.my_coll:emb_doc_arr.foo=redact", "my_db.my_coll:val_arr=keep"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.documents.0.foo":                           RedactedString,
+				"command.documents.0.val_arr.0":                     "a",
+				"command.documents.0.emb_doc_arr.0.foo":             RedactedString,
+				"command.documents.0.emb_doc_arr.0.bar":             true,
+				"command.documents.0.emb_doc_arr.0.timestamp.$date": "2025-05-30T09:38:14.390Z",
+			},
+		},
+		{
+			Name:      "Aggregation with a keep field rule inside a pipeline",
+			InputFile: "simple_aggregation.json",
+			Options: func() {
+				setOptionsRedactedStrings()
+				SetFieldRules(mustParseFieldRules("my_db.my_coll:status=keep"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.pipeline.0.$match.status":              "ACTIVE",
+				"command.pipeline.0.$match.createdAt.$lt.$date": RedactedISODate,
+			},
+		},
+		{
+			Name:      "Updates with a keep field rule",
+			InputFile: "updates.json",
+			Options: func() {
+				setOptionsRedactedAll()
+				SetFieldRules(mustParseFieldRules("my_db.my_coll:foo=keep", "my_db.my_coll:_id=keep"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.updates.0.q._id.$oid":       "669f65fb548091aa955917c8",
+				"command.updates.0.u.$set.foo":       "some string",
+				"command.updates.0.u.$set.bar":       RedactedBoolean,
+				"command.updates.0.u.$set.timestamp": RedactedNumber,
+			},
+		},
+		{
+			Name:      "Deletes",
+			InputFile: "deletes.json",
+			Options:   setOptionsRedactedStrings,
+			ExpectedPaths: map[string]interface{}{
+				"command.deletes.0.q.status": RedactedString,
+				"command.deletes.0.q.owner":  RedactedString,
+				"command.deletes.0.limit":    float64(0),
+			},
+		},
+		{
+			Name:      "Deletes with a keep field rule",
+			InputFile: "deletes.json",
+			Options: func() {
+				setOptionsRedactedStrings()
+				SetFieldRules(mustParseFieldRules("my_db.my_coll:status=keep"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.deletes.0.q.status": "ARCHIVED",
+				"command.deletes.0.q.owner":  RedactedString,
+			},
+		},
+		{
+			Name:      "Deletes with eager redaction",
+			InputFile: "deletes.json",
+			Options:   setOptionsRedactedStringsWithEagerRedaction,
+			ExpectedPaths: map[string]interface{}{
+				fmt.Sprintf("command.deletes.0.q.%s", HashName("status")): RedactedString,
+			},
+		},
+		{
+			Name:      "FindAndModify with a keep field rule",
+			InputFile: "find_and_modify.json",
+			Options: func() {
+				setOptionsRedactedStrings()
+				SetFieldRules(mustParseFieldRules("my_db.my_coll:status=keep"))
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.update.$set.status":            "ACTIVE",
+				"command.update.$addToSet.tags.$each.0": RedactedString,
+			},
+		},
+		{
+			Name:      "Aggregation with unknown operators",
+			InputFile: "unknown_operators.json",
+			Options:   setOptionsRedactedStrings,
+			ExpectedPaths: map[string]interface{}{
+				"command.pipeline.0.$match.status":                    RedactedString,
+				"command.pipeline.0.$match.name.$fuzzyEq":             RedactedString,
+				"command.pipeline.1.$newStageFromServer9.targetField": RedactedString,
+				"command.pipeline.1.$newStageFromServer9.secret":      RedactedString,
+				"command.pipeline.2.$group.n.$sum":                    float64(1),
+			},
+		},
+		{
+			Name:      "Aggregation with unknown operators in strict mode",
+			InputFile: "unknown_operators.json",
+			Options: func() {
+				setOptionsRedactedStrings()
+				SetStrictOperators(true)
+			},
+			ExpectedPaths: map[string]interface{}{
+				"command.pipeline.0.$match.status":        RedactedString,
+				"command.pipeline.0.$match.name.$fuzzyEq": RedactedString,
+				"command.pipeline.1.$newStageFromServer9": RedactedString,
+				"command.pipeline.2.$group.n.$sum":        float64(1),
+			},
+		},
+		{
+			Name:      "Aggregation with unknown operators in strict mode and eager redaction",
+			InputFile: "unknown_operators.json",
+			Options: func() {
+				setOptionsRedactedStringsWithEagerRedaction()
+				SetStrictOperators(true)
+			},
+			ExpectedPaths: map[string]interface{}{
+				fmt.Sprintf("command.pipeline.0.$match.%s", HashName("status")):        RedactedString,
+				fmt.Sprintf("command.pipeline.0.$match.%s.$fuzzyEq", HashName("name")): RedactedString,
+				"command.pipeline.1.$newStageFromServer9":                              RedactedString,
+				fmt.Sprintf("command.pipeline.2.$group.%s.$sum", HashName("n")):        float64(1),
+			},
+		},
+	}
+}
+
+func mustParseFieldRules(rules ...string) []FieldRule {
+	parsed, err := ParseFieldRules(rules)
+	if err != nil {
+		panic(err)
 	}
+	return parsed
 }
 
 // These are just preset functions to set the options for the anonymizer.
@@ -565,6 +759,8 @@ func setOptionsRedactedStrings() {
 	SetEagerRedactionPaths([]string{})
 	SetRedactNamespaces(false)
 	SetRedactedFieldsRegexp("")
+	SetFieldRules(nil)
+	SetStrictOperators(false)
 }
 
 func setOptionsRedactedStringsAndNamespaces() {
@@ -575,6 +771,8 @@ func setOptionsRedactedStringsAndNamespaces() {
 	SetEagerRedactionPaths([]string{})
 	SetRedactNamespaces(true)
 	SetRedactedFieldsRegexp("")
+	SetFieldRules(nil)
+	SetStrictOperators(false)
 }
 
 func setOptionsRedactedStringsWithEagerRedaction() {
@@ -587,6 +785,8 @@ func setOptionsRedactedStringsWithEagerRedaction() {
 	})
 	SetRedactNamespaces(false)
 	SetRedactedFieldsRegexp("")
+	SetFieldRules(nil)
+	SetStrictOperators(false)
 }
 
 func setOptionsRedactedAllWithOverride() {
@@ -597,6 +797,8 @@ func setOptionsRedactedAllWithOverride() {
 	SetEagerRedactionPaths([]string{})
 	SetRedactNamespaces(false)
 	SetRedactedFieldsRegexp("")
+	SetFieldRules(nil)
+	SetStrictOperators(false)
 }
 
 func setOptionsRedactedAll() {
@@ -607,6 +809,8 @@ func setOptionsRedactedAll() {
 	SetEagerRedactionPaths([]string{})
 	SetRedactNamespaces(false)
 	SetRedactedFieldsRegexp("")
+	SetFieldRules(nil)
+	SetStrictOperators(false)
 }
 
 func setOptionsRedactedIPs() {
@@ -617,4 +821,6 @@ func setOptionsRedactedIPs() {
 	SetEagerRedactionPaths([]string{})
 	SetRedactNamespaces(false)
 	SetRedactedFieldsRegexp("")
+	SetFieldRules(nil)
+	SetStrictOperators(false)
 }
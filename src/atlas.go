@@ -1,6 +1,7 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,7 +9,10 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/mongodb-forks/digest"
 	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
@@ -19,6 +23,27 @@ const atlasAPIBaseURL = "https://cloud.mongodb.com"
 type AtlasClient struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// Concurrency is the number of logs downloaded at the same time.
+	Concurrency int
+	// MaxRetries is the number of times a log download is retried after a network error, or a
+	// 429 or 5xx status.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	MaxRetryDelay  time.Duration
+	// AllowPartial keeps the logs that could be downloaded when others couldn't.
+	AllowPartial bool
+	// ChunkSize is the longest range of logs downloaded at once; longer ranges are split.
+	ChunkSize time.Duration
+	// LogCollectionPollInterval is how often the status of an Ops Manager log collection job is
+	// checked.
+	LogCollectionPollInterval time.Duration
+	// ClientID and ClientSecret are the credentials of an Atlas service account. When they're set,
+	// requests are authenticated with its access tokens instead of an API key.
+	ClientID     string
+	ClientSecret string
+
+	tokensOnce sync.Once
+	tokens     *serviceAccountTokens
 }
 
 func NewAtlasClient(httpClient *http.Client) *AtlasClient {
@@ -27,8 +52,47 @@ func NewAtlasClient(httpClient *http.Client) *AtlasClient {
 		httpClient = &http.Client{}
 	}
 	return &AtlasClient{
-		BaseURL:    atlasAPIBaseURL,
-		HTTPClient: httpClient,
+		BaseURL:        atlasAPIBaseURL,
+		HTTPClient:     httpClient,
+		Concurrency:    defaultDownloadConcurrency,
+		MaxRetries:     defaultMaxRetries,
+		RetryBaseDelay: defaultRetryBaseDelay,
+		MaxRetryDelay:  defaultMaxRetryDelay,
+	}
+}
+
+// authenticatedClient returns an HTTP client authenticating its requests with the access tokens of
+// the service account if c.ClientID is set, or with the API key, using Digest authentication,
+// otherwise.
+func (c *AtlasClient) authenticatedClient(publicKey, privateKey string) *http.Client {
+	var baseTransport http.RoundTripper
+	if c.HTTPClient != http.DefaultClient {
+		baseTransport = c.HTTPClient.Transport
+	}
+	if baseTransport == nil {
+		baseTransport = http.DefaultTransport
+	}
+	if c.ClientID != "" {
+		c.tokensOnce.Do(func() {
+			c.tokens = &serviceAccountTokens{
+				client:       &http.Client{Transport: baseTransport, Timeout: c.HTTPClient.Timeout},
+				tokenURL:     c.BaseURL + atlasTokenPath,
+				clientID:     c.ClientID,
+				clientSecret: c.ClientSecret,
+			}
+		})
+		return &http.Client{
+			Transport: &bearerTransport{tokens: c.tokens, transport: baseTransport},
+			Timeout:   c.HTTPClient.Timeout,
+		}
+	}
+	return &http.Client{
+		Transport: &digest.Transport{
+			Username:  publicKey,
+			Password:  privateKey,
+			Transport: baseTransport,
+		},
+		Timeout: c.HTTPClient.Timeout,
 	}
 }
 
@@ -42,30 +106,7 @@ func (c *AtlasClient) getAtlasClusterInfo(ctx context.Context, publicKey, privat
 
 	req.Header.Set("Accept", "application/vnd.atlas.2025-03-12+json")
 
-	// Use Digest authentication
-	digestTransport := &digest.Transport{
-		Username: publicKey,
-		Password: privateKey,
-	}
-	client := c.HTTPClient
-	if client == http.DefaultClient {
-		client = &http.Client{
-			Transport: digestTransport,
-			Timeout:   c.HTTPClient.Timeout,
-		}
-	} else {
-		baseTransport := c.HTTPClient.Transport
-		if baseTransport == nil {
-			baseTransport = http.DefaultTransport
-		}
-		digestTransport.Transport = baseTransport
-		client = &http.Client{
-			Transport: digestTransport,
-			Timeout:   c.HTTPClient.Timeout,
-		}
-	}
-
-	resp, err := client.Do(req)
+	resp, err := c.authenticatedClient(publicKey, privateKey).Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -89,36 +130,111 @@ func (c *AtlasClient) getAtlasClusterInfo(ctx context.Context, publicKey, privat
 	return &info, nil
 }
 
-func (c *AtlasClient) DownloadClusterLogs(ctx context.Context, publicKey, privateKey, projectID, clusterName string, startDate int, endDate int) ([]string, error) {
-	fmt.Fprintln(os.Stdout, "Downloading Atlas cluster logs...")
-	atlasClusterInfo, error := c.getAtlasClusterInfo(ctx, publicKey, privateKey, projectID, clusterName)
-	if error != nil {
-		return nil, fmt.Errorf("failed to get cluster info: %w", error)
+// AtlasLogType is a log that Atlas collects on the hosts of a cluster, named after its download.
+type AtlasLogType string
+
+const (
+	AtlasLogMongod      AtlasLogType = "mongodb"
+	AtlasLogMongos      AtlasLogType = "mongos"
+	AtlasLogMongodAudit AtlasLogType = "mongodb-audit-log"
+	AtlasLogMongosAudit AtlasLogType = "mongos-audit-log"
+)
+
+var atlasLogTypes = []AtlasLogType{AtlasLogMongod, AtlasLogMongos, AtlasLogMongodAudit, AtlasLogMongosAudit}
+
+// ParseAtlasLogTypes returns the log types for their names, without duplicates.
+func ParseAtlasLogTypes(names []string) ([]AtlasLogType, error) {
+	var logTypes []AtlasLogType
+	for _, name := range names {
+		logType := AtlasLogType(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".gz"))
+		if !slices.Contains(atlasLogTypes, logType) {
+			return nil, fmt.Errorf("invalid Atlas log type %q (expected 'mongodb', 'mongos', 'mongodb-audit-log' or 'mongos-audit-log')", name)
+		}
+		if !slices.Contains(logTypes, logType) {
+			logTypes = append(logTypes, logType)
+		}
 	}
-	hosts, err := GetHostsFromConnectionString(atlasClusterInfo.ConnectionStrings.Standard)
+	return logTypes, nil
+}
+
+// EntryFormat returns the format of the entries of the log.
+func (t AtlasLogType) EntryFormat() EntryFormat {
+	if t == AtlasLogMongodAudit || t == AtlasLogMongosAudit {
+		return EntryFormatAudit
+	}
+	return EntryFormatLog
+}
+
+// isMongos returns whether the log is written by mongos routers.
+func (t AtlasLogType) isMongos() bool {
+	return t == AtlasLogMongos || t == AtlasLogMongosAudit
+}
+
+// AtlasLogFile is a log downloaded from a host of an Atlas cluster.
+type AtlasLogFile struct {
+	Cluster string
+	Host    string
+	// Role is the role of the process that wrote the log.
+	Role    HostRole
+	LogType AtlasLogType
+	// Path is the temporary file the log was downloaded to, or the name it's streamed under (see
+	// NewLogStreams).
+	Path string
+}
+
+// DownloadClusterLogs downloads each log type from each host of the clusters selected by filter, to
+// temporary files. The logs of every cluster are downloaded together; see downloadLogs for how
+// failures are handled.
+func (c *AtlasClient) DownloadClusterLogs(ctx context.Context, publicKey, privateKey, projectID string, clusterNames []string, filter HostFilter, logTypes []AtlasLogType, startDate int, endDate int) ([]AtlasLogFile, error) {
+	jobs, err := c.clusterLogJobs(ctx, publicKey, privateKey, projectID, clusterNames, filter, logTypes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get hosts from connection string: %w", err)
+		return nil, err
 	}
-	var logFiles []string
-	for _, host := range hosts {
-		fmt.Fprintf(os.Stdout, "Downloading logs for host %s...\n", host)
-		logFile, err := c.downloadClusterLogsForHost(ctx, publicKey, privateKey, projectID, host, startDate, endDate)
+	fmt.Fprintln(os.Stdout, "Downloading Atlas cluster logs...")
+	return c.downloadLogs(ctx, jobs, func(ctx context.Context, job downloadJob) (string, error) {
+		fmt.Fprintf(os.Stdout, "Downloading %s logs for host %s...\n", job.logType, job.host)
+		return c.downloadClusterLogsForHost(ctx, publicKey, privateKey, projectID, job.host, job.logType, startDate, endDate)
+	})
+}
+
+// ListClusterLogs returns each log type of each host of the clusters selected by filter, without
+// downloading them, to stream them with NewLogStreams. Their Path is the name they're streamed
+// under.
+func (c *AtlasClient) ListClusterLogs(ctx context.Context, publicKey, privateKey, projectID string, clusterNames []string, filter HostFilter, logTypes []AtlasLogType) ([]AtlasLogFile, error) {
+	jobs, err := c.clusterLogJobs(ctx, publicKey, privateKey, projectID, clusterNames, filter, logTypes)
+	if err != nil {
+		return nil, err
+	}
+	logFiles := make([]AtlasLogFile, len(jobs))
+	for i, job := range jobs {
+		logFiles[i] = AtlasLogFile{Cluster: job.cluster, Host: job.host, Role: job.role, LogType: job.logType, Path: fmt.Sprintf("%s.%s", job.host, job.logType)}
+	}
+	return logFiles, nil
+}
+
+// clusterLogJobs returns a job for each log type of each host of the clusters selected by filter.
+func (c *AtlasClient) clusterLogJobs(ctx context.Context, publicKey, privateKey, projectID string, clusterNames []string, filter HostFilter, logTypes []AtlasLogType) ([]downloadJob, error) {
+	var jobs []downloadJob
+	for _, clusterName := range clusterNames {
+		processes, err := c.resolveClusterProcesses(ctx, publicKey, privateKey, projectID, clusterName, filter)
 		if err != nil {
-			// If one host fails, we should clean up what we've downloaded so far
-			_ = c.DeleteClusterLogs(ctx, logFiles)
-			return nil, fmt.Errorf("failed to download logs for host %s: %w", host, err)
+			return nil, fmt.Errorf("cluster %s: %w", clusterName, err)
+		}
+		for _, host := range processHosts(processes) {
+			for _, logType := range logTypes {
+				jobs = append(jobs, downloadJob{cluster: clusterName, host: host, role: hostRole(processes, host, logType), logType: logType})
+			}
 		}
-		logFiles = append(logFiles, logFile)
 	}
-	return logFiles, nil
+	return jobs, nil
 }
 
-func (c *AtlasClient) DeleteClusterLogs(ctx context.Context, logFiles []string) error {
+func (c *AtlasClient) DeleteClusterLogs(ctx context.Context, logFiles []AtlasLogFile) error {
 	var errs []string
 	for _, logFile := range logFiles {
-		if err := os.Remove(logFile); err != nil {
+		if err := os.Remove(logFile.Path); err != nil {
 			// Log the error and continue, since we want to try deleting all files
-			errStr := fmt.Sprintf("failed to delete log file %s: %v", logFile, err)
+			errStr := fmt.Sprintf("failed to delete log file %s: %v", logFile.Path, err)
 			fmt.Fprintln(os.Stderr, errStr)
 			errs = append(errs, errStr)
 		}
@@ -130,64 +246,78 @@ func (c *AtlasClient) DeleteClusterLogs(ctx context.Context, logFiles []string)
 	return nil
 }
 
-func (c *AtlasClient) downloadClusterLogsForHost(ctx context.Context, publicKey, privateKey, projectID, host string, startDate int, endDate int) (string, error) {
-	url := fmt.Sprintf(
-		"%s/api/atlas/v2/groups/%s/clusters/%s/logs/mongodb.gz?endDate=%d&startDate=%d",
-		c.BaseURL, projectID, host, endDate, startDate,
-	)
+// downloadClusterLogsForHost downloads a log of a host to a temporary file. A range longer than
+// c.ChunkSize is downloaded in chunks, which are concatenated without the lines they share (see
+// chunkMerger).
+func (c *AtlasClient) downloadClusterLogsForHost(ctx context.Context, publicKey, privateKey, projectID, host string, logType AtlasLogType, startDate int, endDate int) (string, error) {
+	header := atlasLogHeader()
+	client := c.authenticatedClient(publicKey, privateKey)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	tmpFile, err := os.CreateTemp("", fmt.Sprintf("%s_%s_%d_%d_*.log.gz", logType, host, startDate, endDate))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create temp file: %w", err)
 	}
-	req.Header.Set("Accept", "application/vnd.atlas.2023-02-01+gzip")
-	req.Header.Set("Content-Type", "application/gzip")
+	defer tmpFile.Close()
 
-	digestTransport := &digest.Transport{
-		Username: publicKey,
-		Password: privateKey,
-	}
-	client := c.HTTPClient
-	if client == http.DefaultClient {
-		client = &http.Client{
-			Transport: digestTransport,
-			Timeout:   c.HTTPClient.Timeout,
-		}
+	chunks := logChunks(startDate, endDate, c.chunkSize())
+	if len(chunks) == 1 {
+		err = c.downloadToFile(ctx, client, c.logURL(projectID, host, logType, chunks[0]), header, tmpFile)
 	} else {
-		baseTransport := c.HTTPClient.Transport
-		if baseTransport == nil {
-			baseTransport = http.DefaultTransport
+		gz := gzip.NewWriter(tmpFile)
+		merger := newChunkMerger(gz)
+		for i, chunk := range chunks {
+			fmt.Fprintf(os.Stdout, "Downloading %s logs for host %s (%d/%d)...\n", logType, host, i+1, len(chunks))
+			if err = c.downloadChunk(ctx, client, c.logURL(projectID, host, logType, chunk), header, merger); err != nil {
+				break
+			}
 		}
-		digestTransport.Transport = baseTransport
-		client = &http.Client{
-			Transport: digestTransport,
-			Timeout:   c.HTTPClient.Timeout,
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
 		}
 	}
-
-	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", err
 	}
+	return tmpFile.Name(), nil
+}
 
-	tmpFile, err := os.CreateTemp("", fmt.Sprintf("mongod_%s_%d_%d_*.log.gz", host, startDate, endDate))
+// downloadChunk streams a chunk of a log into merger, so that it's never stored on its own. A chunk
+// interrupted midway is resumed like in streamLog; it fails if it can't be, since its first lines
+// were already merged.
+func (c *AtlasClient) downloadChunk(ctx context.Context, client *http.Client, url string, header http.Header, merger *chunkMerger) error {
+	body, err := c.streamLog(ctx, client, url, header)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return err
 	}
-	defer tmpFile.Close()
-
-	_, err = io.Copy(tmpFile, resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to write log to temp file: %w", err)
+	defer body.Close()
+	if err := merger.add(body); err != nil {
+		return fmt.Errorf("failed to concatenate log chunks: %w", err)
 	}
+	return nil
+}
 
-	return tmpFile.Name(), nil
+// logURL returns the URL of a log of a host over a range.
+func (c *AtlasClient) logURL(projectID, host string, logType AtlasLogType, chunk timeRange) string {
+	return fmt.Sprintf(
+		"%s/api/atlas/v2/groups/%s/clusters/%s/logs/%s.gz?endDate=%d&startDate=%d",
+		c.BaseURL, projectID, host, logType, chunk.end, chunk.start,
+	)
+}
+
+func atlasLogHeader() http.Header {
+	header := http.Header{}
+	header.Set("Accept", "application/vnd.atlas.2023-02-01+gzip")
+	header.Set("Content-Type", "application/gzip")
+	return header
+}
+
+func (c *AtlasClient) chunkSize() time.Duration {
+	if c.ChunkSize <= 0 {
+		return defaultLogChunkSize
+	}
+	return c.ChunkSize
 }
 
 func GetHostsFromConnectionString(connectionString string) ([]string, error) {
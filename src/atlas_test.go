@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -133,6 +134,7 @@ func TestGetAtlasClusterInfo(t *testing.T) {
 // TestDownloadAndCleanupCycle tests the full flow of downloading and deleting logs.
 func TestDownloadAndCleanupCycle(t *testing.T) {
 	clusterInfoJSON, _ := os.ReadFile(filepath.Join("..", "test_fixtures", "cluster-info-response.json"))
+	processesJSON, _ := os.ReadFile(filepath.Join("..", "test_fixtures", "processes-response.json"))
 	dummyLogContent := "this is a fake log file"
 
 	// Use a ServeMux to handle different API endpoints
@@ -143,6 +145,9 @@ func TestDownloadAndCleanupCycle(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 		w.Write(clusterInfoJSON)
 	})
+	mux.HandleFunc("/api/atlas/v2/groups/project1/processes", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(processesJSON)
+	})
 
 	// Handler for log downloads. This will match any log download request.
 	mux.HandleFunc("/api/atlas/v2/groups/project1/clusters/", func(w http.ResponseWriter, r *http.Request) {
@@ -163,7 +168,7 @@ func TestDownloadAndCleanupCycle(t *testing.T) {
 	client.BaseURL = server.URL
 
 	// 1. Download logs
-	logFiles, err := client.DownloadClusterLogs(context.Background(), "pubKey", "privKey", "project1", "cluster1", 0, 1)
+	logFiles, err := client.DownloadClusterLogs(context.Background(), "pubKey", "privKey", "project1", []string{"cluster1"}, HostFilter{}, []AtlasLogType{AtlasLogMongod}, 0, 1)
 	if err != nil {
 		t.Fatalf("DownloadClusterLogs failed: %v", err)
 	}
@@ -176,17 +181,17 @@ func TestDownloadAndCleanupCycle(t *testing.T) {
 	// 2. Verify files were created and have content
 	for _, logFile := range logFiles {
 		// Ensure we clean up this file even if the test fails mid-way
-		defer os.Remove(logFile)
+		defer os.Remove(logFile.Path)
 
-		if _, err := os.Stat(logFile); os.IsNotExist(err) {
-			t.Errorf("Log file %s was not created", logFile)
+		if _, err := os.Stat(logFile.Path); os.IsNotExist(err) {
+			t.Errorf("Log file %s was not created", logFile.Path)
 		}
-		content, err := os.ReadFile(logFile)
+		content, err := os.ReadFile(logFile.Path)
 		if err != nil {
 			t.Errorf("Failed to read created log file %s: %v", logFile, err)
 		}
 		if string(content) != dummyLogContent {
-			t.Errorf("Log file content mismatch for %s", logFile)
+			t.Errorf("Log file content mismatch for %s", logFile.Path)
 		}
 	}
 
@@ -198,8 +203,82 @@ func TestDownloadAndCleanupCycle(t *testing.T) {
 
 	// 4. Verify files were deleted
 	for _, logFile := range logFiles {
-		if _, err := os.Stat(logFile); !os.IsNotExist(err) {
-			t.Errorf("Log file %s was not deleted", logFile)
+		if _, err := os.Stat(logFile.Path); !os.IsNotExist(err) {
+			t.Errorf("Log file %s was not deleted", logFile.Path)
+		}
+	}
+}
+
+// TestDownloadClusterLogs_LogTypes downloads several log types from each host, and checks a failed
+// download cleans up the logs downloaded before it.
+func TestDownloadClusterLogs_LogTypes(t *testing.T) {
+	clusterInfoJSON, _ := os.ReadFile(filepath.Join("..", "test_fixtures", "cluster-info-response.json"))
+	processesJSON, _ := os.ReadFile(filepath.Join("..", "test_fixtures", "processes-response.json"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/atlas/v2/groups/project1/clusters/cluster1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(clusterInfoJSON)
+	})
+	mux.HandleFunc("/api/atlas/v2/groups/project1/processes", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(processesJSON)
+	})
+	mux.HandleFunc("/api/atlas/v2/groups/project1/clusters/", func(w http.ResponseWriter, r *http.Request) {
+		logName := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if logName == "mongos.gz" {
+			http.Error(w, `{"detail":"No mongos on this host."}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(logName))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+
+	logFiles, err := client.DownloadClusterLogs(context.Background(), "pubKey", "privKey", "project1", []string{"cluster1"}, HostFilter{}, []AtlasLogType{AtlasLogMongod, AtlasLogMongodAudit}, 0, 1)
+	if err != nil {
+		t.Fatalf("DownloadClusterLogs failed: %v", err)
+	}
+	defer client.DeleteClusterLogs(context.Background(), logFiles)
+	if len(logFiles) != 6 {
+		t.Fatalf("Expected 2 log files for each of the 3 hosts, got %d", len(logFiles))
+	}
+	for i, logFile := range logFiles {
+		wantType := []AtlasLogType{AtlasLogMongod, AtlasLogMongodAudit}[i%2]
+		if logFile.LogType != wantType || logFile.Host != "mycluster-shard-00-0"+string(rune('0'+i/2))+".abcde.mongodb.net" {
+			t.Errorf("Unexpected log file %d: %+v", i, logFile)
+		}
+		content, err := os.ReadFile(logFile.Path)
+		if err != nil || string(content) != string(wantType)+".gz" {
+			t.Errorf("Expected %s to hold the %s log, got %q (%v)", logFile.Path, wantType, content, err)
+		}
+	}
+
+	// The mongos log of the first host fails after its mongod log was downloaded.
+	_, err = client.DownloadClusterLogs(context.Background(), "pubKey", "privKey", "project1", []string{"cluster1"}, HostFilter{}, []AtlasLogType{AtlasLogMongod, AtlasLogMongos}, 0, 1)
+	if err == nil || !strings.Contains(err.Error(), "failed to download mongos logs for host") {
+		t.Fatalf("Expected the mongos download to fail, got %v", err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(os.TempDir(), "mongodb_mycluster-shard-00-00.abcde.mongodb.net_0_1_*.log.gz"))
+	for _, leftover := range leftovers {
+		if !slices.ContainsFunc(logFiles, func(f AtlasLogFile) bool { return f.Path == leftover }) {
+			t.Errorf("Expected %s to be cleaned up after the failed download", leftover)
 		}
 	}
 }
+
+func TestParseAtlasLogTypes(t *testing.T) {
+	got, err := ParseAtlasLogTypes([]string{"mongodb", "Mongos.gz", "mongodb-audit-log", "mongodb"})
+	if err != nil {
+		t.Fatalf("ParseAtlasLogTypes returned an unexpected error: %v", err)
+	}
+	if want := []AtlasLogType{AtlasLogMongod, AtlasLogMongos, AtlasLogMongodAudit}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAtlasLogTypes() = %v, want %v", got, want)
+	}
+	if _, err := ParseAtlasLogTypes([]string{"mongosqld"}); err == nil {
+		t.Errorf("ParseAtlasLogTypes(mongosqld) error = nil, want an error")
+	}
+	if AtlasLogMongosAudit.EntryFormat() != EntryFormatAudit || AtlasLogMongos.EntryFormat() != EntryFormatLog {
+		t.Errorf("Expected audit logs to be redacted as audit events, and the others as logs")
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// outputTemplatePlaceholders are the placeholders of --outputTemplate, and what they're replaced
+// with for each log.
+var outputTemplatePlaceholders = []string{"project", "cluster", "host", "role", "logType", "start", "end"}
+
+var outputTemplatePlaceholderRegex = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// outputTemplateTimeLayout is the layout of the {start} and {end} placeholders, which fits in file
+// names.
+const outputTemplateTimeLayout = "20060102T150405Z"
+
+// AtlasOutputNames names the redacted output of each log downloaded from an Atlas cluster: after
+// Template if it's set, or '<OutputFile>.<host>.<log type>' otherwise, with the '.gz' of OutputFile
+// kept at the end.
+type AtlasOutputNames struct {
+	Template   string
+	OutputFile string
+	ProjectID  string
+	StartDate  int
+	EndDate    int
+}
+
+// ValidateOutputTemplate returns an error if template has placeholders other than
+// outputTemplatePlaceholders.
+func ValidateOutputTemplate(template string) error {
+	for _, match := range outputTemplatePlaceholderRegex.FindAllStringSubmatch(template, -1) {
+		if !slices.Contains(outputTemplatePlaceholders, match[1]) {
+			return fmt.Errorf("unknown placeholder %s in output template (expected {%s})", match[0], strings.Join(outputTemplatePlaceholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+// Path returns the path of the redacted output of file.
+func (n AtlasOutputNames) Path(file AtlasLogFile) string {
+	if n.Template == "" {
+		base, ext := n.OutputFile, ""
+		if strings.HasSuffix(strings.ToLower(base), ".gz") {
+			base, ext = base[:len(base)-len(".gz")], base[len(base)-len(".gz"):]
+		}
+		return fmt.Sprintf("%s.%s.%s%s", base, file.Host, file.LogType, ext)
+	}
+	role := string(file.Role)
+	if role == "" {
+		role = "unknown"
+	}
+	values := map[string]string{
+		"project": n.ProjectID,
+		"cluster": file.Cluster,
+		"host":    file.Host,
+		"role":    role,
+		"logType": string(file.LogType),
+		"start":   time.Unix(int64(n.StartDate), 0).UTC().Format(outputTemplateTimeLayout),
+		"end":     time.Unix(int64(n.EndDate), 0).UTC().Format(outputTemplateTimeLayout),
+	}
+	return outputTemplatePlaceholderRegex.ReplaceAllStringFunc(n.Template, func(match string) string {
+		return values[match[1:len(match)-1]]
+	})
+}
+
+// Paths returns the paths of the redacted outputs of files, in order. Logs named the same would
+// overwrite each other, so they're an error.
+func (n AtlasOutputNames) Paths(files []AtlasLogFile) ([]string, error) {
+	paths := make([]string, len(files))
+	seen := make(map[string]AtlasLogFile, len(files))
+	for i, file := range files {
+		paths[i] = n.Path(file)
+		if other, ok := seen[paths[i]]; ok {
+			return nil, fmt.Errorf("the %s log of %s and the %s log of %s would both be written to %s; use {host} and {logType} in the output template", other.LogType, other.Host, file.LogType, file.Host, paths[i])
+		}
+		seen[paths[i]] = file
+	}
+	return paths, nil
+}
+
+// CreateOutputFile creates the file at path, and its missing directories. The output is gzipped if
+// path ends in '.gz'; close flushes and closes it.
+func CreateOutputFile(path string) (w io.Writer, close func() error, err error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !strings.HasSuffix(strings.ToLower(path), ".gz") {
+		return f, f.Close, nil
+	}
+	gz := gzip.NewWriter(f)
+	return gz, func() error {
+		return errors.Join(gz.Close(), f.Close())
+	}, nil
+}
+
+// mergeSource is a log being merged, with its next redacted entry.
+type mergeSource struct {
+	file   AtlasLogFile
+	stream *logStream
+	next   func() (string, bool)
+	stop   func()
+	err    error
+	entry  *orderedmap.OrderedMap[string, any]
+	time   time.Time
+}
+
+// MergeAtlasLogs redacts the logs and writes their entries to outWriter as a single log,
+// interleaved by timestamp. Each entry gets the host that logged it, after its timestamp (see
+// redactHostName). The logs are each in chronological order, so one entry of each is read at a
+// time.
+func MergeAtlasLogs(fileReader FileReader, files []AtlasLogFile, outWriter io.Writer) error {
+	var sources []*mergeSource
+	defer func() {
+		for _, source := range sources {
+			// Closing the log first stops reading the rest of it.
+			source.stream.Close()
+			source.stop()
+		}
+	}()
+	for _, file := range files {
+		s, err := openLogFileInput(fileReader, file.Path, nil)
+		if err != nil {
+			return fmt.Errorf("failed to open %s log of %s: %w", file.LogType, file.Host, err)
+		}
+		source := &mergeSource{file: file, stream: s}
+		var lines iter.Seq[string] = func(yield func(string) bool) {
+			defer s.Close()
+			source.err = forEachLogEntry(s, func(line string, lineNumber int) {
+				yield(line)
+			})
+		}
+		source.next, source.stop = iter.Pull(lines)
+		sources = append(sources, source)
+		if err := source.advance(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		var earliest *mergeSource
+		for _, source := range sources {
+			if source.entry != nil && (earliest == nil || source.time.Before(earliest.time)) {
+				earliest = source
+			}
+		}
+		if earliest == nil {
+			return nil
+		}
+		reportLine(writeRedactedEntry(withHost(earliest.entry, redactHostName(earliest.file.Host)), outWriter) != nil)
+		if err := earliest.advance(); err != nil {
+			return err
+		}
+	}
+}
+
+// advance redacts the next entry of the log. Entries that can't be parsed are dropped, like when
+// redacting a single log.
+func (m *mergeSource) advance() error {
+	m.entry = nil
+	SetEntryFormat(m.file.LogType.EntryFormat())
+	for {
+		line, ok := m.next()
+		if !ok {
+			if m.err != nil {
+				return fmt.Errorf("failed to read %s log of %s: %w", m.file.LogType, m.file.Host, m.err)
+			}
+			return nil
+		}
+		redacted, err := redactEntry(line)
+		if err != nil {
+			reportLine(true)
+			continue
+		}
+		m.entry, m.time = redacted, entryTime(redacted)
+		return nil
+	}
+}
+
+// entryTime returns the timestamp of a log entry (t) or audit event (ts), or the zero time if it
+// has none.
+func entryTime(entry *orderedmap.OrderedMap[string, any]) time.Time {
+	for _, field := range []string{"t", "ts"} {
+		v, ok := entry.Get(field)
+		if !ok {
+			continue
+		}
+		tMap, ok := v.(*orderedmap.OrderedMap[string, any])
+		if !ok {
+			continue
+		}
+		date, _ := tMap.Get("$date")
+		if s, ok := date.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// withHost returns entry with a host field after its timestamp, or at the end if it has none.
+func withHost(entry *orderedmap.OrderedMap[string, any], host string) *orderedmap.OrderedMap[string, any] {
+	_, hasT := entry.Get("t")
+	_, hasTS := entry.Get("ts")
+	if !hasT && !hasTS {
+		entry.Set("host", host)
+		return entry
+	}
+	result := orderedmap.NewOrderedMap[string, any]()
+	for key, value := range entry.AllFromFront() {
+		if key == "host" {
+			continue
+		}
+		result.Set(key, value)
+		if key == "t" || key == "ts" {
+			result.Set("host", host)
+		}
+	}
+	return result
+}
+
+// redactHostName hashes the name of a host with --redactIPs, as a whole so that the hosts of a
+// cluster don't share the hash of their domain.
+func redactHostName(host string) string {
+	reportIPDetected("host")
+	if !redactIPs {
+		return host
+	}
+	reportRedactedValue([]string{"host"}, nil)
+	h := sha256.Sum256([]byte(host))
+	return fmt.Sprintf("%s_%x", redactedString, h[:8])
+}
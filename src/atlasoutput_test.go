@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAtlasOutputNames_Path(t *testing.T) {
+	file := AtlasLogFile{Cluster: "cluster0", Host: "cluster0-shard-00-00.abcde.mongodb.net", Role: HostRolePrimary, LogType: AtlasLogMongod}
+	tests := []struct {
+		name     string
+		names    AtlasOutputNames
+		file     AtlasLogFile
+		expected string
+	}{
+		{
+			name:     "output file",
+			names:    AtlasOutputNames{OutputFile: "out.log"},
+			file:     file,
+			expected: "out.log.cluster0-shard-00-00.abcde.mongodb.net.mongodb",
+		},
+		{
+			name:     "gzipped output file",
+			names:    AtlasOutputNames{OutputFile: "out.log.gz"},
+			file:     file,
+			expected: "out.log.cluster0-shard-00-00.abcde.mongodb.net.mongodb.gz",
+		},
+		{
+			name:     "template",
+			names:    AtlasOutputNames{Template: "{cluster}/{host}/{logType}-{start}-{end}.log.gz", StartDate: 1760000000, EndDate: 1760086400},
+			file:     file,
+			expected: "cluster0/cluster0-shard-00-00.abcde.mongodb.net/mongodb-20251009T085320Z-20251010T085320Z.log.gz",
+		},
+		{
+			name:     "template with roles",
+			names:    AtlasOutputNames{Template: "{project}/{role}-{host}.log", ProjectID: "project1"},
+			file:     AtlasLogFile{Host: "host1", LogType: AtlasLogMongos},
+			expected: "project1/unknown-host1.log",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.names.Path(tt.file); got != tt.expected {
+				t.Errorf("Path() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAtlasOutputNames_Paths(t *testing.T) {
+	files := []AtlasLogFile{
+		{Host: "host1", LogType: AtlasLogMongod},
+		{Host: "host1", LogType: AtlasLogMongodAudit},
+	}
+	if _, err := (AtlasOutputNames{Template: "{host}/{logType}.log"}).Paths(files); err != nil {
+		t.Errorf("Paths() returned an unexpected error: %v", err)
+	}
+	_, err := (AtlasOutputNames{Template: "{cluster}/{host}.log"}).Paths(files)
+	if err == nil || !strings.Contains(err.Error(), "would both be written to /host1.log") {
+		t.Errorf("Expected the logs of host1 to conflict, got %v", err)
+	}
+}
+
+func TestValidateOutputTemplate(t *testing.T) {
+	if err := ValidateOutputTemplate("{cluster}/{host}/{role}-{logType}-{start}-{end}.log.gz"); err != nil {
+		t.Errorf("ValidateOutputTemplate() returned an unexpected error: %v", err)
+	}
+	if err := ValidateOutputTemplate("{cluster}/{hostname}.log"); err == nil || !strings.Contains(err.Error(), "unknown placeholder {hostname}") {
+		t.Errorf("Expected {hostname} to be rejected, got %v", err)
+	}
+}
+
+func TestCreateOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster0", "host1", "mongodb.log.gz")
+	w, closeOutput, err := CreateOutputFile(path)
+	if err != nil {
+		t.Fatalf("CreateOutputFile returned an unexpected error: %v", err)
+	}
+	io.WriteString(w, "redacted\n")
+	if err := closeOutput(); err != nil {
+		t.Fatalf("Failed to close the output: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open the output: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Expected the output to be gzipped: %v", err)
+	}
+	if content, _ := io.ReadAll(gz); string(content) != "redacted\n" {
+		t.Errorf("Unexpected output: %q", content)
+	}
+}
+
+func TestMergeAtlasLogs(t *testing.T) {
+	defer SetRedactIPs(false)
+	dir := t.TempDir()
+	writeLog := func(name string, lines ...string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+	files := []AtlasLogFile{
+		{Host: "host1", LogType: AtlasLogMongod, Path: writeLog("host1.log",
+			`{"t":{"$date":"2025-10-01T10:00:00.000+00:00"},"s":"I","c":"NETWORK","id":1,"ctx":"conn1","msg":"first"}`,
+			`{"t":{"$date":"2025-10-01T10:00:03.000+00:00"},"s":"I","c":"NETWORK","id":1,"ctx":"conn1","msg":"fourth"}`,
+		)},
+		{Host: "host2", LogType: AtlasLogMongod, Path: writeLog("host2.log",
+			`{"t":{"$date":"2025-10-01T12:00:01.000+02:00"},"s":"I","c":"NETWORK","id":1,"ctx":"conn1","msg":"second"}`,
+			`not a log entry`,
+			`{"t":{"$date":"2025-10-01T10:00:04.000Z"},"s":"I","c":"NETWORK","id":1,"ctx":"conn1","msg":"fifth"}`,
+		)},
+		{Host: "host1", LogType: AtlasLogMongodAudit, Path: writeLog("host1.audit.log",
+			`{"atype":"authenticate","ts":{"$date":"2025-10-01T10:00:02.000+00:00"},"uuid":{"$binary":{"base64":"AAAAAAAAAAAAAAAAAAAAAA==","subType":"04"}},"local":{"ip":"10.0.0.1","port":27017},"remote":{"ip":"10.0.0.2","port":51234},"users":[],"roles":[],"param":{"user":"alice","db":"admin","mechanism":"SCRAM-SHA-256"},"result":0}`,
+		)},
+	}
+
+	tests := []struct {
+		name      string
+		redactIPs bool
+		expected  []string
+	}{
+		{
+			name: "hosts kept",
+			expected: []string{
+				`{"t":{"$date":"2025-10-01T10:00:00.000+00:00"},"host":"host1","s":"I","c":"NETWORK","id":1,"ctx":"conn1","msg":"first"}`,
+				`{"t":{"$date":"2025-10-01T12:00:01.000+02:00"},"host":"host2","s":"I","c":"NETWORK","id":1,"ctx":"conn1","msg":"second"}`,
+				`{"atype":"authenticate","ts":{"$date":"2025-10-01T10:00:02.000+00:00"},"host":"host1",`,
+				`{"t":{"$date":"2025-10-01T10:00:03.000+00:00"},"host":"host1","s":"I","c":"NETWORK","id":1,"ctx":"conn1","msg":"fourth"}`,
+				`{"t":{"$date":"2025-10-01T10:00:04.000Z"},"host":"host2","s":"I","c":"NETWORK","id":1,"ctx":"conn1","msg":"fifth"}`,
+			},
+		},
+		{
+			name:      "hosts hashed",
+			redactIPs: true,
+			expected: []string{
+				`"host":"REDACTED_c0365b5a3867cc38"`,
+				`"host":"REDACTED_a0fa7aed11f846f7"`,
+				`"host":"REDACTED_c0365b5a3867cc38"`,
+				`"host":"REDACTED_c0365b5a3867cc38"`,
+				`"host":"REDACTED_a0fa7aed11f846f7"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRedactIPs(tt.redactIPs)
+			var out bytes.Buffer
+			if err := MergeAtlasLogs(&DefaultFileReader{}, files, &out); err != nil {
+				t.Fatalf("MergeAtlasLogs returned an unexpected error: %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != len(tt.expected) {
+				t.Fatalf("Expected %d entries, got %d:\n%s", len(tt.expected), len(lines), out.String())
+			}
+			for i, line := range lines {
+				if !strings.Contains(line, tt.expected[i]) {
+					t.Errorf("Entry %d = %s, want it to contain %s", i, line, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRedactHostName(t *testing.T) {
+	defer SetRedactIPs(false)
+	SetRedactIPs(true)
+	host1 := redactHostName("cluster0-shard-00-00.abcde.mongodb.net")
+	host2 := redactHostName("cluster0-shard-00-01.abcde.mongodb.net")
+	if host1 == host2 || strings.Contains(host1, ".") || !strings.HasPrefix(host1, redactedString+"_") {
+		t.Errorf("Expected distinct hashes of the hosts, got %q and %q", host1, host2)
+	}
+	if host1 != redactHostName("cluster0-shard-00-00.abcde.mongodb.net") {
+		t.Errorf("Expected the hash of a host to be consistent")
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// RedactAuditEvent redacts an event of the JSON audit log, as written by mongod and mongos with
+// auditLog.format set to JSON. The commands checked by authCheck events are redacted like in log
+// entries, and the users, custom data and namespaces in the parameters of the other events are
+// redacted according to their type (atype).
+func RedactAuditEvent(jsonStr string) (*orderedmap.OrderedMap[string, any], error) {
+	event, err := UnmarshalOrdered([]byte(jsonStr))
+	if err != nil {
+		return nil, err
+	}
+	atypeVal, _ := event.Get("atype")
+	atype, _ := atypeVal.(string)
+	SetCurrentComponent("")
+	SetCurrentNamespace("")
+
+	for _, field := range []string{"local", "remote"} {
+		if endpoint, ok := event.Get(field); ok {
+			if endpointMap, ok := endpoint.(*orderedmap.OrderedMap[string, any]); ok {
+				redactAuditEndpoint(field, endpointMap)
+			}
+		}
+	}
+	if users, ok := event.Get("users"); ok {
+		if usersArr, ok := users.([]any); ok {
+			redactAuditUsers(usersArr)
+		}
+	}
+	if roles, ok := event.Get("roles"); ok {
+		if rolesArr, ok := roles.([]any); ok {
+			redactAuditRoles(rolesArr)
+		}
+	}
+	if param, ok := event.Get("param"); ok {
+		if paramMap, ok := param.(*orderedmap.OrderedMap[string, any]); ok {
+			redactAuditParam(atype, paramMap)
+		}
+	}
+	return event, nil
+}
+
+// redactAuditEndpoint redacts the address of a client or server, e.g., {"ip": "10.0.0.1",
+// "port": 27017}, like the remote of log entries.
+func redactAuditEndpoint(field string, endpoint *orderedmap.OrderedMap[string, any]) {
+	if _, ok := endpoint.Get("ip"); ok {
+		reportIPDetected(field)
+		if redactIPs {
+			endpoint.Set("ip", "255.255.255.255")
+			if _, ok := endpoint.Get("port"); ok {
+				endpoint.Set("port", 65535)
+			}
+			reportRedactedValue([]string{field}, nil)
+		}
+	}
+}
+
+func redactAuditUsers(users []any) {
+	for _, u := range users {
+		if userMap, ok := u.(*orderedmap.OrderedMap[string, any]); ok {
+			if name, ok := userMap.Get("user"); ok {
+				userMap.Set("user", redactScalarValue([]string{"user"}, name, false, false))
+			}
+			hashAuditDatabase(userMap)
+		}
+	}
+}
+
+// redactAuditRoles hashes the databases of roles. Role names are kept, as most are built-in.
+func redactAuditRoles(roles []any) {
+	for _, r := range roles {
+		if roleMap, ok := r.(*orderedmap.OrderedMap[string, any]); ok {
+			hashAuditDatabase(roleMap)
+		}
+	}
+}
+
+// hashAuditDatabase hashes the database of a user or role with --redactNamespaces. Virtual
+// databases, such as $external, are kept.
+func hashAuditDatabase(m *orderedmap.OrderedMap[string, any]) {
+	if !redactNamespaces {
+		return
+	}
+	if v, ok := m.Get("db"); ok {
+		if s, ok := v.(string); ok && s != "" && !strings.HasPrefix(s, "$") {
+			m.Set("db", HashName(s))
+		}
+	}
+}
+
+func redactAuditParam(atype string, param *orderedmap.OrderedMap[string, any]) {
+	nsVal, _ := param.Get("ns")
+	ns, _ := nsVal.(string)
+	SetCurrentNamespace(ns)
+	shouldEagerRedact := isEagerRedactionNamespace(ns)
+
+	switch atype {
+	case "authCheck":
+		if args, ok := param.Get("args"); ok {
+			if argsMap, ok := args.(*orderedmap.OrderedMap[string, any]); ok {
+				redactCommand(argsMap, shouldEagerRedact)
+				if redactNamespaces {
+					redactNamespace(argsMap)
+				}
+			}
+		}
+	case "createIndex", "dropIndex":
+		if shouldEagerRedact {
+			if indexName, ok := param.Get("indexName"); ok {
+				if s, ok := indexName.(string); ok {
+					param.Set("indexName", HashName(s))
+				}
+			}
+			if spec, ok := param.Get("indexSpec"); ok {
+				if specMap, ok := spec.(*orderedmap.OrderedMap[string, any]); ok {
+					if key, ok := specMap.Get("key"); ok {
+						if keyMap, ok := key.(*orderedmap.OrderedMap[string, any]); ok {
+							specMap.Set("key", hashFieldNames(keyMap))
+						}
+					}
+					if name, ok := specMap.Get("name"); ok {
+						if s, ok := name.(string); ok {
+							specMap.Set("name", HashName(s))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Parameters shared by the user and role management events.
+	if user, ok := param.Get("user"); ok {
+		param.Set("user", redactScalarValue([]string{"user"}, user, false, false))
+	}
+	for _, field := range []string{"roles", "inheritedRoles"} {
+		if roles, ok := param.Get(field); ok {
+			if rolesArr, ok := roles.([]any); ok {
+				redactAuditRoles(rolesArr)
+			}
+		}
+	}
+	if customData, ok := param.Get("customData"); ok {
+		if customDataMap, ok := customData.(*orderedmap.OrderedMap[string, any]); ok {
+			param.Set("customData", redactQueryValues(customDataMap, false, false, nil, []string{"customData"}))
+		}
+	}
+	if redactNamespaces {
+		// Collections are renamed from old to new.
+		for _, field := range []string{"ns", "old", "new"} {
+			if v, ok := param.Get(field); ok {
+				if s, ok := v.(string); ok && s != "" {
+					param.Set(field, HashName(s))
+				}
+			}
+		}
+		hashAuditDatabase(param)
+		if privileges, ok := param.Get("privileges"); ok {
+			if privilegesArr, ok := privileges.([]any); ok {
+				for _, p := range privilegesArr {
+					redactPrivilegeResource(p)
+				}
+			}
+		}
+	}
+}
+
+// redactPrivilegeResource hashes the database and collection a privilege of a role applies to.
+// Empty names, which stand for any database or collection, are kept.
+func redactPrivilegeResource(privilege any) {
+	privilegeMap, ok := privilege.(*orderedmap.OrderedMap[string, any])
+	if !ok {
+		return
+	}
+	resource, _ := privilegeMap.Get("resource")
+	resourceMap, ok := resource.(*orderedmap.OrderedMap[string, any])
+	if !ok {
+		return
+	}
+	for _, field := range []string{"db", "collection"} {
+		if v, ok := resourceMap.Get(field); ok {
+			if s, ok := v.(string); ok && s != "" {
+				resourceMap.Set(field, HashName(s))
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRedactAuditEvent(t *testing.T) {
+	authCheck := getFixtureContent(t, "test_fixtures/audit_authcheck.json")
+	tests := []struct {
+		name          string
+		event         string
+		options       func()
+		expectedPaths map[string]any
+	}{
+		{
+			name:    "authCheck",
+			event:   authCheck,
+			options: setOptionsRedactedStrings,
+			expectedPaths: map[string]any{
+				"param.args.filter.customerEmail": "redacted@redacted.com",
+				"param.args.filter.status":        RedactedString,
+				"param.args.find":                 "invoices",
+				"param.ns":                        "billing.invoices",
+				"users.0.user":                    RedactedString,
+				"roles.0.role":                    "readWrite",
+				"remote.ip":                       "10.20.30.40",
+			},
+		},
+		{
+			name:  "authCheck with field names, namespaces and IPs",
+			event: authCheck,
+			options: func() {
+				setOptionsRedactedStringsAndNamespaces()
+				SetEagerRedactionPaths([]string{"billing.*"})
+				SetRedactIPs(true)
+			},
+			expectedPaths: map[string]any{
+				"param.args.filter." + HashName("status"): RedactedString,
+				"param.args.find":                         HashName("invoices"),
+				"param.ns":                                HashName("billing.invoices"),
+				"remote.ip":                               "255.255.255.255",
+				"remote.port":                             float64(65535),
+				"local.ip":                                "255.255.255.255",
+				"users.0.db":                              HashName("admin"),
+				"roles.0.db":                              HashName("billing"),
+				"roles.0.role":                            "readWrite",
+			},
+		},
+		{
+			name:    "createUser",
+			event:   `{"atype":"createUser","param":{"user":"jane","db":"billing","customData":{"employeeId":"E-1234","team":"finance"},"roles":[{"role":"read","db":"billing"}]},"result":0}`,
+			options: setOptionsRedactedStrings,
+			expectedPaths: map[string]any{
+				"param.user":                  RedactedString,
+				"param.db":                    "billing",
+				"param.customData.employeeId": RedactedString,
+				"param.customData.team":       RedactedString,
+				"param.roles.0.role":          "read",
+			},
+		},
+		{
+			name:    "authenticate",
+			event:   `{"atype":"authenticate","param":{"user":"jane@example.com","db":"$external","mechanism":"PLAIN"},"result":0}`,
+			options: setOptionsRedactedStringsAndNamespaces,
+			expectedPaths: map[string]any{
+				"param.user":      "redacted@redacted.com",
+				"param.db":        "$external",
+				"param.mechanism": "PLAIN",
+			},
+		},
+		{
+			name:    "createRole",
+			event:   `{"atype":"createRole","param":{"role":"invoiceReader","db":"admin","privileges":[{"resource":{"db":"billing","collection":""},"actions":["find"]}]},"result":0}`,
+			options: setOptionsRedactedStringsAndNamespaces,
+			expectedPaths: map[string]any{
+				"param.db":                               HashName("admin"),
+				"param.privileges.0.resource.db":         HashName("billing"),
+				"param.privileges.0.resource.collection": "",
+				"param.privileges.0.actions.0":           "find",
+			},
+		},
+		{
+			name:    "renameCollection",
+			event:   `{"atype":"renameCollection","param":{"old":"billing.invoices","new":"billing.invoices_2024"},"result":0}`,
+			options: setOptionsRedactedStringsAndNamespaces,
+			expectedPaths: map[string]any{
+				"param.old": HashName("billing.invoices"),
+				"param.new": HashName("billing.invoices_2024"),
+			},
+		},
+		{
+			name:  "createIndex",
+			event: `{"atype":"createIndex","param":{"ns":"billing.invoices","indexName":"customerEmail_1","indexSpec":{"v":2,"key":{"customerEmail":1},"name":"customerEmail_1"}},"result":0}`,
+			options: func() {
+				setOptionsRedactedStrings()
+				SetEagerRedactionPaths([]string{"billing.*"})
+			},
+			expectedPaths: map[string]any{
+				"param.ns":        "billing.invoices",
+				"param.indexName": HashName("customerEmail_1"),
+				"param.indexSpec.key." + HashName("customerEmail"): float64(1),
+				"param.indexSpec.name":                             HashName("customerEmail_1"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options()
+			defer setOptionsRedactedStrings()
+			event, err := RedactAuditEvent(tt.event)
+			if err != nil {
+				t.Fatalf("RedactAuditEvent returned an unexpected error: %v", err)
+			}
+			for path, want := range tt.expectedPaths {
+				if got := getJSONPath(event, path); !valuesEqual(got, want) {
+					t.Errorf("expected %s to be %s, got %s", path, getTypeInfo(want), getTypeInfo(got))
+				}
+			}
+		})
+	}
+}
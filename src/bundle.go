@@ -0,0 +1,457 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// NonLogFilePolicy decides what happens to the files of a bundle that aren't MongoDB logs.
+type NonLogFilePolicy string
+
+const (
+	NonLogFilesExclude NonLogFilePolicy = "exclude"
+	NonLogFilesCopy    NonLogFilePolicy = "copy"
+)
+
+// ParseNonLogFilePolicy returns the NonLogFilePolicy for its name.
+func ParseNonLogFilePolicy(name string) (NonLogFilePolicy, error) {
+	switch policy := NonLogFilePolicy(strings.ToLower(name)); policy {
+	case NonLogFilesExclude, NonLogFilesCopy:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid non-log file policy %q (expected 'exclude' or 'copy')", name)
+}
+
+// sniffSize is the number of leading bytes of a file inspected to recognize a MongoDB log.
+const sniffSize = 64 * 1024
+
+var logFileNameRegex = regexp.MustCompile(`(?i)(^mongo[ds]?\b|\.log$|\.log[.-])`)
+
+// IsArchive reports whether filePath names a tar archive, optionally gzipped.
+func IsArchive(filePath string) bool {
+	name := strings.ToLower(filePath)
+	return strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// BundleResult lists what happened to each file of a bundle, by path relative to its root.
+type BundleResult struct {
+	Redacted []string
+	Copied   []string
+	Excluded []string
+	// Unrecognized lists the files named like logs whose content isn't a MongoDB structured log.
+	Unrecognized []string
+	// Undetermined lists the compressed files and archives whose content couldn't be recognized.
+	// They may hold logs, so they're left out whatever the NonLogFilePolicy.
+	Undetermined []string
+}
+
+// bundleSink receives the files of a redacted bundle.
+type bundleSink interface {
+	Create(relPath string, mode fs.FileMode, modTime time.Time) (io.WriteCloser, error)
+	Close() error
+}
+
+// RedactBundle redacts every MongoDB log in a directory or tar archive (input), and writes the
+// results to a directory or tar archive (output) with the same structure. Logs are recognized by
+// their content, so rotated, compressed and pretty-printed logs are redacted whatever their names;
+// compressed logs stay compressed. Other files are copied or left out according to policy. All the logs are redacted with
+// the same options, so a field name is hashed the same way in every file. An empty output
+// redacts the bundle without writing anything.
+func RedactBundle(input, output string, policy NonLogFilePolicy) (*BundleResult, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() && output != "" && isWithinDir(input, output) {
+		return nil, fmt.Errorf("output %s must not be inside the input directory", output)
+	}
+	var sink bundleSink = discardSink{}
+	if output != "" && IsArchive(output) {
+		sink, err = newArchiveSink(output)
+	} else if output != "" {
+		sink, err = newDirSink(output)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BundleResult{}
+	process := func(relPath string, mode fs.FileMode, modTime time.Time, r io.Reader) error {
+		br := bufio.NewReaderSize(r, sniffSize)
+		header, _ := br.Peek(sniffSize)
+		compression, kind := sniffMongoLog(header)
+		switch kind {
+		case bundleFileLog:
+			result.Redacted = append(result.Redacted, relPath)
+			return redactToSink(sink, relPath, mode, modTime, br, compression)
+		case bundleFileUndetermined:
+			result.Undetermined = append(result.Undetermined, relPath)
+			return nil
+		}
+		if logFileNameRegex.MatchString(path.Base(relPath)) {
+			result.Unrecognized = append(result.Unrecognized, relPath)
+		}
+		if policy != NonLogFilesCopy {
+			result.Excluded = append(result.Excluded, relPath)
+			return nil
+		}
+		result.Copied = append(result.Copied, relPath)
+		return copyToSink(sink, relPath, mode, modTime, br)
+	}
+
+	if IsArchive(input) {
+		err = walkArchive(input, process)
+	} else {
+		err = walkDir(input, process)
+	}
+	if closeErr := sink.Close(); err == nil {
+		err = closeErr
+	}
+	return result, err
+}
+
+// bundleFileKind is what a file of a bundle holds, as recognized from its first bytes.
+type bundleFileKind int
+
+const (
+	bundleFileOther bundleFileKind = iota
+	bundleFileLog
+	// bundleFileUndetermined is a compressed file or an archive whose content can't be recognized,
+	// and which may hold logs.
+	bundleFileUndetermined
+)
+
+// sniffMongoLog returns how header, the first bytes of a file, is compressed, and whether it's the
+// start of a MongoDB structured log, one entry per line or pretty-printed. It's recognized the same
+// way as the input of redact, so whatever redact reads is recognized here.
+func sniffMongoLog(header []byte) (Compression, bundleFileKind) {
+	compression := DetectCompression(header)
+	if compression == CompressionTar || compression == CompressionZip {
+		return compression, bundleFileUndetermined
+	}
+	s, err := openLogStream(bytes.NewReader(header))
+	if err != nil {
+		return compression, bundleFileUndetermined
+	}
+	defer s.Close()
+	if s.archived {
+		return compression, bundleFileUndetermined
+	}
+	// The header is usually a truncated compressed stream, so read errors are expected.
+	data, _ := io.ReadAll(io.LimitReader(s, sniffSize))
+	// A file that can't be recognized is a non-log file, unless it's compressed: all that's known
+	// then is that it isn't plain text.
+	unknown := bundleFileOther
+	if compression != CompressionNone {
+		unknown = bundleFileUndetermined
+	}
+
+	var first []byte
+	switch s.Format {
+	case FormatJSONLines:
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				first = line
+				break
+			}
+		}
+	case FormatPrettyJSON:
+		var raw json.RawMessage
+		if json.NewDecoder(bytes.NewReader(data)).Decode(&raw) != nil {
+			return compression, unknown
+		}
+		first = raw
+		if raw[0] == '[' {
+			var elements []json.RawMessage
+			if json.Unmarshal(raw, &elements) != nil || len(elements) == 0 {
+				return compression, unknown
+			}
+			first = elements[0]
+		}
+	case FormatLegacyText:
+		return compression, bundleFileOther
+	default:
+		return compression, unknown
+	}
+	if first == nil {
+		return compression, unknown
+	}
+	entry, err := UnmarshalOrdered(first)
+	if err != nil {
+		return compression, unknown
+	}
+	_, hasTime := entry.Get("t")
+	_, hasMsg := entry.Get("msg")
+	if hasTime && hasMsg {
+		return compression, bundleFileLog
+	}
+	return compression, bundleFileOther
+}
+
+// redactedBundlePath returns the path of the redaction of the log at relPath. There's no bzip2
+// compressor in the standard library, so bzip2 logs are gzipped instead.
+func redactedBundlePath(relPath string, compression Compression) string {
+	if compression == CompressionBzip2 {
+		ext := path.Ext(relPath)
+		if strings.EqualFold(ext, ".bz2") || strings.EqualFold(ext, ".bz") {
+			return strings.TrimSuffix(relPath, ext) + ".gz"
+		}
+	}
+	return relPath
+}
+
+// newCompressor returns a writer compressing to w the same way as the log it's the redaction of.
+func newCompressor(w io.Writer, compression Compression) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip, CompressionBzip2:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	case CompressionXz:
+		return xz.NewWriter(w)
+	}
+	return nopWriteCloser{w}, nil
+}
+
+func redactToSink(sink bundleSink, relPath string, mode fs.FileMode, modTime time.Time, r io.Reader, compression Compression) error {
+	s, err := openLogInput(r)
+	if err != nil {
+		return fmt.Errorf("failed to redact %s: %w", relPath, err)
+	}
+	defer s.Close()
+	w, err := sink.Create(redactedBundlePath(relPath, compression), mode, modTime)
+	if err != nil {
+		return err
+	}
+	out, err := newCompressor(w, compression)
+	if err != nil {
+		w.Close()
+		return fmt.Errorf("failed to create %s compressor for %s: %w", compression, relPath, err)
+	}
+	err = redactLogStream(s, out, nil)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to redact %s: %w", relPath, err)
+	}
+	return nil
+}
+
+func copyToSink(sink bundleSink, relPath string, mode fs.FileMode, modTime time.Time, r io.Reader) error {
+	w, err := sink.Create(relPath, mode, modTime)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", relPath, err)
+	}
+	return nil
+}
+
+type bundleFileFunc func(relPath string, mode fs.FileMode, modTime time.Time, r io.Reader) error
+
+func walkDir(root string, fn bundleFileFunc) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return fn(filepath.ToSlash(rel), info.Mode().Perm(), info.ModTime(), f)
+	})
+}
+
+func walkArchive(archivePath string, fn bundleFileFunc) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(strings.ToLower(archivePath), ".tar") {
+		gzReader, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		defer gzReader.Close()
+		r = gzReader
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		rel, err := cleanBundlePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err := fn(rel, hdr.FileInfo().Mode().Perm(), hdr.ModTime, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func isWithinDir(dir, p string) bool {
+	absDir, err1 := filepath.Abs(dir)
+	absPath, err2 := filepath.Abs(p)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// cleanBundlePath rejects archive entries that would be written outside of the output.
+func cleanBundlePath(name string) (string, error) {
+	rel := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "./"))
+	if path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("invalid path in archive: %s", name)
+	}
+	return rel, nil
+}
+
+type discardSink struct{}
+
+func (discardSink) Create(string, fs.FileMode, time.Time) (io.WriteCloser, error) {
+	return nopWriteCloser{io.Discard}, nil
+}
+func (discardSink) Close() error { return nil }
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+type dirSink struct{ root string }
+
+func newDirSink(root string) (*dirSink, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	return &dirSink{root: root}, nil
+}
+
+func (s *dirSink) Create(relPath string, mode fs.FileMode, _ time.Time) (io.WriteCloser, error) {
+	p := filepath.Join(s.root, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+}
+
+func (s *dirSink) Close() error { return nil }
+
+// archiveSink writes files to a tar archive. Each file is staged in a temporary file first,
+// since a tar header needs the size of the file before its content.
+type archiveSink struct {
+	file *os.File
+	gz   *gzip.Writer
+	tar  *tar.Writer
+}
+
+func newArchiveSink(archivePath string) (*archiveSink, error) {
+	f, err := os.Create(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output archive: %w", err)
+	}
+	s := &archiveSink{file: f}
+	var w io.Writer = f
+	if !strings.HasSuffix(strings.ToLower(archivePath), ".tar") {
+		s.gz = gzip.NewWriter(f)
+		w = s.gz
+	}
+	s.tar = tar.NewWriter(w)
+	return s, nil
+}
+
+func (s *archiveSink) Create(relPath string, mode fs.FileMode, modTime time.Time) (io.WriteCloser, error) {
+	tmp, err := os.CreateTemp("", "anonymongo-bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	return &archiveEntryWriter{sink: s, tmp: tmp, hdr: &tar.Header{
+		Name:    relPath,
+		Mode:    int64(mode),
+		ModTime: modTime,
+	}}, nil
+}
+
+func (s *archiveSink) Close() error {
+	err := s.tar.Close()
+	if s.gz != nil {
+		if closeErr := s.gz.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type archiveEntryWriter struct {
+	sink *archiveSink
+	tmp  *os.File
+	hdr  *tar.Header
+}
+
+func (w *archiveEntryWriter) Write(p []byte) (int, error) { return w.tmp.Write(p) }
+
+func (w *archiveEntryWriter) Close() error {
+	defer os.Remove(w.tmp.Name())
+	defer w.tmp.Close()
+	size, err := w.tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := w.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.hdr.Size = size
+	if err := w.sink.tar.WriteHeader(w.hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(w.sink.tar, w.tmp)
+	return err
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(data)); err != nil {
+		t.Fatalf("Failed to gzip: %v", err)
+	}
+	gz.Close()
+	return buf.Bytes()
+}
+
+func gunzipBytes(t *testing.T, data []byte) string {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to open gzip data: %v", err)
+	}
+	out, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to gunzip: %v", err)
+	}
+	return string(out)
+}
+
+// bundleFiles returns the content of a test bundle by path: structured logs under various names,
+// a gzipped log, a legacy text log and a non-log file.
+func bundleFiles(t *testing.T) (map[string][]byte, string) {
+	t.Helper()
+	log := getFixtureContent(t, "test_fixtures/simple_find.json") + "\n" +
+		getFixtureContent(t, "test_fixtures/find_with_emails.json") + "\n"
+	return map[string][]byte{
+		"mongod.log":                         []byte(log),
+		"rs0/mongod.log.2025-06-01T00-00-00": []byte(log),
+		"rs0/diagnostics/mongodb.gz":         gzipBytes(t, log),
+		"rs0/mongos.log":                     []byte("2020-01-01T00:00:00.000+0000 I NETWORK [conn1] legacy text log\n"),
+		"notes.txt":                          []byte("support case notes\n"),
+	}, log
+}
+
+func writeBundleDir(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func writeBundleArchive(t *testing.T, archivePath string, files map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0o644, Size: int64(len(files[name]))})
+		tw.Write(files[name])
+	}
+	tw.Close()
+	if err := os.WriteFile(archivePath, gzipBytes(t, buf.String()), 0o644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+}
+
+func readBundleDir(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		data, err := os.ReadFile(p)
+		files[filepath.ToSlash(rel)] = data
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	return files
+}
+
+func readBundleArchive(t *testing.T, archivePath string) map[string][]byte {
+	t.Helper()
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to read archive: %v", err)
+	}
+	tr := tar.NewReader(strings.NewReader(gunzipBytes(t, data)))
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		files[hdr.Name], _ = io.ReadAll(tr)
+	}
+}
+
+func redactLog(t *testing.T, log string) string {
+	t.Helper()
+	var out strings.Builder
+	if err := ProcessMongoLogFileFromReader(strings.NewReader(log), &out, nil); err != nil {
+		t.Fatalf("Failed to redact: %v", err)
+	}
+	return out.String()
+}
+
+func TestRedactBundle(t *testing.T) {
+	setOptionsRedactedStringsWithEagerRedaction()
+	defer setOptionsRedactedStrings()
+	files, log := bundleFiles(t)
+	redacted := redactLog(t, log)
+
+	tests := []struct {
+		name         string
+		archive      bool
+		policy       NonLogFilePolicy
+		wantCopied   []string
+		wantExcluded []string
+	}{
+		{"directory, excluding non-log files", false, NonLogFilesExclude, nil, []string{"notes.txt", "rs0/mongos.log"}},
+		{"directory, copying non-log files", false, NonLogFilesCopy, []string{"notes.txt", "rs0/mongos.log"}, nil},
+		{"archive, excluding non-log files", true, NonLogFilesExclude, nil, []string{"notes.txt", "rs0/mongos.log"}},
+		{"archive, copying non-log files", true, NonLogFilesCopy, []string{"notes.txt", "rs0/mongos.log"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input, output := filepath.Join(dir, "bundle"), filepath.Join(dir, "redacted")
+			if tt.archive {
+				input, output = input+".tar.gz", output+".tgz"
+				writeBundleArchive(t, input, files)
+			} else {
+				writeBundleDir(t, input, files)
+			}
+
+			result, err := RedactBundle(input, output, tt.policy)
+			if err != nil {
+				t.Fatalf("RedactBundle() error = %v", err)
+			}
+			wantRedacted := []string{"mongod.log", "rs0/diagnostics/mongodb.gz", "rs0/mongod.log.2025-06-01T00-00-00"}
+			for _, list := range [][]string{result.Redacted, result.Copied, result.Excluded} {
+				slices.Sort(list)
+			}
+			if !reflect.DeepEqual(result.Redacted, wantRedacted) {
+				t.Errorf("Redacted = %v, want %v", result.Redacted, wantRedacted)
+			}
+			if !reflect.DeepEqual(result.Copied, tt.wantCopied) {
+				t.Errorf("Copied = %v, want %v", result.Copied, tt.wantCopied)
+			}
+			if !reflect.DeepEqual(result.Excluded, tt.wantExcluded) {
+				t.Errorf("Excluded = %v, want %v", result.Excluded, tt.wantExcluded)
+			}
+			if want := []string{"rs0/mongos.log"}; !reflect.DeepEqual(result.Unrecognized, want) {
+				t.Errorf("Unrecognized = %v, want %v", result.Unrecognized, want)
+			}
+
+			var got map[string][]byte
+			if tt.archive {
+				got = readBundleArchive(t, output)
+			} else {
+				got = readBundleDir(t, output)
+			}
+			if len(got) != len(wantRedacted)+len(tt.wantCopied) {
+				t.Errorf("Output has %d files, want %d", len(got), len(wantRedacted)+len(tt.wantCopied))
+			}
+			for _, name := range []string{"mongod.log", "rs0/mongod.log.2025-06-01T00-00-00"} {
+				if string(got[name]) != redacted {
+					t.Errorf("%s =\n%s\nwant\n%s", name, got[name], redacted)
+				}
+			}
+			if gz := got["rs0/diagnostics/mongodb.gz"]; gunzipBytes(t, gz) != redacted {
+				t.Errorf("rs0/diagnostics/mongodb.gz isn't the gzipped redacted log")
+			}
+			for _, name := range tt.wantCopied {
+				if !bytes.Equal(got[name], files[name]) {
+					t.Errorf("%s = %q, want it copied unchanged", name, got[name])
+				}
+			}
+		})
+	}
+}
+
+func TestRedactBundleDryRun(t *testing.T) {
+	setOptionsRedactedStrings()
+	files, _ := bundleFiles(t)
+	input := filepath.Join(t.TempDir(), "bundle")
+	writeBundleDir(t, input, files)
+
+	result, err := RedactBundle(input, "", NonLogFilesCopy)
+	if err != nil {
+		t.Fatalf("RedactBundle() error = %v", err)
+	}
+	if len(result.Redacted) != 3 || len(result.Copied) != 2 {
+		t.Errorf("RedactBundle() = %+v, want 3 redacted and 2 copied files", result)
+	}
+	if got := readBundleDir(t, input); !reflect.DeepEqual(got, files) {
+		t.Errorf("Input directory was modified")
+	}
+}
+
+func TestRedactBundleOutputInsideInput(t *testing.T) {
+	input := t.TempDir()
+	if _, err := RedactBundle(input, filepath.Join(input, "redacted"), NonLogFilesExclude); err == nil {
+		t.Errorf("RedactBundle() error = nil, want an error for an output inside the input directory")
+	}
+}
+
+func TestRedactBundleArchiveTraversal(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "../escape.txt", Mode: 0o644, Size: 1})
+	tw.Write([]byte("x"))
+	tw.Close()
+	input := filepath.Join(dir, "bundle.tar")
+	if err := os.WriteFile(input, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	if _, err := RedactBundle(input, filepath.Join(dir, "out", "redacted"), NonLogFilesCopy); err == nil {
+		t.Errorf("RedactBundle() error = nil, want an error for an entry outside the archive root")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "escape.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Entry outside the archive root was written")
+	}
+}
+
+// TestRedactBundleCompressed checks that logs compressed in any of the formats redact reads, and
+// pretty-printed ones, are redacted, and that a compressed file that can't be recognized is left out
+// even when non-log files are copied.
+func TestRedactBundleCompressed(t *testing.T) {
+	setOptionsRedactedStringsWithEagerRedaction()
+	defer setOptionsRedactedStrings()
+	line := getFixtureContent(t, "test_fixtures/simple_find.json") + "\n"
+	bzip2Data, err := os.ReadFile(filepath.Join("..", "test_fixtures", "simple_find.log.bz2"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(line), "", "  "); err != nil {
+		t.Fatalf("Failed to indent log: %v", err)
+	}
+	files := map[string][]byte{
+		"mongod.log.bz2":     bzip2Data,
+		"mongod.log.zst":     zstdBytes(t, line),
+		"mongod.log.xz":      xzBytes(t, line),
+		"mongod-pretty.json": pretty.Bytes(),
+		"diagnostic.data.gz": gzipBytes(t, "\x00\x01binary data"),
+	}
+	dir := t.TempDir()
+	input, output := filepath.Join(dir, "bundle"), filepath.Join(dir, "redacted")
+	writeBundleDir(t, input, files)
+
+	result, err := RedactBundle(input, output, NonLogFilesCopy)
+	if err != nil {
+		t.Fatalf("RedactBundle() error = %v", err)
+	}
+	slices.Sort(result.Redacted)
+	if want := []string{"mongod-pretty.json", "mongod.log.bz2", "mongod.log.xz", "mongod.log.zst"}; !reflect.DeepEqual(result.Redacted, want) {
+		t.Errorf("Redacted = %v, want %v", result.Redacted, want)
+	}
+	if want := []string{"diagnostic.data.gz"}; !reflect.DeepEqual(result.Undetermined, want) || len(result.Copied) != 0 {
+		t.Errorf("Undetermined = %v and Copied = %v, want %v and none", result.Undetermined, result.Copied, want)
+	}
+
+	redacted := redactLog(t, line)
+	got := readBundleDir(t, output)
+	tests := []struct {
+		name            string
+		wantCompression Compression
+	}{
+		// There's no bzip2 compressor, so the redacted bzip2 log is gzipped.
+		{"mongod.log.gz", CompressionGzip},
+		{"mongod.log.zst", CompressionZstd},
+		{"mongod.log.xz", CompressionXz},
+		{"mongod-pretty.json", CompressionNone},
+	}
+	if len(got) != len(tests) {
+		t.Errorf("Output has %d files, want %d", len(got), len(tests))
+	}
+	for _, tt := range tests {
+		s, err := openLogStream(bytes.NewReader(got[tt.name]))
+		if err != nil {
+			t.Errorf("Failed to open %s: %v", tt.name, err)
+			continue
+		}
+		content, err := io.ReadAll(s)
+		s.Close()
+		if err != nil || string(content) != redacted || s.Compression != tt.wantCompression {
+			t.Errorf("%s = %q (%s, error %v), want %q (%s)", tt.name, content, s.Compression, err, redacted, tt.wantCompression)
+		}
+	}
+}
+
+func TestSniffMongoLog(t *testing.T) {
+	structured := `{"t":{"$date":"2025-05-30T09:47:39.001+00:00"},"s":"I","c":"NETWORK","id":22943,"ctx":"listener","msg":"Connection accepted"}` + "\n"
+	pretty := "{\n  \"t\": {\"$date\": \"2025-05-30T09:47:39.001+00:00\"},\n  \"msg\": \"Connection accepted\"\n}\n"
+	legacy := "2020-01-01T00:00:00.000+0000 I NETWORK [conn1] connection accepted\n"
+	bzip2Data, err := os.ReadFile(filepath.Join("..", "test_fixtures", "simple_find.log.bz2"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	tests := []struct {
+		name            string
+		header          []byte
+		wantCompression Compression
+		wantKind        bundleFileKind
+	}{
+		{"structured log", []byte(structured), CompressionNone, bundleFileLog},
+		{"structured log after blank lines", []byte("\n\n" + structured), CompressionNone, bundleFileLog},
+		{"pretty-printed log", []byte(pretty), CompressionNone, bundleFileLog},
+		{"array of log entries", []byte("[" + structured + "]"), CompressionNone, bundleFileLog},
+		{"gzipped structured log", gzipBytes(t, structured), CompressionGzip, bundleFileLog},
+		{"truncated gzipped structured log", gzipBytes(t, strings.Repeat(structured, 100))[:200], CompressionGzip, bundleFileLog},
+		{"gzipped pretty-printed log", gzipBytes(t, pretty), CompressionGzip, bundleFileLog},
+		{"zstd structured log", zstdBytes(t, structured), CompressionZstd, bundleFileLog},
+		{"xz structured log", xzBytes(t, structured), CompressionXz, bundleFileLog},
+		{"bzip2 structured log", bzip2Data, CompressionBzip2, bundleFileLog},
+		{"gzipped text", gzipBytes(t, "hello\n"), CompressionGzip, bundleFileUndetermined},
+		{"corrupted gzip", []byte{0x1f, 0x8b, 0x00, 0x00}, CompressionGzip, bundleFileUndetermined},
+		{"gzipped tar", gzipBytes(t, string(tarBytes(t, map[string][]byte{"mongod.log": []byte(structured)}, "mongod.log"))), CompressionGzip, bundleFileUndetermined},
+		{"zip", zipBytes(t, map[string][]byte{"mongod.log": []byte(structured)}, "mongod.log"), CompressionZip, bundleFileUndetermined},
+		{"gzipped legacy text log", gzipBytes(t, legacy), CompressionGzip, bundleFileOther},
+		{"gzipped JSON that isn't a log", gzipBytes(t, `{"name":"cluster0"}`+"\n"), CompressionGzip, bundleFileOther},
+		{"legacy text log", []byte(legacy), CompressionNone, bundleFileOther},
+		{"JSON that isn't a log", []byte(`{"name":"cluster0"}` + "\n"), CompressionNone, bundleFileOther},
+		{"JSON scalar", []byte("2020\n"), CompressionNone, bundleFileOther},
+		{"empty file", nil, CompressionNone, bundleFileOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCompression, gotKind := sniffMongoLog(tt.header)
+			if gotCompression != tt.wantCompression || gotKind != tt.wantKind {
+				t.Errorf("sniffMongoLog() = (%v, %v), want (%v, %v)", gotCompression, gotKind, tt.wantCompression, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestCleanBundlePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"mongod.log", "mongod.log", false},
+		{"./logs/mongod.log", "logs/mongod.log", false},
+		{"logs/../mongod.log", "mongod.log", false},
+		{"../mongod.log", "", true},
+		{"logs/../../mongod.log", "", true},
+		{"/etc/passwd", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cleanBundlePath(tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("cleanBundlePath(%q) = %q, %v, want %q (error: %v)", tt.name, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseNonLogFilePolicy(t *testing.T) {
+	for name, want := range map[string]NonLogFilePolicy{"exclude": NonLogFilesExclude, "COPY": NonLogFilesCopy} {
+		if got, err := ParseNonLogFilePolicy(name); err != nil || got != want {
+			t.Errorf("ParseNonLogFilePolicy(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseNonLogFilePolicy("delete"); err == nil {
+		t.Errorf("ParseNonLogFilePolicy(\"delete\") error = nil, want an error")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// defaultOperatorCatalog holds the built-in operator definitions. Each section maps an operator
+// (or an option of an operator) to the name of an OperatorType, or to a nested object describing
+// its options. Two sections are combined when the catalog is loaded:
+//   - every "aggregation" entry is also available as a "core" operator
+//   - the "$search" and "$searchMeta" entries of "searchAggregation" also accept every "search" operator
+//
+//go:embed operators.json
+var defaultOperatorCatalog []byte
+
+var catalogSections = []string{"aggregation", "core", "operatorMaps", "search", "searchAggregation"}
+
+// OperatorCatalog is a loaded set of operator definitions.
+type OperatorCatalog struct {
+	// Definitions is the catalog as written in its data file(s), after applying overrides.
+	Definitions       OrderedMap
+	Aggregation       OrderedMap
+	Core              OrderedMap
+	OperatorMaps      OrderedMap
+	Search            OrderedMap
+	SearchAggregation OrderedMap
+}
+
+// LoadOperatorCatalog builds the operator catalog from the built-in definitions, with the
+// definitions in override (if any) merged on top of them. Nested objects are merged key by key,
+// so an override only needs to list the operators or options it adds or changes.
+func LoadOperatorCatalog(override []byte) (*OperatorCatalog, error) {
+	definitions, err := parseCatalogDefinitions(defaultOperatorCatalog)
+	if err != nil {
+		return nil, err
+	}
+	if len(override) > 0 {
+		overrideDefinitions, err := parseCatalogDefinitions(override)
+		if err != nil {
+			return nil, err
+		}
+		deepMergeMaps(definitions, overrideDefinitions)
+	}
+
+	sections := map[string]OrderedMap{}
+	for _, name := range catalogSections {
+		section := orderedmap.NewOrderedMap[string, any]()
+		if raw, ok := definitions.Get(name); ok && raw != nil {
+			rawMap, ok := raw.(OrderedMap)
+			if !ok {
+				return nil, fmt.Errorf("operator catalog section %q must be an object", name)
+			}
+			section, err = buildOperatorDefinitions(rawMap, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		sections[name] = section
+	}
+
+	catalog := &OperatorCatalog{
+		Definitions:       definitions,
+		Aggregation:       sections["aggregation"],
+		Core:              sections["core"],
+		OperatorMaps:      sections["operatorMaps"],
+		Search:            sections["search"],
+		SearchAggregation: sections["searchAggregation"],
+	}
+	mergeMaps(catalog.Core, catalog.Aggregation)
+	for _, stage := range []string{"$search", "$searchMeta"} {
+		if def, ok := catalog.SearchAggregation.Get(stage); ok {
+			if defMap, ok := def.(OrderedMap); ok {
+				mergeMaps(defMap, catalog.Search)
+			}
+		}
+	}
+	return catalog, nil
+}
+
+// LoadOperatorCatalogFile loads the operator catalog with the definitions in filePath merged on top
+// of the built-in ones.
+func LoadOperatorCatalogFile(filePath string) (*OperatorCatalog, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operator catalog: %w", err)
+	}
+	return LoadOperatorCatalog(data)
+}
+
+// SetOperatorCatalog makes catalog the set of operators used for redaction.
+func SetOperatorCatalog(catalog *OperatorCatalog) {
+	AggregationOperators = catalog.Aggregation
+	CoreOperators = catalog.Core
+	OperatorMapDefs = catalog.OperatorMaps
+	SearchOperators = catalog.Search
+	SearchAggregationOperators = catalog.SearchAggregation
+}
+
+// MarshalIndent renders the catalog definitions in the data file format.
+func (c *OperatorCatalog) MarshalIndent() ([]byte, error) {
+	compact, err := MarshalOrdered(c.Definitions)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, compact, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func parseCatalogDefinitions(data []byte) (OrderedMap, error) {
+	definitions, err := UnmarshalOrdered(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse operator catalog: %w", err)
+	}
+	for el := definitions.Front(); el != nil; el = el.Next() {
+		if !isCatalogSection(el.Key) {
+			return nil, fmt.Errorf("unknown operator catalog section %q (expected one of: %s)", el.Key, strings.Join(catalogSections, ", "))
+		}
+	}
+	return definitions, nil
+}
+
+func isCatalogSection(name string) bool {
+	for _, section := range catalogSections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+func deepMergeMaps(dst, src OrderedMap) {
+	for el := src.Front(); el != nil; el = el.Next() {
+		srcMap, srcIsMap := el.Value.(OrderedMap)
+		existing, _ := dst.Get(el.Key)
+		dstMap, dstIsMap := existing.(OrderedMap)
+		if srcIsMap && dstIsMap {
+			deepMergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst.Set(el.Key, el.Value)
+	}
+}
+
+func buildOperatorDefinitions(raw OrderedMap, path string) (OrderedMap, error) {
+	built := orderedmap.NewOrderedMap[string, any]()
+	for el := raw.Front(); el != nil; el = el.Next() {
+		elPath := path + "." + el.Key
+		switch v := el.Value.(type) {
+		case nil:
+			built.Set(el.Key, nil)
+		case string:
+			opType, err := ParseOperatorType(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid operator catalog entry %s: %w", elPath, err)
+			}
+			built.Set(el.Key, opType)
+		case OrderedMap:
+			nested, err := buildOperatorDefinitions(v, elPath)
+			if err != nil {
+				return nil, err
+			}
+			built.Set(el.Key, nested)
+		default:
+			return nil, fmt.Errorf("invalid operator catalog entry %s: expected an operator type name or an object", elPath)
+		}
+	}
+	return built, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadOperatorCatalog_BuiltIn(t *testing.T) {
+	catalog, err := LoadOperatorCatalog(nil)
+	if err != nil {
+		t.Fatalf("Failed to load the built-in operator catalog: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		section  OrderedMap
+		path     []string
+		expected any
+	}{
+		{"Core operator", catalog.Core, []string{"$in"}, Redactable},
+		{"Aggregation stage merged into core operators", catalog.Core, []string{"$limit"}, Exempt},
+		{"Nested aggregation option", catalog.Aggregation, []string{"$lookup", "from"}, Namespace},
+		{"Null aggregation option", catalog.Aggregation, []string{"$listSampledQueries", "namespace"}, nil},
+		{"Operator map definition", catalog.OperatorMaps, []string{"facets", "path"}, FieldName},
+		{"Search stage option", catalog.SearchAggregation, []string{"$search", "index"}, Exempt},
+		{"Search operator merged into $search", catalog.SearchAggregation, []string{"$search", "text", "path"}, FieldName},
+		{"Search operator merged into $searchMeta", catalog.SearchAggregation, []string{"$searchMeta", "compound", "must"}, OperatorArray},
+		{"Newer server stage", catalog.SearchAggregation, []string{"$scoreFusion", "input", "pipelines"}, Pipeline},
+		{"Newer server expression", catalog.Core, []string{"$toUUID"}, Redactable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var current any = tc.section
+			for _, key := range tc.path {
+				m, ok := current.(OrderedMap)
+				if !ok {
+					t.Fatalf("Expected an object before %q in %v", key, tc.path)
+				}
+				val, exists := m.Get(key)
+				if !exists {
+					t.Fatalf("Expected %v to exist in the catalog", tc.path)
+				}
+				current = val
+			}
+			if current != tc.expected {
+				t.Errorf("Expected %v to be %v, but got %v", tc.path, tc.expected, current)
+			}
+		})
+	}
+}
+
+func TestLoadOperatorCatalog_Override(t *testing.T) {
+	override := []byte(`{
+		"core": {"$newExpression": "Redactable", "$in": "Exempt"},
+		"aggregation": {"$lookup": {"as": "FieldName"}, "$newStage": {"target": "Namespace"}}
+	}`)
+	catalog, err := LoadOperatorCatalog(override)
+	if err != nil {
+		t.Fatalf("Failed to load the operator catalog with overrides: %v", err)
+	}
+
+	if v, _ := catalog.Core.Get("$newExpression"); v != Redactable {
+		t.Errorf("Expected the added operator to be Redactable, but got %v", v)
+	}
+	if v, _ := catalog.Core.Get("$in"); v != Exempt {
+		t.Errorf("Expected the overridden operator to be Exempt, but got %v", v)
+	}
+	lookup, _ := catalog.Aggregation.Get("$lookup")
+	if v, _ := lookup.(OrderedMap).Get("as"); v != FieldName {
+		t.Errorf("Expected the overridden $lookup option to be FieldName, but got %v", v)
+	}
+	if v, _ := lookup.(OrderedMap).Get("from"); v != Namespace {
+		t.Errorf("Expected the untouched $lookup option to be kept, but got %v", v)
+	}
+	if _, ok := catalog.Core.Get("$newStage"); !ok {
+		t.Errorf("Expected the added aggregation stage to be merged into core operators")
+	}
+}
+
+func TestLoadOperatorCatalog_Errors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		override      string
+		expectedError string
+	}{
+		{"Unknown operator type", `{"core": {"$x": "Hidden"}}`, `core.$x: unknown operator type "Hidden"`},
+		{"Unknown section", `{"expressions": {}}`, `unknown operator catalog section "expressions"`},
+		{"Non-object section", `{"core": "Redactable"}`, `section "core" must be an object`},
+		{"Invalid entry", `{"core": {"$x": 1}}`, `invalid operator catalog entry core.$x`},
+		{"Malformed JSON", `{"core": `, "failed to parse operator catalog"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadOperatorCatalog([]byte(tc.override))
+			if err == nil {
+				t.Fatal("Expected an error, but got none")
+			}
+			if !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("Expected error to contain %q, but got: %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestOperatorCatalog_MarshalIndentRoundTrip(t *testing.T) {
+	catalog, err := LoadOperatorCatalog(nil)
+	if err != nil {
+		t.Fatalf("Failed to load the built-in operator catalog: %v", err)
+	}
+	out, err := catalog.MarshalIndent()
+	if err != nil {
+		t.Fatalf("MarshalIndent failed: %v", err)
+	}
+	catalogFile := filepath.Join(t.TempDir(), "operators.json")
+	if err := os.WriteFile(catalogFile, out, 0644); err != nil {
+		t.Fatalf("Failed to write catalog file: %v", err)
+	}
+	reloaded, err := LoadOperatorCatalogFile(catalogFile)
+	if err != nil {
+		t.Fatalf("Failed to reload the printed catalog: %v", err)
+	}
+	reprinted, err := reloaded.MarshalIndent()
+	if err != nil {
+		t.Fatalf("MarshalIndent failed: %v", err)
+	}
+	if string(out) != string(reprinted) {
+		t.Errorf("Expected the printed catalog to round-trip unchanged")
+	}
+}
+
+func TestSetOperatorCatalog_AffectsRedaction(t *testing.T) {
+	defaultCatalog, _ := LoadOperatorCatalog(nil)
+	defer SetOperatorCatalog(defaultCatalog)
+	setOptionsRedactedStrings()
+
+	catalog, err := LoadOperatorCatalog([]byte(`{"core": {"$in": "Exempt"}}`))
+	if err != nil {
+		t.Fatalf("Failed to load the operator catalog: %v", err)
+	}
+	SetOperatorCatalog(catalog)
+
+	data, err := os.ReadFile(filepath.Join("..", "test_fixtures", "in_operator.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test log file: %v", err)
+	}
+	entry, err := RedactMongoLog(string(data))
+	if err != nil {
+		t.Fatalf("RedactMongoLog failed: %v", err)
+	}
+	attr, _ := entry.Get("attr")
+	if got := getJSONPath(attr, "command.filter.foo.$in.0"); got == RedactedString {
+		t.Errorf("Expected values of an operator overridden as Exempt to be kept, but got %v", got)
+	}
+}
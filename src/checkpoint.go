@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// fingerprintSize is the number of leading bytes of an input file hashed to recognize it again.
+const fingerprintSize = 1024
+
+// Checkpoint records how far into an input file redaction has progressed, so a later run can
+// resume from there.
+type Checkpoint struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	// Fingerprint is a hash of the first bytes of the input file (up to Offset), used to tell
+	// whether the file at Path is still the one the checkpoint was taken on.
+	Fingerprint string `json:"fingerprint"`
+	// Compressed is set when Path is compressed, in which case Offset is a position in the
+	// decompressed data.
+	Compressed bool `json:"compressed,omitempty"`
+	LineNumber int  `json:"lineNumber,omitempty"`
+	// OutputSize is the size of the output file once the line before Offset was written, and
+	// OutputFingerprint a hash of its last bytes, to validate the output before resuming.
+	OutputSize        int64  `json:"outputSize,omitempty"`
+	OutputFingerprint string `json:"outputFingerprint,omitempty"`
+	// HashKey is the random key values were hashed with by field rules, if there was no encryption
+	// key, so they get the same hashes again after resuming. Nothing about the content of the input
+	// is kept: names are hashed deterministically.
+	HashKey []byte `json:"hashKey,omitempty"`
+}
+
+// LoadCheckpoint reads a checkpoint file. It returns nil, without an error, if the file doesn't exist.
+func LoadCheckpoint(filePath string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", filePath, err)
+	}
+	return &cp, nil
+}
+
+// Save writes the checkpoint to filePath. The file is replaced atomically, so an interrupted run
+// never leaves a partial checkpoint behind.
+func (c *Checkpoint) Save(filePath string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Matches reports whether the checkpoint was taken on the file currently open as f, at path.
+func (c *Checkpoint) Matches(path string, f *os.File) bool {
+	if c == nil || c.Path != path {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || (!c.Compressed && info.Size() < c.Offset) {
+		return false
+	}
+	fingerprint, err := fileFingerprint(f, c.Offset)
+	return err == nil && fingerprint == c.Fingerprint
+}
+
+// fileFingerprint hashes the first bytes of f, up to offset, without moving its read position.
+func fileFingerprint(f io.ReaderAt, offset int64) (string, error) {
+	n := min(offset, fingerprintSize)
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(buf)), nil
+}
+
+// outputFingerprint hashes the last bytes of f before size.
+func outputFingerprint(f io.ReaderAt, size int64) (string, error) {
+	n := min(size, fingerprintSize)
+	buf := make([]byte, n)
+	if _, err := f.ReadAt(buf, size-n); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(buf)), nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadCheckpoint_Missing(t *testing.T) {
+	cp, err := LoadCheckpoint(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || cp != nil {
+		t.Errorf("Expected no checkpoint and no error, but got %v and %v", cp, err)
+	}
+}
+
+func TestLoadCheckpoint_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatalf("Failed to write checkpoint: %v", err)
+	}
+	if _, err := LoadCheckpoint(path); err == nil {
+		t.Errorf("Expected an error for an invalid checkpoint")
+	}
+}
+
+func TestCheckpoint_SaveAndMatch(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "mongod.log")
+	if err := os.WriteFile(input, []byte("line one\nline two\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		t.Fatalf("Failed to open input: %v", err)
+	}
+	defer f.Close()
+	fingerprint, err := fileFingerprint(f, 9)
+	if err != nil {
+		t.Fatalf("fileFingerprint returned an unexpected error: %v", err)
+	}
+
+	path := filepath.Join(dir, "checkpoint.json")
+	saved := &Checkpoint{Path: input, Offset: 9, Fingerprint: fingerprint}
+	if err := saved.Save(path); err != nil {
+		t.Fatalf("Save returned an unexpected error: %v", err)
+	}
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint returned an unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cp, saved) {
+		t.Errorf("Expected checkpoint %+v, but got %+v", saved, cp)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected bool
+	}{
+		{name: "same file", path: input, content: "line one\nline two\n", expected: true},
+		{name: "file grew", path: input, content: "line one\nline two\nline three\n", expected: true},
+		{name: "other path", path: filepath.Join(dir, "other.log"), content: "line one\nline two\n", expected: false},
+		{name: "file replaced", path: input, content: "line 1\nline 2\n", expected: false},
+		{name: "file shrank", path: input, content: "line\n", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(tt.path, []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("Failed to write input: %v", err)
+			}
+			f, err := os.Open(tt.path)
+			if err != nil {
+				t.Fatalf("Failed to open input: %v", err)
+			}
+			defer f.Close()
+			if got := cp.Matches(tt.path, f); got != tt.expected {
+				t.Errorf("Expected Matches to return %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
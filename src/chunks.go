@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultLogChunkSize is the longest range of logs downloaded at once, since the Atlas API times out
+// or truncates its response for long ranges.
+const defaultLogChunkSize = 24 * time.Hour
+
+// timeRange is a range of logs, in epoch seconds.
+type timeRange struct {
+	start int
+	end   int
+}
+
+// logChunks splits the range from start to end into consecutive ranges of at most size, which
+// share their boundaries.
+func logChunks(start, end int, size time.Duration) []timeRange {
+	step := int(size / time.Second)
+	if step <= 0 || end-start <= step {
+		return []timeRange{{start, end}}
+	}
+	var chunks []timeRange
+	for chunkStart := start; chunkStart < end; chunkStart += step {
+		chunks = append(chunks, timeRange{chunkStart, min(chunkStart+step, end)})
+	}
+	return chunks
+}
+
+// chunkMerger concatenates the gzipped logs of consecutive chunks of a range. Chunks can overlap at
+// their boundaries, so the lines of a chunk older than the latest line of the previous ones are
+// dropped, as well as the lines identical to one at that latest time. Lines without a timestamp are
+// kept.
+type chunkMerger struct {
+	w io.Writer
+	// latest is the time of the latest line written, and latestLines the lines written at that time.
+	latest      time.Time
+	latestLines map[string]bool
+}
+
+func newChunkMerger(w io.Writer) *chunkMerger {
+	return &chunkMerger{w: w, latestLines: map[string]bool{}}
+}
+
+// add appends the lines of a gzipped chunk that weren't in the previous chunks. An empty chunk has
+// no lines.
+func (m *chunkMerger) add(chunk io.Reader) error {
+	br := bufio.NewReader(chunk)
+	if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+		return nil
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer gz.Close()
+
+	// The lines of the chunk are compared to those of the previous chunks only.
+	previous, previousLines := m.latest, m.latestLines
+	m.latestLines = cloneLines(previousLines)
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		t := lineTime(line)
+		if !t.IsZero() && !previous.IsZero() && (t.Before(previous) || (t.Equal(previous) && previousLines[line])) {
+			continue
+		}
+		if _, err := fmt.Fprintln(m.w, line); err != nil {
+			return err
+		}
+		switch {
+		case t.After(m.latest):
+			m.latest, m.latestLines = t, map[string]bool{line: true}
+		case t.Equal(m.latest):
+			m.latestLines[line] = true
+		}
+	}
+	return scanner.Err()
+}
+
+func cloneLines(lines map[string]bool) map[string]bool {
+	clone := make(map[string]bool, len(lines)+1)
+	for line := range lines {
+		clone[line] = true
+	}
+	return clone
+}
+
+// timeFields are how the timestamp of a log line (t) or audit event (ts) starts.
+var timeFields = []string{`"t":{"$date":"`, `"ts":{"$date":"`}
+
+// lineTime returns the timestamp of a log line (t) or audit event (ts), or the zero time if it has
+// none. Lines aren't parsed: the timestamp is the first of these fields in the line, which is where
+// mongod writes it.
+func lineTime(line string) time.Time {
+	date := ""
+	first := len(line)
+	for _, field := range timeFields {
+		if i := strings.Index(line[:first], field); i >= 0 {
+			first, date = i, line[i+len(field):]
+		}
+	}
+	end := strings.IndexByte(date, '"')
+	if end < 0 {
+		return time.Time{}
+	}
+	t, _ := time.Parse(time.RFC3339Nano, date[:end])
+	return t
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLogChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    int
+		end      int
+		size     time.Duration
+		expected []timeRange
+	}{
+		{name: "shorter than a chunk", start: 0, end: 3600, size: 24 * time.Hour, expected: []timeRange{{0, 3600}}},
+		{name: "exactly a chunk", start: 0, end: 86400, size: 24 * time.Hour, expected: []timeRange{{0, 86400}}},
+		{name: "last chunk shorter", start: 0, end: 36 * 3600, size: 24 * time.Hour, expected: []timeRange{{0, 86400}, {86400, 36 * 3600}}},
+		{name: "several chunks", start: 100, end: 100 + 3*3600, size: time.Hour, expected: []timeRange{{100, 3700}, {3700, 7300}, {7300, 10900}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if chunks := logChunks(tt.start, tt.end, tt.size); !reflect.DeepEqual(chunks, tt.expected) {
+				t.Errorf("logChunks() = %v, want %v", chunks, tt.expected)
+			}
+		})
+	}
+}
+
+// logLineAt returns a log line at t, with msg to tell lines at the same time apart.
+func logLineAt(t time.Time, msg string) string {
+	return fmt.Sprintf(`{"t":{"$date":"%s"},"s":"I","c":"NETWORK","msg":"%s"}`, t.UTC().Format("2006-01-02T15:04:05.000Z07:00"), msg)
+}
+
+func gzipLines(t *testing.T, lines ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, line := range lines {
+		fmt.Fprintln(gz, line)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Failed to gzip lines: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestChunkMerger(t *testing.T) {
+	t0 := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	a := logLineAt(t0, "a")
+	b := logLineAt(t0.Add(time.Hour), "b")
+	c1 := logLineAt(t0.Add(2*time.Hour), "c1")
+	c2 := logLineAt(t0.Add(2*time.Hour), "c2")
+	c3 := logLineAt(t0.Add(2*time.Hour), "c3")
+	d := logLineAt(t0.Add(3*time.Hour), "d")
+	noTime := "a line without a timestamp"
+
+	var out bytes.Buffer
+	merger := newChunkMerger(&out)
+	chunks := [][]byte{
+		gzipLines(t, a, b, c1, c2),
+		nil,
+		// Overlaps the previous chunk, and has a new line at the time of its latest lines.
+		gzipLines(t, b, c1, c2, c3, noTime, d),
+		// Repeats a line of its own at the latest time of the previous chunks.
+		gzipLines(t, d, d),
+	}
+	for i, chunk := range chunks {
+		if err := merger.add(bytes.NewReader(chunk)); err != nil {
+			t.Fatalf("add(chunk %d) returned an unexpected error: %v", i, err)
+		}
+	}
+
+	expected := strings.Join([]string{a, b, c1, c2, c3, noTime, d}, "\n") + "\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected merged chunks:\n%s\nwant:\n%s", out.String(), expected)
+	}
+
+	if err := newChunkMerger(io.Discard).add(strings.NewReader("not gzipped")); err == nil {
+		t.Error("Expected an error for a chunk that isn't gzipped")
+	}
+}
+
+func TestLineTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected time.Time
+	}{
+		{"log line", `{"t":{"$date":"2025-06-01T10:00:00.123+00:00"},"s":"I","msg":"x"}`, time.Date(2025, 6, 1, 10, 0, 0, 123e6, time.UTC)},
+		{"audit event", `{"atype":"authenticate","ts":{"$date":"2025-06-01T10:00:00.000Z"},"param":{}}`, time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{"nested timestamp after the top-level one", `{"t":{"$date":"2025-06-01T10:00:00Z"},"attr":{"ts":{"$date":"2020-01-01T00:00:00Z"}}}`, time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)},
+		{"no timestamp", `{"msg":"x"}`, time.Time{}},
+		{"invalid timestamp", `{"t":{"$date":"yesterday"}}`, time.Time{}},
+		{"truncated line", `{"t":{"$date":"2025-06-01T1`, time.Time{}},
+		{"text", "a line without a timestamp", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineTime(tt.line); !got.Equal(tt.expected) {
+				t.Errorf("lineTime() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDownloadClusterLogs_Chunks(t *testing.T) {
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(36 * time.Hour)
+	// A line every 30 minutes, with two lines at some times.
+	var lines []string
+	lineTimes := map[string]time.Time{}
+	for at := start; !at.After(end); at = at.Add(30 * time.Minute) {
+		line := logLineAt(at, "line at "+at.Format(time.Kitchen))
+		lines, lineTimes[line] = append(lines, line), at
+		if at.Minute() == 0 && at.Hour()%12 == 0 {
+			twin := logLineAt(at, "twin at "+at.Format(time.Kitchen))
+			lines, lineTimes[twin] = append(lines, twin), at
+		}
+	}
+
+	// The chunks are merged as they're downloaded, so the merged log is the only temporary file.
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if entries, _ := os.ReadDir(tmpDir); len(entries) != 1 {
+			t.Errorf("%d temporary files while downloading a chunk, want 1", len(entries))
+		}
+		from, _ := strconv.Atoi(r.URL.Query().Get("startDate"))
+		to, _ := strconv.Atoi(r.URL.Query().Get("endDate"))
+		// Like the Atlas API, the lines at the boundaries of the range are included, and a few
+		// lines before its start too.
+		var served []string
+		for _, line := range lines {
+			if at := int(lineTimes[line].Unix()); at >= from-3600 && at <= to {
+				served = append(served, line)
+			}
+		}
+		w.Write(gzipLines(t, served...))
+	}))
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+	client.ChunkSize = 12 * time.Hour
+
+	path, err := client.downloadClusterLogsForHost(context.Background(), "pubKey", "privKey", "project1", "host1", AtlasLogMongod, int(start.Unix()), int(end.Unix()))
+	if err != nil {
+		t.Fatalf("downloadClusterLogsForHost returned an unexpected error: %v", err)
+	}
+	defer os.Remove(path)
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected 3 chunks to be downloaded, got %d", n)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open the downloaded log: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("The downloaded log isn't gzipped: %v", err)
+	}
+	content, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Failed to read the downloaded log: %v", err)
+	}
+	expected := strings.Join(lines, "\n") + "\n"
+	if string(content) != expected {
+		t.Errorf("Unexpected concatenated chunks:\n%s\nwant:\n%s", content, expected)
+	}
+}
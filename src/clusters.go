@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// clustersPageSize is the number of clusters requested per page of the clusters API.
+const clustersPageSize = 500
+
+// AtlasCluster is a cluster of an Atlas project, as listed by the clusters API.
+type AtlasCluster struct {
+	Name   string `json:"name"`
+	Paused bool   `json:"paused"`
+}
+
+type atlasClustersPage struct {
+	Results    []AtlasCluster `json:"results"`
+	TotalCount int            `json:"totalCount"`
+}
+
+// ResolveClusterNames returns the names of the clusters whose logs are downloaded: every cluster of
+// the project if all is set, and otherwise the clusters named by patterns, which can be globs
+// (e.g., 'prod-*'). Clusters are only listed when needed, and paused clusters, which have no logs
+// to download, are left out of the listed ones. A pattern matching no cluster is an error.
+func (c *AtlasClient) ResolveClusterNames(ctx context.Context, publicKey, privateKey, projectID string, patterns []string, all bool) ([]string, error) {
+	if !all && !slices.ContainsFunc(patterns, isGlob) {
+		return patterns, nil
+	}
+	clusters, err := c.listClusters(ctx, publicKey, privateKey, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	var names []string
+	addCluster := func(cluster AtlasCluster) {
+		if cluster.Paused {
+			fmt.Fprintf(os.Stdout, "Skipping paused cluster %s\n", cluster.Name)
+			return
+		}
+		if !slices.Contains(names, cluster.Name) {
+			names = append(names, cluster.Name)
+		}
+	}
+	if all {
+		for _, cluster := range clusters {
+			addCluster(cluster)
+		}
+	}
+	for _, pattern := range patterns {
+		matched := false
+		for _, cluster := range clusters {
+			if ok, _ := path.Match(pattern, cluster.Name); ok {
+				matched = true
+				addCluster(cluster)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no cluster of project %s matches %q", projectID, pattern)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("project %s has no running cluster", projectID)
+	}
+	return names, nil
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// listClusters returns every cluster of the project, reading all the pages of the clusters API.
+func (c *AtlasClient) listClusters(ctx context.Context, publicKey, privateKey, projectID string) ([]AtlasCluster, error) {
+	var clusters []AtlasCluster
+	for pageNum := 1; ; pageNum++ {
+		url := fmt.Sprintf("%s/api/atlas/v2/groups/%s/clusters?pageNum=%d&itemsPerPage=%d", c.BaseURL, projectID, pageNum, clustersPageSize)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Accept", "application/vnd.atlas.2024-08-05+json")
+
+		resp, err := c.authenticatedClient(publicKey, privateKey).Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		var page atlasClustersPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		clusters = append(clusters, page.Results...)
+		if len(page.Results) == 0 || len(clusters) >= page.TotalCount {
+			return clusters, nil
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveClusterNames(t *testing.T) {
+	server := newProcessesServer(t)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+
+	tests := []struct {
+		name     string
+		patterns []string
+		all      bool
+		expected []string
+	}{
+		{name: "names", patterns: []string{"othercluster", "shardedcluster"}, expected: []string{"othercluster", "shardedcluster"}},
+		{name: "glob", patterns: []string{"*cluster"}, expected: []string{"shardedcluster", "othercluster"}},
+		{name: "names and globs", patterns: []string{"othercluster", "sh?rded*"}, expected: []string{"othercluster", "shardedcluster"}},
+		{name: "all clusters", all: true, expected: []string{"shardedcluster", "othercluster"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, err := client.ResolveClusterNames(context.Background(), "pubKey", "privKey", "project1", tt.patterns, tt.all)
+			if err != nil {
+				t.Fatalf("ResolveClusterNames returned an unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("ResolveClusterNames() = %v, want %v", names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveClusterNames_Errors(t *testing.T) {
+	server := newProcessesServer(t)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+
+	_, err := client.ResolveClusterNames(context.Background(), "pubKey", "privKey", "project1", []string{"prod-*"}, false)
+	if err == nil || !strings.Contains(err.Error(), `no cluster of project project1 matches "prod-*"`) {
+		t.Errorf("Expected prod-* to match no cluster, got %v", err)
+	}
+	_, err = client.ResolveClusterNames(context.Background(), "pubKey", "privKey", "project1", []string{"paused*"}, false)
+	if err == nil || !strings.Contains(err.Error(), "project project1 has no running cluster") {
+		t.Errorf("Expected the paused cluster to be left out, got %v", err)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"detail":"Unauthorized"}`, http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	client.BaseURL = failing.URL
+	_, err = client.ResolveClusterNames(context.Background(), "pubKey", "privKey", "project1", nil, true)
+	if err == nil || !strings.Contains(err.Error(), "failed to list clusters: unexpected status 401") {
+		t.Errorf("Expected listing the clusters to fail, got %v", err)
+	}
+}
+
+func TestDownloadClusterLogs_MultipleClusters(t *testing.T) {
+	server := newProcessesServer(t)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+
+	filter := HostFilter{Roles: []HostRole{HostRolePrimary}}
+	logFiles, err := client.DownloadClusterLogs(context.Background(), "pubKey", "privKey", "project1", []string{"shardedcluster", "othercluster"}, filter, []AtlasLogType{AtlasLogMongod}, 0, 1)
+	if err != nil {
+		t.Fatalf("DownloadClusterLogs failed: %v", err)
+	}
+	defer client.DeleteClusterLogs(context.Background(), logFiles)
+
+	expected := []AtlasLogFile{
+		{Cluster: "shardedcluster", Host: "ac-q1w2e3-shard-00-00.abcde.mongodb.net", Role: HostRolePrimary, LogType: AtlasLogMongod},
+		{Cluster: "shardedcluster", Host: "ac-q1w2e3-shard-01-00.abcde.mongodb.net", Role: HostRolePrimary, LogType: AtlasLogMongod},
+		{Cluster: "othercluster", Host: "othercluster-shard-00-00.abcde.mongodb.net", Role: HostRolePrimary, LogType: AtlasLogMongod},
+	}
+	if len(logFiles) != len(expected) {
+		t.Fatalf("Expected %d log files, got %+v", len(expected), logFiles)
+	}
+	for i, logFile := range logFiles {
+		content, _ := os.ReadFile(logFile.Path)
+		logFile.Path = ""
+		if logFile != expected[i] || string(content) != expected[i].Host {
+			t.Errorf("Log file %d = %+v holding %q, want %+v", i, logFile, content, expected[i])
+		}
+	}
+}
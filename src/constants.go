@@ -7,8 +7,10 @@ const (
 	RedactedBoolean  = false
 	RedactedObjectId = "000000000000000000000000"
 	RedactedUUID     = "AAAAAAAAAAAAAAAAAAA="
+	// RedactedUUIDString replaces the string form of a UUID, e.g., {"$uuid": "..."}.
+	RedactedUUIDString = "00000000-0000-0000-0000-000000000000"
 )
 
 var (
-	TopLevelSearchOperators = []string{"$search", "$searchMeta", "$vectorSearch", "$rankFusion"}
+	TopLevelSearchOperators = []string{"$search", "$searchMeta", "$vectorSearch", "$rankFusion", "$scoreFusion"}
 )
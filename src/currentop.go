@@ -0,0 +1,113 @@
+package main
+
+import (
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// RedactCurrentOp redacts the output of db.currentOp(), whose operations are under inprog, or an
+// operation returned by the $currentOp aggregation stage. Operations are redacted like the log
+// entries of the same commands, so the same values and names are redacted or hashed the same way,
+// and their session IDs (lsid) are kept to match them with the log.
+func RedactCurrentOp(jsonStr string) (*orderedmap.OrderedMap[string, any], error) {
+	doc, err := UnmarshalOrdered([]byte(jsonStr))
+	if err != nil {
+		return nil, err
+	}
+	SetCurrentComponent("")
+	if inprog, ok := doc.Get("inprog"); ok {
+		if ops, ok := inprog.([]any); ok {
+			for _, op := range ops {
+				if opMap, ok := op.(*orderedmap.OrderedMap[string, any]); ok {
+					redactCurrentOperation(opMap)
+				}
+			}
+			return doc, nil
+		}
+	}
+	redactCurrentOperation(doc)
+	return doc, nil
+}
+
+func redactCurrentOperation(op *orderedmap.OrderedMap[string, any]) {
+	ns := entryNamespace(op)
+	SetCurrentNamespace(ns)
+	shouldEagerRedact := isEagerRedactionNamespace(ns)
+
+	redactOperationCommands(op, shouldEagerRedact)
+	// The cursor of a getMore holds the command that created it.
+	if cursor, ok := op.Get("cursor"); ok {
+		if cursorMap, ok := cursor.(*orderedmap.OrderedMap[string, any]); ok {
+			redactOperationCommands(cursorMap, shouldEagerRedact)
+		}
+	}
+
+	// mongos reports the client as client_s, and the client of the shards under clientMetadata.
+	for _, field := range []string{"client", "client_s", "host"} {
+		redactNetworkLocation(op, field)
+	}
+	if metadata, ok := op.Get("clientMetadata"); ok {
+		if metadataMap, ok := metadata.(*orderedmap.OrderedMap[string, any]); ok {
+			if mongos, ok := metadataMap.Get("mongos"); ok {
+				if mongosMap, ok := mongos.(*orderedmap.OrderedMap[string, any]); ok {
+					redactNetworkLocation(mongosMap, "client")
+					redactNetworkLocation(mongosMap, "host")
+				}
+			}
+		}
+	}
+
+	for _, field := range []string{"effectiveUsers", "runBy"} {
+		if users, ok := op.Get(field); ok {
+			if usersArr, ok := users.([]any); ok {
+				for _, u := range usersArr {
+					if userMap, ok := u.(*orderedmap.OrderedMap[string, any]); ok {
+						if name, ok := userMap.Get("user"); ok {
+							userMap.Set("user", redactScalarValue([]string{"user"}, name, false, false))
+						}
+					}
+				}
+			}
+		}
+	}
+	if redactNamespaces {
+		if nsVal, ok := op.Get("ns"); ok {
+			if nsStr, ok := nsVal.(string); ok {
+				op.Set("ns", HashName(nsStr))
+			}
+		}
+	}
+}
+
+// redactOperationCommands redacts the command, originatingCommand and planSummary of an operation.
+func redactOperationCommands(op *orderedmap.OrderedMap[string, any], shouldEagerRedact bool) {
+	for _, field := range []string{"command", "originatingCommand"} {
+		if cmd, ok := op.Get(field); ok {
+			if cmdMap, ok := cmd.(*orderedmap.OrderedMap[string, any]); ok {
+				redactCommand(cmdMap, shouldEagerRedact)
+				if redactNamespaces {
+					redactNamespace(cmdMap)
+				}
+			}
+		}
+	}
+	if shouldEagerRedact {
+		if planSummary, ok := op.Get("planSummary"); ok {
+			if psStr, ok := planSummary.(string); ok {
+				op.Set("planSummary", redactFieldNamesFromPlanSummary(psStr))
+			}
+		}
+	}
+}
+
+// redactNetworkLocation replaces the '<host>:<port>' in field like the remote of log entries.
+func redactNetworkLocation(m *orderedmap.OrderedMap[string, any], field string) {
+	if v, ok := m.Get(field); ok {
+		if _, ok := v.(string); ok {
+			reportIPDetected(field)
+			if redactIPs {
+				m.Set(field, "255.255.255.255:65535")
+				reportRedactedValue([]string{field}, nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRedactCurrentOp(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       func()
+		expectedPaths map[string]any
+	}{
+		{
+			name:    "values",
+			options: setOptionsRedactedStrings,
+			expectedPaths: map[string]any{
+				"inprog.0.command.filter.customerEmail":                       "redacted@redacted.com",
+				"inprog.0.command.filter.amount.$gt":                          float64(1000),
+				"inprog.0.lsid.id.$uuid":                                      "0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60",
+				"inprog.0.client":                                             "10.20.30.40:51234",
+				"inprog.0.effectiveUsers.0.user":                              RedactedString,
+				"inprog.0.effectiveUsers.0.db":                                "admin",
+				"inprog.0.planSummary":                                        "IXSCAN { customerEmail: 1 }",
+				"inprog.0.locks.Global":                                       "r",
+				"inprog.0.clientMetadata.driver.name":                         "nodejs",
+				"inprog.1.cursor.originatingCommand.pipeline.0.$match.status": RedactedString,
+				"inprog.1.cursor.originatingCommand.pipeline.1.$group._id":    "$customerId",
+				"inprog.1.effectiveUsers.0.user":                              RedactedString,
+				"inprog.1.ns":                                                 "billing.invoices",
+			},
+		},
+		{
+			name: "field names, namespaces and IPs",
+			options: func() {
+				setOptionsRedactedStringsAndNamespaces()
+				SetEagerRedactionPaths([]string{"billing.*"})
+				SetRedactIPs(true)
+			},
+			expectedPaths: map[string]any{
+				"inprog.0.ns":           HashName("billing.invoices"),
+				"inprog.0.command.find": HashName("invoices"),
+				"inprog.0.command.filter." + HashName("customerEmail"): "redacted@redacted.com",
+				"inprog.0.planSummary":                                 "IXSCAN { " + HashName("customerEmail") + ": 1 }",
+				"inprog.0.client":                                      "255.255.255.255:65535",
+				"inprog.0.host":                                        "255.255.255.255:65535",
+				"inprog.1.client_s":                                    "255.255.255.255:65535",
+				"inprog.1.clientMetadata.mongos.client":                "255.255.255.255:65535",
+				"inprog.1.clientMetadata.mongos.host":                  "255.255.255.255:65535",
+				"inprog.1.cursor.planSummary":                          "IXSCAN { " + HashName("status") + ": 1 }",
+				"inprog.1.cursor.originatingCommand.aggregate":         HashName("invoices"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options()
+			defer setOptionsRedactedStrings()
+			doc, err := RedactCurrentOp(getFixtureContent(t, "test_fixtures/currentop_inprog.json"))
+			if err != nil {
+				t.Fatalf("RedactCurrentOp returned an unexpected error: %v", err)
+			}
+			for path, want := range tt.expectedPaths {
+				if got := getJSONPath(doc, path); !valuesEqual(got, want) {
+					t.Errorf("expected %s to be %s, got %s", path, getTypeInfo(want), getTypeInfo(got))
+				}
+			}
+		})
+	}
+}
+
+// TestRedactCurrentOp_MatchesLog redacts the same command as an operation and in a slow query log
+// entry, which must give the same result.
+func TestRedactCurrentOp_MatchesLog(t *testing.T) {
+	setOptionsRedactedStringsAndNamespaces()
+	SetEagerRedactionPaths([]string{"billing.*"})
+	SetRedactIPs(true)
+	defer setOptionsRedactedStrings()
+
+	command := `{"find":"invoices","filter":{"customerEmail":"jane@example.com"},"lsid":{"id":{"$uuid":"0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60"}},"$db":"billing"}`
+	op, err := RedactCurrentOp(`{"op":"query","ns":"billing.invoices","client":"10.20.30.40:51234","command":` + command + `,"planSummary":"IXSCAN { customerEmail: 1 }"}`)
+	if err != nil {
+		t.Fatalf("RedactCurrentOp returned an unexpected error: %v", err)
+	}
+	entry, err := RedactMongoLog(`{"t":{"$date":"2025-06-22T10:15:04.123+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1842","msg":"Slow query","attr":{"type":"command","ns":"billing.invoices","command":` + command + `,"planSummary":"IXSCAN { customerEmail: 1 }","remote":"10.20.30.40:51234"}}`)
+	if err != nil {
+		t.Fatalf("RedactMongoLog returned an unexpected error: %v", err)
+	}
+	for opPath, logPath := range map[string]string{"command": "attr.command", "ns": "attr.ns", "planSummary": "attr.planSummary", "client": "attr.remote"} {
+		got, err := marshalOrderedValue(getJSONPath(op, opPath))
+		if err != nil {
+			t.Fatalf("Failed to marshal %s: %v", opPath, err)
+		}
+		want, err := marshalOrderedValue(getJSONPath(entry, logPath))
+		if err != nil {
+			t.Fatalf("Failed to marshal %s: %v", logPath, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("expected %s to match %s of the log entry.\nGot:\n%s\nWant:\n%s", opPath, logPath, got, want)
+		}
+	}
+}
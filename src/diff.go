@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// ValueChange is a difference between an original log entry and its redacted version.
+type ValueChange struct {
+	Path string
+	Old  any
+	New  any
+	// FieldName is set when the field name at Path changed, rather than its value.
+	FieldName bool
+	// Removed is set when the field at Path is missing from the redacted entry.
+	Removed bool
+}
+
+// EntryDiff lists the changes redaction made to a single log entry.
+type EntryDiff struct {
+	Line      int
+	Component string
+	Namespace string
+	Changes   []ValueChange
+}
+
+// LineRange is an inclusive range of line numbers. An End of 0 means there's no upper bound.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// DiffOptions selects the entries shown by the diff command and how they're rendered.
+type DiffOptions struct {
+	Components []string
+	Namespaces []string
+	Lines      []LineRange
+	Color      bool
+}
+
+const (
+	colorReset = "\033[0m"
+	colorBold  = "\033[1m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+// ParseLineRanges parses a comma-separated list of line numbers and ranges, such as '5,10-20,100-'.
+func ParseLineRanges(spec string) ([]LineRange, error) {
+	var ranges []LineRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		startStr, endStr, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(startStr)
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("invalid line range %q: lines start at 1", part)
+		}
+		end := start
+		if isRange {
+			end = 0
+			if endStr != "" {
+				end, err = strconv.Atoi(endStr)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("invalid line range %q", part)
+				}
+			}
+		}
+		ranges = append(ranges, LineRange{Start: start, End: end})
+	}
+	return ranges, nil
+}
+
+func (r LineRange) contains(line int) bool {
+	return line >= r.Start && (r.End == 0 || line <= r.End)
+}
+
+func (o DiffOptions) matchesLine(line int) bool {
+	if len(o.Lines) == 0 {
+		return true
+	}
+	for _, r := range o.Lines {
+		if r.contains(line) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o DiffOptions) matchesEntry(component, namespace string) bool {
+	if len(o.Components) > 0 && !slices.ContainsFunc(o.Components, func(c string) bool { return strings.EqualFold(c, component) }) {
+		return false
+	}
+	if len(o.Namespaces) > 0 && !slices.ContainsFunc(o.Namespaces, func(p string) bool { return MatchesNamespace(p, namespace) }) {
+		return false
+	}
+	return true
+}
+
+// DiffValues walks an original value and its redacted version side by side, and returns every
+// field name and scalar value that differs. Fields are matched by key, or by their hashed key when
+// field names are redacted, and a field missing from the redacted value is reported as removed.
+// Redaction keeps the length of arrays, so array items are compared by position.
+func DiffValues(original, redacted any) []ValueChange {
+	var changes []ValueChange
+	diffValues("", original, redacted, &changes)
+	return changes
+}
+
+func diffValues(path string, original, redacted any, changes *[]ValueChange) {
+	switch o := original.(type) {
+	case *orderedmap.OrderedMap[string, any]:
+		r, ok := redacted.(*orderedmap.OrderedMap[string, any])
+		if !ok {
+			*changes = append(*changes, ValueChange{Path: path, Old: original, New: redacted})
+			return
+		}
+		for oEl := o.Front(); oEl != nil; oEl = oEl.Next() {
+			elPath := joinDiffPath(path, oEl.Key)
+			if value, ok := r.Get(oEl.Key); ok {
+				diffValues(elPath, oEl.Value, value, changes)
+				continue
+			}
+			key := hashedName(oEl.Key)
+			if value, ok := r.Get(key); ok {
+				*changes = append(*changes, ValueChange{Path: elPath, Old: oEl.Key, New: key, FieldName: true})
+				diffValues(elPath, oEl.Value, value, changes)
+				continue
+			}
+			*changes = append(*changes, ValueChange{Path: elPath, Old: oEl.Value, Removed: true})
+		}
+	case []any:
+		r, ok := redacted.([]any)
+		if !ok {
+			*changes = append(*changes, ValueChange{Path: path, Old: original, New: redacted})
+			return
+		}
+		for i := 0; i < len(o) && i < len(r); i++ {
+			diffValues(joinDiffPath(path, strconv.Itoa(i)), o[i], r[i], changes)
+		}
+	default:
+		if fmt.Sprint(original) != fmt.Sprint(redacted) {
+			*changes = append(*changes, ValueChange{Path: path, Old: original, New: redacted})
+		}
+	}
+}
+
+func joinDiffPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// DiffMongoLog redacts a single log line in memory and returns the changes made to it.
+func DiffMongoLog(line string, lineNumber int) (*EntryDiff, error) {
+	original, err := UnmarshalOrdered([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+	SetCurrentLineNumber(lineNumber)
+	redacted, err := redactEntry(line)
+	if err != nil {
+		return nil, err
+	}
+	return &EntryDiff{
+		Line:      lineNumber,
+		Component: currentComponent,
+		Namespace: currentNamespace,
+		Changes:   DiffValues(original, redacted),
+	}, nil
+}
+
+// Write renders the diff, one changed field per group of lines.
+func (d *EntryDiff) Write(w io.Writer, color bool) {
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + colorReset
+	}
+	header := fmt.Sprintf("line %d", d.Line)
+	if d.Component != "" {
+		header += " " + d.Component
+	}
+	if d.Namespace != "" {
+		header += " " + d.Namespace
+	}
+	fmt.Fprintln(w, paint(colorBold, "@@ "+header+" @@"))
+	for _, change := range d.Changes {
+		path := change.Path
+		if change.FieldName {
+			path += " (field name)"
+		}
+		if change.Removed {
+			path += " (removed)"
+		}
+		fmt.Fprintln(w, paint(colorCyan, "  "+path))
+		fmt.Fprintln(w, paint(colorRed, "  - "+formatDiffValue(change.Old)))
+		if !change.Removed {
+			fmt.Fprintln(w, paint(colorGreen, "  + "+formatDiffValue(change.New)))
+		}
+	}
+}
+
+func formatDiffValue(v any) string {
+	switch val := v.(type) {
+	case *orderedmap.OrderedMap[string, any]:
+		out, err := MarshalOrdered(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(out)
+	case []any:
+		items := make([]string, len(val))
+		for i, item := range val {
+			items[i] = formatDiffValue(item)
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	default:
+		out, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(out)
+	}
+}
+
+func diffMongoLogStream(s *logStream, w io.Writer, opts DiffOptions) (int, error) {
+	changed := 0
+	err := forEachLogEntry(s, func(line string, lineNumber int) {
+		if line == "" || !opts.matchesLine(lineNumber) {
+			return
+		}
+		diff, err := DiffMongoLog(line, lineNumber)
+		if err != nil || len(diff.Changes) == 0 || !opts.matchesEntry(diff.Component, diff.Namespace) {
+			return
+		}
+		diff.Write(w, opts.Color)
+		changed++
+	})
+	SetCurrentLineNumber(0)
+	return changed, err
+}
+
+// DiffMongoLogFile redacts a MongoDB log file in memory and writes a structural diff of every
+// changed entry that matches opts to w. It returns the number of changed entries written.
+func DiffMongoLogFile(fileReader FileReader, filePath string, w io.Writer, opts DiffOptions) (int, error) {
+	s, err := openLogFileInput(fileReader, filePath, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	return diffMongoLogStream(s, w, opts)
+}
+
+// DiffMongoLogFromReader writes a structural diff of log lines read from any io.Reader (such as stdin).
+func DiffMongoLogFromReader(r io.Reader, w io.Writer, opts DiffOptions) (int, error) {
+	s, err := openLogInput(r)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	return diffMongoLogStream(s, w, opts)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLineRanges(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected []LineRange
+		wantErr  bool
+	}{
+		{spec: "", expected: nil},
+		{spec: "5", expected: []LineRange{{Start: 5, End: 5}}},
+		{spec: "5, 10-20,100-", expected: []LineRange{{Start: 5, End: 5}, {Start: 10, End: 20}, {Start: 100, End: 0}}},
+		{spec: "0", wantErr: true},
+		{spec: "20-10", wantErr: true},
+		{spec: "a-b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseLineRanges(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error for %q, but got %v", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLineRanges returned an unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDiffMongoLog(t *testing.T) {
+	setOptionsRedactedStringsWithEagerRedaction()
+	defer setOptionsRedactedStrings()
+
+	diff, err := DiffMongoLog(getFixtureContent(t, "test_fixtures/simple_find.json"), 3)
+	if err != nil {
+		t.Fatalf("DiffMongoLog returned an unexpected error: %v", err)
+	}
+	if diff.Line != 3 || diff.Component != "COMMAND" || diff.Namespace != "my_db.my_coll" {
+		t.Errorf("Expected line 3, component COMMAND and namespace my_db.my_coll, but got %d, %q and %q", diff.Line, diff.Component, diff.Namespace)
+	}
+	expected := []ValueChange{
+		{Path: "attr.command.filter.foo", Old: "foo", New: HashName("foo"), FieldName: true},
+		{Path: "attr.command.filter.foo", Old: "simple string", New: RedactedString},
+	}
+	if len(diff.Changes) < len(expected) || !reflect.DeepEqual(diff.Changes[:len(expected)], expected) {
+		t.Errorf("Expected changes to start with %v, but got %v", expected, diff.Changes)
+	}
+}
+
+func TestDiffMongoLogFromReader_Filters(t *testing.T) {
+	setOptionsRedactedStrings()
+	input := strings.Join([]string{
+		getFixtureContent(t, "test_fixtures/simple_find.json"),
+		getFixtureContent(t, "test_fixtures/connection_accepted.json"),
+		getFixtureContent(t, "test_fixtures/find_with_emails.json"),
+	}, "\n")
+
+	tests := []struct {
+		name          string
+		opts          DiffOptions
+		expectedLines []string
+	}{
+		{name: "no filters", opts: DiffOptions{}, expectedLines: []string{"line 1", "line 3"}},
+		{name: "component", opts: DiffOptions{Components: []string{"network"}}, expectedLines: nil},
+		{name: "namespace", opts: DiffOptions{Namespaces: []string{"my_db"}}, expectedLines: []string{"line 1", "line 3"}},
+		{name: "other namespace", opts: DiffOptions{Namespaces: []string{"other.*"}}, expectedLines: nil},
+		{name: "lines", opts: DiffOptions{Lines: []LineRange{{Start: 2, End: 0}}}, expectedLines: []string{"line 3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			changed, err := DiffMongoLogFromReader(strings.NewReader(input), &out, tt.opts)
+			if err != nil {
+				t.Fatalf("DiffMongoLogFromReader returned an unexpected error: %v", err)
+			}
+			if changed != len(tt.expectedLines) {
+				t.Errorf("Expected %d changed entries, but got %d:\n%s", len(tt.expectedLines), changed, out.String())
+			}
+			for _, line := range tt.expectedLines {
+				if !strings.Contains(out.String(), "@@ "+line+" ") {
+					t.Errorf("Expected a diff for %s, but got:\n%s", line, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestEntryDiff_Write(t *testing.T) {
+	diff := &EntryDiff{
+		Line:      7,
+		Component: "COMMAND",
+		Namespace: "db.coll",
+		Changes:   []ValueChange{{Path: "attr.command.filter.n", Old: "x", New: RedactedString}},
+	}
+
+	var plain bytes.Buffer
+	diff.Write(&plain, false)
+	expected := "@@ line 7 COMMAND db.coll @@\n  attr.command.filter.n\n  - \"x\"\n  + \"REDACTED\"\n"
+	if plain.String() != expected {
+		t.Errorf("Expected:\n%s\nbut got:\n%s", expected, plain.String())
+	}
+
+	removed := &EntryDiff{Line: 7, Changes: []ValueChange{{Path: "attr.command.filter.deletedAt", Removed: true}}}
+	var removedOut bytes.Buffer
+	removed.Write(&removedOut, false)
+	expected = "@@ line 7 @@\n  attr.command.filter.deletedAt (removed)\n  - null\n"
+	if removedOut.String() != expected {
+		t.Errorf("Expected:\n%s\nbut got:\n%s", expected, removedOut.String())
+	}
+
+	var colored bytes.Buffer
+	diff.Write(&colored, true)
+	if !strings.Contains(colored.String(), colorRed+"  - \"x\""+colorReset) {
+		t.Errorf("Expected the old value in red, but got %q", colored.String())
+	}
+}
+
+func TestDiffMongoLog_MatchesFieldsByKey(t *testing.T) {
+	line := `{"t":{"$date":"2024-01-01T00:00:00.000Z"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"my_db.my_coll","command":{"find":"my_coll","filter":{"deletedAt":null,"email":"a@b.com","name":"bob"}}}}`
+
+	tests := []struct {
+		name     string
+		setup    func()
+		expected []ValueChange
+	}{
+		{
+			name:  "null-valued field",
+			setup: setOptionsRedactedStrings,
+			expected: []ValueChange{
+				{Path: "attr.command.filter.deletedAt", Old: nil, Removed: true},
+				{Path: "attr.command.filter.email", Old: "a@b.com", New: "redacted@redacted.com"},
+				{Path: "attr.command.filter.name", Old: "bob", New: RedactedString},
+			},
+		},
+		{
+			name:  "redacted field names",
+			setup: setOptionsRedactedStringsWithEagerRedaction,
+			expected: []ValueChange{
+				{Path: "attr.command.filter.deletedAt", Old: nil, Removed: true},
+				{Path: "attr.command.filter.email", Old: "email", New: hashedName("email"), FieldName: true},
+				{Path: "attr.command.filter.email", Old: "a@b.com", New: "redacted@redacted.com"},
+				{Path: "attr.command.filter.name", Old: "name", New: hashedName("name"), FieldName: true},
+				{Path: "attr.command.filter.name", Old: "bob", New: RedactedString},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup()
+			defer setOptionsRedactedStrings()
+
+			diff, err := DiffMongoLog(line, 1)
+			if err != nil {
+				t.Fatalf("DiffMongoLog returned an unexpected error: %v", err)
+			}
+			var filterChanges []ValueChange
+			for _, change := range diff.Changes {
+				if strings.HasPrefix(change.Path, "attr.command.filter.") {
+					filterChanges = append(filterChanges, change)
+				}
+			}
+			if !reflect.DeepEqual(filterChanges, tt.expected) {
+				t.Errorf("Expected %v, but got %v", tt.expected, filterChanges)
+			}
+		})
+	}
+}
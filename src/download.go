@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDownloadConcurrency = 4
+	defaultMaxRetries          = 5
+	defaultRetryBaseDelay      = time.Second
+	defaultMaxRetryDelay       = time.Minute
+)
+
+// LogDownloadFailure is a log that couldn't be downloaded from a host.
+type LogDownloadFailure struct {
+	Host    string
+	LogType AtlasLogType
+	Err     error
+}
+
+// PartialDownloadError lists the logs that couldn't be downloaded, when the others were kept.
+type PartialDownloadError struct {
+	Failures []LogDownloadFailure
+}
+
+func (e *PartialDownloadError) Error() string {
+	lines := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		lines[i] = fmt.Sprintf("  %s logs for host %s: %v", f.LogType, f.Host, f.Err)
+	}
+	return fmt.Sprintf("failed to download %d log(s):\n%s", len(e.Failures), strings.Join(lines, "\n"))
+}
+
+// downloadJob is a log to download from a host.
+type downloadJob struct {
+	cluster string
+	host    string
+	role    HostRole
+	logType AtlasLogType
+}
+
+// downloadLogs downloads the logs of the jobs, up to c.Concurrency at a time, and returns them in
+// the order of the jobs. Unless c.AllowPartial is set, the first failure stops the other downloads
+// and deletes the logs already downloaded; otherwise the downloaded logs are returned along with a
+// PartialDownloadError, or an error if none could be downloaded.
+func (c *AtlasClient) downloadLogs(ctx context.Context, jobs []downloadJob, download func(ctx context.Context, job downloadJob) (string, error)) ([]AtlasLogFile, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultDownloadConcurrency
+	}
+
+	paths := make([]string, len(jobs))
+	errs := make([]error, len(jobs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return
+			}
+			paths[i], errs[i] = download(ctx, job)
+			if errs[i] != nil && !c.AllowPartial {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var logFiles []AtlasLogFile
+	var failures []LogDownloadFailure
+	var firstErr error
+	for i, job := range jobs {
+		if errs[i] != nil {
+			failures = append(failures, LogDownloadFailure{Host: job.host, LogType: job.logType, Err: errs[i]})
+			// Downloads stopped after the first failure fail with context.Canceled.
+			if firstErr == nil && !errors.Is(errs[i], context.Canceled) {
+				firstErr = fmt.Errorf("failed to download %s logs for host %s: %w", job.logType, job.host, errs[i])
+			}
+			continue
+		}
+		logFiles = append(logFiles, AtlasLogFile{Cluster: job.cluster, Host: job.host, Role: job.role, LogType: job.logType, Path: paths[i]})
+	}
+	if len(failures) == 0 {
+		return logFiles, nil
+	}
+	if !c.AllowPartial || len(logFiles) == 0 {
+		_ = c.DeleteClusterLogs(ctx, logFiles)
+		if firstErr == nil {
+			firstErr = failures[0].Err
+		}
+		if c.AllowPartial {
+			return nil, &PartialDownloadError{Failures: failures}
+		}
+		return nil, firstErr
+	}
+	return logFiles, &PartialDownloadError{Failures: failures}
+}
+
+// retryableStatusError is a response worth retrying: the API is rate limiting the requests (429),
+// or failed on its side (5xx).
+type retryableStatusError struct {
+	status     int
+	body       string
+	retryAfter time.Duration
+}
+
+func (e *retryableStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.status, e.body)
+}
+
+// downloadToFile downloads url to file, retrying up to c.MaxRetries times on network errors and
+// retryable statuses, with exponential backoff or after the delay of their Retry-After header. A
+// download interrupted midway is resumed with a range request; if the server doesn't honor it, the
+// log is downloaded again from the start.
+func (c *AtlasClient) downloadToFile(ctx context.Context, client *http.Client, url string, header http.Header, file *os.File) error {
+	var written int64
+	retries := 0
+	return c.retry(ctx, url, &retries, func() error {
+		n, err := c.downloadAttempt(ctx, client, url, header, file, written)
+		written = n
+		return err
+	})
+}
+
+// retry calls attempt until it succeeds, fails with a permanentDownloadError, or *retries reaches
+// c.MaxRetries, waiting between attempts with exponential backoff or for the delay of a
+// retryableStatusError. *retries counts the attempts retried, so that it can be shared by the
+// requests of a download.
+func (c *AtlasClient) retry(ctx context.Context, url string, retries *int, attempt func() error) error {
+	maxRetries := max(c.MaxRetries, 0)
+	for {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		var statusErr *retryableStatusError
+		isStatusErr := errors.As(err, &statusErr)
+		var permanent *permanentDownloadError
+		if errors.As(err, &permanent) || ctx.Err() != nil || *retries >= maxRetries {
+			return err
+		}
+		delay := c.retryDelay(*retries)
+		if isStatusErr && statusErr.retryAfter > 0 {
+			delay = min(statusErr.retryAfter, c.maxRetryDelay())
+		}
+		*retries++
+		fmt.Fprintf(os.Stderr, "Retrying %s in %s after: %v\n", url, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// permanentDownloadError is a failure that retrying won't fix, such as a missing log.
+type permanentDownloadError struct {
+	err error
+}
+
+func (e *permanentDownloadError) Error() string { return e.err.Error() }
+func (e *permanentDownloadError) Unwrap() error { return e.err }
+
+// downloadAttempt requests url, from the byte at offset written if it's not 0, and appends the
+// response to file. It returns the number of bytes of the log in file.
+func (c *AtlasClient) downloadAttempt(ctx context.Context, client *http.Client, url string, header http.Header, file *os.File, written int64) (int64, error) {
+	resp, err := c.requestLog(ctx, client, url, header, written)
+	if err != nil {
+		return written, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && written > 0 {
+		// The whole log, again, since the range wasn't honored.
+		if err := file.Truncate(0); err != nil {
+			return 0, &permanentDownloadError{fmt.Errorf("failed to truncate temp file: %w", err)}
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return 0, &permanentDownloadError{fmt.Errorf("failed to truncate temp file: %w", err)}
+		}
+		written = 0
+	}
+
+	n, err := io.Copy(file, resp.Body)
+	written += n
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return written, &permanentDownloadError{fmt.Errorf("failed to write log to temp file: %w", err)}
+		}
+		return written, fmt.Errorf("download interrupted after %d bytes: %w", written, err)
+	}
+	return written, nil
+}
+
+// requestLog requests url, from the byte at offset if it's not 0. It returns the response if it's
+// the whole log (200) or the rest of it from offset (206), and otherwise a retryableStatusError or
+// a permanentDownloadError.
+func (c *AtlasClient) requestLog(ctx context.Context, client *http.Client, url string, header http.Header, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &permanentDownloadError{fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header = header.Clone()
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)):
+		// Resumed where the previous attempt stopped.
+		return resp, nil
+	case resp.StatusCode == http.StatusOK:
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, &retryableStatusError{status: resp.StatusCode, body: string(body), retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return nil, &permanentDownloadError{fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))}
+}
+
+func (c *AtlasClient) retryDelay(attempt int) time.Duration {
+	delay := c.RetryBaseDelay
+	if delay <= 0 {
+		delay = defaultRetryBaseDelay
+	}
+	for i := 0; i < attempt && delay < c.maxRetryDelay(); i++ {
+		delay *= 2
+	}
+	return min(delay, c.maxRetryDelay())
+}
+
+func (c *AtlasClient) maxRetryDelay() time.Duration {
+	if c.MaxRetryDelay <= 0 {
+		return defaultMaxRetryDelay
+	}
+	return c.MaxRetryDelay
+}
+
+// parseRetryAfter returns the delay of a Retry-After header, given in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
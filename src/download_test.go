@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestDownloadClient(server *httptest.Server) *AtlasClient {
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+	client.RetryBaseDelay = time.Millisecond
+	client.MaxRetryDelay = 10 * time.Millisecond
+	return client
+}
+
+// downloadWithClient downloads the root of the server to a temporary file, and returns its content.
+func downloadWithClient(t *testing.T, client *AtlasClient) (string, error) {
+	t.Helper()
+	file, err := os.CreateTemp(t.TempDir(), "download-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer file.Close()
+	err = client.downloadToFile(context.Background(), client.HTTPClient, client.BaseURL+"/log.gz", http.Header{}, file)
+	content, _ := os.ReadFile(file.Name())
+	return string(content), err
+}
+
+func TestDownloadToFile_Retries(t *testing.T) {
+	const log = "0123456789abcdefghij"
+	tests := []struct {
+		name         string
+		responses    []func(w http.ResponseWriter, r *http.Request)
+		wantErr      string
+		wantRequests int
+	}{
+		{
+			name: "server errors and rate limiting",
+			responses: []func(w http.ResponseWriter, r *http.Request){
+				func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				},
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Retry-After", "0")
+					http.Error(w, "slow down", http.StatusTooManyRequests)
+				},
+				func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(log)) },
+			},
+			wantRequests: 3,
+		},
+		{
+			name: "missing log",
+			responses: []func(w http.ResponseWriter, r *http.Request){
+				func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) },
+			},
+			wantErr:      "unexpected status 404",
+			wantRequests: 1,
+		},
+		{
+			name: "retries exhausted",
+			responses: []func(w http.ResponseWriter, r *http.Request){
+				func(w http.ResponseWriter, r *http.Request) { http.Error(w, "bad gateway", http.StatusBadGateway) },
+			},
+			wantErr:      "unexpected status 502",
+			wantRequests: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1))
+				tt.responses[min(n, len(tt.responses))-1](w, r)
+			}))
+			defer server.Close()
+			client := newTestDownloadClient(server)
+			client.MaxRetries = 2
+
+			content, err := downloadWithClient(t, client)
+			if tt.wantErr == "" && (err != nil || content != log) {
+				t.Errorf("downloadToFile() = %q, %v, want %q", content, err, log)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("downloadToFile() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if int(requests.Load()) != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, requests.Load())
+			}
+		})
+	}
+}
+
+// interruptedHandler sends the first half of log and drops the connection on the first request.
+// Later requests are answered by resume.
+func interruptedHandler(t *testing.T, log string, resume func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	var requests atomic.Int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			resume(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(log)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(log[:len(log)/2]))
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Failed to hijack the connection: %v", err)
+			return
+		}
+		conn.Close()
+	}
+}
+
+func TestDownloadToFile_Resume(t *testing.T) {
+	const log = "0123456789abcdefghij"
+
+	t.Run("range honored", func(t *testing.T) {
+		var gotRange string
+		server := httptest.NewServer(interruptedHandler(t, log, func(w http.ResponseWriter, r *http.Request) {
+			gotRange = r.Header.Get("Range")
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", len(log)/2, len(log)-1, len(log)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(log[len(log)/2:]))
+		}))
+		defer server.Close()
+
+		content, err := downloadWithClient(t, newTestDownloadClient(server))
+		if err != nil || content != log {
+			t.Errorf("downloadToFile() = %q, %v, want %q", content, err, log)
+		}
+		if want := fmt.Sprintf("bytes=%d-", len(log)/2); gotRange != want {
+			t.Errorf("Expected the download to resume with Range %q, got %q", want, gotRange)
+		}
+	})
+
+	t.Run("range ignored", func(t *testing.T) {
+		server := httptest.NewServer(interruptedHandler(t, log, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(log))
+		}))
+		defer server.Close()
+
+		content, err := downloadWithClient(t, newTestDownloadClient(server))
+		if err != nil || content != log {
+			t.Errorf("downloadToFile() = %q, %v, want %q", content, err, log)
+		}
+	})
+}
+
+func TestDownloadLogs_Concurrency(t *testing.T) {
+	client := NewAtlasClient(nil)
+	client.Concurrency = 2
+
+	var running, maxRunning atomic.Int32
+	var jobs []downloadJob
+	for i := range 6 {
+		jobs = append(jobs, downloadJob{host: fmt.Sprintf("host%d", i), logType: AtlasLogMongod})
+	}
+	dir := t.TempDir()
+	logFiles, err := client.downloadLogs(context.Background(), jobs, func(ctx context.Context, job downloadJob) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		path := dir + "/" + job.host
+		return path, os.WriteFile(path, []byte(job.host), 0o600)
+	})
+	if err != nil {
+		t.Fatalf("downloadLogs returned an unexpected error: %v", err)
+	}
+	if maxRunning.Load() != 2 {
+		t.Errorf("Expected 2 downloads at a time, got %d", maxRunning.Load())
+	}
+	for i, logFile := range logFiles {
+		if logFile.Host != jobs[i].host {
+			t.Errorf("Expected the logs in the order of the jobs, got %s at %d", logFile.Host, i)
+		}
+	}
+}
+
+func TestDownloadLogs_Failures(t *testing.T) {
+	jobs := []downloadJob{{host: "host0", logType: AtlasLogMongod}, {host: "host1", logType: AtlasLogMongod}, {host: "host2", logType: AtlasLogMongod}}
+	download := func(dir string, failing map[string]bool) func(ctx context.Context, job downloadJob) (string, error) {
+		var mu sync.Mutex
+		return func(ctx context.Context, job downloadJob) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if failing[job.host] {
+				return "", errors.New("unexpected status 503: unavailable")
+			}
+			path := dir + "/" + job.host
+			return path, os.WriteFile(path, []byte(job.host), 0o600)
+		}
+	}
+
+	t.Run("partial success", func(t *testing.T) {
+		client := NewAtlasClient(nil)
+		client.AllowPartial = true
+		logFiles, err := client.downloadLogs(context.Background(), jobs, download(t.TempDir(), map[string]bool{"host1": true}))
+		var partialErr *PartialDownloadError
+		if !errors.As(err, &partialErr) || len(partialErr.Failures) != 1 || partialErr.Failures[0].Host != "host1" {
+			t.Fatalf("Expected host1 to be reported as failed, got %v", err)
+		}
+		if len(logFiles) != 2 || logFiles[0].Host != "host0" || logFiles[1].Host != "host2" {
+			t.Errorf("Expected the logs of host0 and host2, got %+v", logFiles)
+		}
+		if !strings.Contains(err.Error(), "mongodb logs for host host1: unexpected status 503") {
+			t.Errorf("Unexpected error message: %v", err)
+		}
+	})
+
+	t.Run("partial success without logs", func(t *testing.T) {
+		client := NewAtlasClient(nil)
+		client.AllowPartial = true
+		logFiles, err := client.downloadLogs(context.Background(), jobs, download(t.TempDir(), map[string]bool{"host0": true, "host1": true, "host2": true}))
+		if logFiles != nil || err == nil {
+			t.Errorf("Expected an error and no logs, got %+v, %v", logFiles, err)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		client := NewAtlasClient(nil)
+		client.Concurrency = 1
+		dir := t.TempDir()
+		logFiles, err := client.downloadLogs(context.Background(), jobs, download(dir, map[string]bool{"host1": true}))
+		if logFiles != nil || err == nil || !strings.Contains(err.Error(), "failed to download mongodb logs for host host1") {
+			t.Fatalf("Expected host1 to fail the download, got %+v, %v", logFiles, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("Expected the downloaded logs to be deleted, got %d", len(entries))
+		}
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("7"); got != 7*time.Second {
+		t.Errorf("parseRetryAfter(7) = %s", got)
+	}
+	if got := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter(date) = %s", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("parseRetryAfter(soon) = %s", got)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// RedactExplain redacts the output of explain() for find and aggregate, including the explain of
+// sharded clusters. The filters, index bounds and slot-based plans of its query plans are redacted,
+// along with the command that was explained and the stages of explained aggregations.
+func RedactExplain(jsonStr string) (*orderedmap.OrderedMap[string, any], error) {
+	doc, err := UnmarshalOrdered([]byte(jsonStr))
+	if err != nil {
+		return nil, err
+	}
+	ns := explainNamespace(doc)
+	SetCurrentComponent("")
+	SetCurrentNamespace(ns)
+	return redactQueryPlan(doc, isEagerRedactionNamespace(ns)), nil
+}
+
+// explainNamespace returns the namespace that was explained, from the first query planner that
+// names it, or from the explained command.
+func explainNamespace(doc *orderedmap.OrderedMap[string, any]) string {
+	if ns := findExplainNamespace(doc); ns != "" {
+		return ns
+	}
+	cmd, ok := doc.Get("command")
+	if !ok {
+		return ""
+	}
+	cmdMap, ok := cmd.(*orderedmap.OrderedMap[string, any])
+	if !ok {
+		return ""
+	}
+	db, _ := cmdMap.Get("$db")
+	dbStr, _ := db.(string)
+	for _, field := range []string{"find", "aggregate", "count", "distinct"} {
+		if coll, ok := cmdMap.Get(field); ok {
+			if collStr, ok := coll.(string); ok && dbStr != "" {
+				return dbStr + "." + collStr
+			}
+		}
+	}
+	return ""
+}
+
+func findExplainNamespace(v any) string {
+	switch v := v.(type) {
+	case *orderedmap.OrderedMap[string, any]:
+		if ns, ok := v.Get("namespace"); ok {
+			if nsStr, ok := ns.(string); ok {
+				return nsStr
+			}
+		}
+		for el := v.Front(); el != nil; el = el.Next() {
+			if ns := findExplainNamespace(el.Value); ns != "" {
+				return ns
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if ns := findExplainNamespace(item); ns != "" {
+				return ns
+			}
+		}
+	}
+	return ""
+}
+
+// slotBasedPlanLiteralRegex matches the literals of a slot-based plan: key strings, e.g.,
+// 'KS(3C6A6F686E0004)', and strings, along with the function reading the field they name, if any.
+// Strings prefixed with '@' are the UUIDs of collections and the names of indexes.
+var slotBasedPlanLiteralRegex = regexp.MustCompile(`KS\(([0-9A-Fa-f]*)\)|(\b(?:getField|getFieldOrElement|getElement)\(s\d+, )?(@)?"((?:[^"\\]|\\.)*)"`)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// redactSlotBasedPlan redacts the plan of the slot-based execution engine (SBE), which is printed as
+// text in the slots and stages of the slotBasedPlan section of an explain.
+func redactSlotBasedPlan(plan *orderedmap.OrderedMap[string, any], redactFieldNames bool) {
+	for el := plan.Front(); el != nil; el = el.Next() {
+		if s, ok := el.Value.(string); ok {
+			plan.Set(el.Key, redactSlotBasedPlanString(s, redactFieldNames))
+		}
+	}
+}
+
+func redactSlotBasedPlanString(s string, redactFieldNames bool) string {
+	return slotBasedPlanLiteralRegex.ReplaceAllStringFunc(s, func(literal string) string {
+		m := slotBasedPlanLiteralRegex.FindStringSubmatch(literal)
+		if strings.HasPrefix(literal, "KS(") {
+			return "KS(" + redactString(m[1], redactedString) + ")"
+		}
+		fieldAccess, isName, value := m[2], m[3] != "", m[4]
+		switch {
+		case isName:
+			if redactFieldNames && !uuidRegex.MatchString(value) {
+				value = HashName(value)
+			}
+			return `@"` + value + `"`
+		case fieldAccess != "":
+			if redactFieldNames {
+				value = HashName(value)
+			}
+			return fieldAccess + `"` + value + `"`
+		}
+		value = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value)
+		return quoteIndexBound(redactScalarValue([]string{"slotBasedPlan"}, value, false, false))
+	})
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRedactExplain(t *testing.T) {
+	tests := []struct {
+		name          string
+		fixture       string
+		options       func()
+		expectedPaths map[string]any
+	}{
+		{
+			name:    "find with a slot-based plan",
+			fixture: "explain_find.json",
+			options: setOptionsRedactedStrings,
+			expectedPaths: map[string]any{
+				"queryPlanner.parsedQuery.$and.0.ssn.$eq":                       RedactedString,
+				"queryPlanner.parsedQuery.$and.1.email.$eq":                     "redacted@redacted.com",
+				"queryPlanner.winningPlan.queryPlan.filter.email.$eq":           "redacted@redacted.com",
+				"queryPlanner.winningPlan.queryPlan.inputStage.indexName":       "ssn_1",
+				"queryPlanner.winningPlan.queryPlan.inputStage.indexBounds.ssn": []any{`["REDACTED", "REDACTED"]`},
+				"queryPlanner.winningPlan.slotBasedPlan.slots":                  "$$RESULT=s11 env: { s3 = Nothing (SEARCH_META), s2 = KS(REDACTED) }",
+				"queryPlanner.rejectedPlans.0.filter.ssn.$eq":                   RedactedString,
+				"queryPlanner.rejectedPlans.0.inputStage.indexBounds.email":     []any{`["redacted@redacted.com", "redacted@redacted.com"]`},
+				"executionStats.executionStages.filter":                         `traverseF(s5, lambda(l1.0) { ((move(l1.0) == "redacted@redacted.com") ?: false) }, false) `,
+				"executionStats.nReturned":                                      float64(1),
+				"command.filter.ssn":                                            RedactedString,
+				"queryPlanner.namespace":                                        "hr.employees",
+			},
+		},
+		{
+			name:    "find with field names and namespaces",
+			fixture: "explain_find.json",
+			options: func() {
+				setOptionsRedactedStringsAndNamespaces()
+				SetEagerRedactionPaths([]string{"hr.*"})
+			},
+			expectedPaths: map[string]any{
+				"queryPlanner.namespace": HashName("hr.employees"),
+				"queryPlanner.winningPlan.queryPlan.filter." + HashName("email") + ".$eq":      "redacted@redacted.com",
+				"queryPlanner.winningPlan.queryPlan.inputStage.indexName":                      HashName("ssn_1"),
+				"queryPlanner.winningPlan.queryPlan.inputStage.keyPattern." + HashName("ssn"):  float64(1),
+				"queryPlanner.winningPlan.queryPlan.inputStage.indexBounds." + HashName("ssn"): []any{`["REDACTED", "REDACTED"]`},
+				"executionStats.executionStages.inputStage.indexName":                          HashName("ssn_1"),
+				"command.find":                      HashName("employees"),
+				"command.filter." + HashName("ssn"): RedactedString,
+			},
+		},
+		{
+			name:    "aggregate",
+			fixture: "explain_aggregate.json",
+			options: setOptionsRedactedStrings,
+			expectedPaths: map[string]any{
+				"stages.0.$cursor.queryPlanner.parsedQuery.status.$eq":                       RedactedString,
+				"stages.0.$cursor.queryPlanner.winningPlan.inputStage.indexBounds.status":    []any{`["REDACTED", "REDACTED"]`},
+				"stages.0.$cursor.queryPlanner.winningPlan.inputStage.indexBounds.createdAt": []any{"[MaxKey, MinKey]"},
+				"stages.0.$cursor.queryPlanner.winningPlan.transformBy.customer":             float64(1),
+				"stages.0.nReturned":                            float64(120),
+				"stages.1.$group._id":                           "$customer",
+				"stages.1.maxAccumulatorMemoryUsageBytes.spent": float64(8640),
+				"stages.2.$match._id.$ne":                       "redacted@redacted.com",
+				"stages.2.nReturned":                            float64(11),
+				"command.pipeline.0.$match.status":              RedactedString,
+			},
+		},
+		{
+			name:    "sharded find",
+			fixture: "explain_sharded.json",
+			options: setOptionsRedactedStrings,
+			expectedPaths: map[string]any{
+				"queryPlanner.winningPlan.shards.0.parsedQuery.lastName.$eq":                                         RedactedString,
+				"queryPlanner.winningPlan.shards.0.winningPlan.inputStage.inputStage.indexBounds.lastName":           []any{`["REDACTED", "REDACTED"]`},
+				"executionStats.executionStages.shards.0.executionStages.inputStage.inputStage.indexBounds.lastName": []any{`["REDACTED", "REDACTED"]`},
+				"executionStats.executionStages.shards.0.shardName":                                                  "shard-0",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options()
+			defer setOptionsRedactedStrings()
+			doc, err := RedactExplain(getFixtureContent(t, "test_fixtures/"+tt.fixture))
+			if err != nil {
+				t.Fatalf("RedactExplain returned an unexpected error: %v", err)
+			}
+			for path, want := range tt.expectedPaths {
+				if got := getJSONPath(doc, path); !valuesEqual(got, want) {
+					t.Errorf("expected %s to be %s, got %s", path, getTypeInfo(want), getTypeInfo(got))
+				}
+			}
+		})
+	}
+}
+
+func TestRedactSlotBasedPlanString(t *testing.T) {
+	setOptionsRedactedStrings()
+	plan := `[1] ixseek KS(3C416C6963650004) @"0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60" @"name_1" [s2 = getField(s1, "name")] {(s2 == "O\"Neil")}`
+
+	got := redactSlotBasedPlanString(plan, false)
+	want := `[1] ixseek KS(REDACTED) @"0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60" @"name_1" [s2 = getField(s1, "name")] {(s2 == "REDACTED")}`
+	if got != want {
+		t.Errorf("redactSlotBasedPlanString() =\n%s\nwant\n%s", got, want)
+	}
+
+	got = redactSlotBasedPlanString(plan, true)
+	want = `[1] ixseek KS(REDACTED) @"0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60" @"` + HashName("name_1") + `" [s2 = getField(s1, "` + HashName("name") + `")] {(s2 == "REDACTED")}`
+	if got != want {
+		t.Errorf("redactSlotBasedPlanString() with field names =\n%s\nwant\n%s", got, want)
+	}
+}
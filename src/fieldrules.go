@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FieldAction determines what happens to the value of a field matched by a FieldRule.
+type FieldAction string
+
+const (
+	FieldActionKeep   FieldAction = "keep"
+	FieldActionRedact FieldAction = "redact"
+	FieldActionHash   FieldAction = "hash"
+)
+
+// FieldRule selects a field path within one or more namespaces, e.g. 'shop.users:address.zip=keep'.
+// The namespace is a glob (path.Match syntax), and the field path is a dotted pattern in which '*'
+// matches exactly one path segment and '**' matches any number of segments.
+type FieldRule struct {
+	Namespace string
+	Path      []string
+	Action    FieldAction
+}
+
+var (
+	fieldRules       []FieldRule
+	currentNamespace string
+
+	runHashKey     []byte
+	runHashKeyOnce sync.Once
+)
+
+func SetFieldRules(rules []FieldRule) { fieldRules = rules }
+func SetCurrentNamespace(ns string)   { currentNamespace = ns }
+
+// ParseFieldRule parses a selector in the form '<namespace>:<field path>=<keep|redact|hash>'.
+func ParseFieldRule(s string) (FieldRule, error) {
+	selector, action, found := strings.Cut(s, "=")
+	if !found {
+		return FieldRule{}, fmt.Errorf("invalid field rule %q: expected '<namespace>:<field path>=<keep|redact|hash>'", s)
+	}
+	ns, fieldPath, found := strings.Cut(selector, ":")
+	if !found || ns == "" || fieldPath == "" {
+		return FieldRule{}, fmt.Errorf("invalid field rule %q: expected '<namespace>:<field path>=<keep|redact|hash>'", s)
+	}
+	if _, err := path.Match(ns, ""); err != nil {
+		return FieldRule{}, fmt.Errorf("invalid namespace pattern in field rule %q: %w", s, err)
+	}
+	fa := FieldAction(strings.ToLower(action))
+	switch fa {
+	case FieldActionKeep, FieldActionRedact, FieldActionHash:
+	default:
+		return FieldRule{}, fmt.Errorf("invalid action %q in field rule %q: expected keep, redact, or hash", action, s)
+	}
+	return FieldRule{
+		Namespace: ns,
+		Path:      strings.Split(fieldPath, "."),
+		Action:    fa,
+	}, nil
+}
+
+// ParseFieldRules parses every selector in rules, stopping at the first invalid one.
+func ParseFieldRules(rules []string) ([]FieldRule, error) {
+	parsed := make([]FieldRule, 0, len(rules))
+	for _, r := range rules {
+		rule, err := ParseFieldRule(r)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// MatchesNamespace reports whether a namespace pattern selects ns. A pattern without a
+// collection part (e.g. 'shop') selects every collection in that database.
+func MatchesNamespace(pattern, ns string) bool {
+	if ok, _ := path.Match(pattern, ns); ok {
+		return true
+	}
+	if !strings.Contains(pattern, ".") {
+		db, _, _ := strings.Cut(ns, ".")
+		ok, _ := path.Match(pattern, db)
+		return ok
+	}
+	return false
+}
+
+func matchFieldPath(pattern []string, fieldPath []string) bool {
+	if len(pattern) == 0 {
+		return len(fieldPath) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(fieldPath); i++ {
+			if matchFieldPath(pattern[1:], fieldPath[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(fieldPath) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], fieldPath[0]); !ok {
+		return false
+	}
+	return matchFieldPath(pattern[1:], fieldPath[1:])
+}
+
+// fieldPathFromKeyPath turns a traversal key path into the document field path it addresses,
+// dropping operators and splitting dotted keys: [$match, a.b, $in] becomes [a, b].
+func fieldPathFromKeyPath(keyPath []string) []string {
+	fieldPath := make([]string, 0, len(keyPath))
+	for _, key := range keyPath {
+		if key == "" || strings.HasPrefix(key, "$") {
+			continue
+		}
+		if _, isOp := CoreOperators.Get(key); isOp {
+			continue
+		}
+		fieldPath = append(fieldPath, strings.Split(key, ".")...)
+	}
+	return fieldPath
+}
+
+// fieldRuleFor returns the last rule matching the field addressed by keyPath in the current namespace,
+// so that more specific rules can be listed after broader ones.
+func fieldRuleFor(keyPath []string) (FieldRule, bool) {
+	if len(fieldRules) == 0 {
+		return FieldRule{}, false
+	}
+	fieldPath := fieldPathFromKeyPath(keyPath)
+	if len(fieldPath) == 0 {
+		return FieldRule{}, false
+	}
+	for i := len(fieldRules) - 1; i >= 0; i-- {
+		rule := fieldRules[i]
+		if MatchesNamespace(rule.Namespace, currentNamespace) && matchFieldPath(rule.Path, fieldPath) {
+			return rule, true
+		}
+	}
+	return FieldRule{}, false
+}
+
+// HashValue returns a consistent hash for a scalar value, so equal values remain correlatable.
+// The type of the value is hashed too, so that 5 and "5" don't get the same hash. The hash is an
+// HMAC keyed with the encryption key, or with a random key for this run when there's none, so that
+// low-entropy values can't be recovered by hashing candidates. It's still only a pseudonym: equal
+// values get equal hashes.
+func HashValue(v any) string {
+	mac := hmac.New(sha256.New, valueHashKey())
+	fmt.Fprintf(mac, "%T:%v", v, v)
+	return fmt.Sprintf("%s_%x", redactedString, mac.Sum(nil)[:8])
+}
+
+// valueHashKey derives the key of HashValue from the encryption key, so it isn't used for two
+// purposes, or returns a random key generated once per run.
+func valueHashKey() []byte {
+	if len(encryptionKey) > 0 {
+		mac := hmac.New(sha256.New, encryptionKey)
+		mac.Write([]byte("anonymongo value hash"))
+		return mac.Sum(nil)
+	}
+	runHashKeyOnce.Do(func() {
+		runHashKey = make([]byte, 32)
+		if _, err := rand.Read(runHashKey); err != nil {
+			panic(fmt.Sprintf("failed to generate a hash key: %v", err))
+		}
+	})
+	return runHashKey
+}
+
+// checkpointHashKey returns the key of HashValue if it's the random key of this run and a field rule
+// hashes values, so a checkpoint can keep it for the run resuming from it, or nil otherwise.
+func checkpointHashKey() []byte {
+	if len(encryptionKey) > 0 || !slices.ContainsFunc(fieldRules, func(r FieldRule) bool { return r.Action == FieldActionHash }) {
+		return nil
+	}
+	return valueHashKey()
+}
+
+// restoreHashKey keys HashValue with the random key of a previous run, kept in its checkpoint.
+func restoreHashKey(key []byte) {
+	if len(key) == 0 {
+		return
+	}
+	runHashKeyOnce.Do(func() {})
+	runHashKey = key
+}
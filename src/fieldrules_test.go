@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseFieldRule(t *testing.T) {
+	testCases := []struct {
+		name      string
+		rule      string
+		expected  FieldRule
+		expectErr bool
+	}{
+		{
+			name:     "Exact namespace and field",
+			rule:     "shop.users:address.zip=keep",
+			expected: FieldRule{Namespace: "shop.users", Path: []string{"address", "zip"}, Action: FieldActionKeep},
+		},
+		{
+			name:     "Globbed namespace and recursive field",
+			rule:     "shop.*:payment.**=redact",
+			expected: FieldRule{Namespace: "shop.*", Path: []string{"payment", "**"}, Action: FieldActionRedact},
+		},
+		{
+			name:     "Case-insensitive action",
+			rule:     "shop.users:email=HASH",
+			expected: FieldRule{Namespace: "shop.users", Path: []string{"email"}, Action: FieldActionHash},
+		},
+		{name: "Missing action", rule: "shop.users:email", expectErr: true},
+		{name: "Missing namespace", rule: ":email=keep", expectErr: true},
+		{name: "Missing field path", rule: "shop.users=keep", expectErr: true},
+		{name: "Unknown action", rule: "shop.users:email=drop", expectErr: true},
+		{name: "Malformed namespace pattern", rule: "shop.[:email=keep", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := ParseFieldRule(tc.rule)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error for %q, but got none", tc.rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rule, tc.expected) {
+				t.Errorf("Expected %+v, but got %+v", tc.expected, rule)
+			}
+		})
+	}
+}
+
+func TestMatchesNamespace(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		ns       string
+		expected bool
+	}{
+		{"shop.users", "shop.users", true},
+		{"shop.user", "shop.users", false},
+		{"shop.user", "shop.users_archive", false},
+		{"shop.*", "shop.users", true},
+		{"shop.*", "shopping.users", false},
+		{"shop", "shop.users", true},
+		{"shop", "shopping.users", false},
+		{"*", "shop.users", true},
+		{"*.orders", "eu.orders", true},
+		{"*.orders", "eu.orders_v2", false},
+	}
+
+	for _, tc := range testCases {
+		if got := MatchesNamespace(tc.pattern, tc.ns); got != tc.expected {
+			t.Errorf("MatchesNamespace(%q, %q) = %v, expected %v", tc.pattern, tc.ns, got, tc.expected)
+		}
+	}
+}
+
+func TestFieldRuleFor(t *testing.T) {
+	defer SetFieldRules(nil)
+	defer SetCurrentNamespace("")
+
+	SetFieldRules([]FieldRule{
+		{Namespace: "shop.*", Path: []string{"payment", "**"}, Action: FieldActionRedact},
+		{Namespace: "shop.users", Path: []string{"address", "zip"}, Action: FieldActionKeep},
+		{Namespace: "shop.users", Path: []string{"*", "email"}, Action: FieldActionHash},
+	})
+
+	testCases := []struct {
+		name     string
+		ns       string
+		keyPath  []string
+		expected FieldAction
+		found    bool
+	}{
+		{"Exact path", "shop.users", []string{"address", "zip"}, FieldActionKeep, true},
+		{"Exact path with operators", "shop.users", []string{"$match", "address.zip", "$in"}, FieldActionKeep, true},
+		{"Prefix of a rule path", "shop.users", []string{"address"}, "", false},
+		{"Extension of a rule path", "shop.users", []string{"address", "zip", "plus4"}, "", false},
+		{"Single-segment wildcard", "shop.users", []string{"contact", "email"}, FieldActionHash, true},
+		{"Single-segment wildcard does not recurse", "shop.users", []string{"a", "b", "email"}, "", false},
+		{"Recursive wildcard", "shop.orders", []string{"payment", "card", "number"}, FieldActionRedact, true},
+		{"Recursive wildcard matches its root", "shop.orders", []string{"payment"}, FieldActionRedact, true},
+		{"Other namespace", "crm.users", []string{"address", "zip"}, "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			SetCurrentNamespace(tc.ns)
+			rule, found := fieldRuleFor(tc.keyPath)
+			if found != tc.found {
+				t.Fatalf("Expected found=%v, but got %v", tc.found, found)
+			}
+			if found && rule.Action != tc.expected {
+				t.Errorf("Expected action %q, but got %q", tc.expected, rule.Action)
+			}
+		})
+	}
+}
+
+func TestHashValue(t *testing.T) {
+	if HashValue("5") != HashValue("5") {
+		t.Error("Expected equal values to get the same hash")
+	}
+	tests := []struct {
+		name string
+		a, b any
+	}{
+		{name: "int and string", a: 5, b: "5"},
+		{name: "float and string", a: 5.5, b: "5.5"},
+		{name: "bool and string", a: true, b: "true"},
+		{name: "int and float", a: 5, b: 5.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if HashValue(tt.a) == HashValue(tt.b) {
+				t.Errorf("Expected %#v and %#v to get different hashes, both got %s", tt.a, tt.b, HashValue(tt.a))
+			}
+		})
+	}
+}
+
+func TestHashValue_Keyed(t *testing.T) {
+	defer SetEncryptionKey(nil)
+
+	SetEncryptionKey(nil)
+	runHash := HashValue("jdoe")
+	unkeyed := sha256.Sum256([]byte("string:jdoe"))
+	if runHash == fmt.Sprintf("%s_%x", redactedString, unkeyed[:8]) {
+		t.Errorf("Expected a keyed hash, but got the plain SHA-256 %s", runHash)
+	}
+
+	SetEncryptionKey(bytes.Repeat([]byte{1}, 64))
+	keyed := HashValue("jdoe")
+	if keyed == runHash {
+		t.Errorf("Expected the encryption key to change the hash, but both got %s", keyed)
+	}
+	if HashValue("jdoe") != keyed {
+		t.Error("Expected equal values to get the same hash with the same key")
+	}
+	SetEncryptionKey(bytes.Repeat([]byte{2}, 64))
+	if HashValue("jdoe") == keyed {
+		t.Errorf("Expected another encryption key to change the hash, but both got %s", keyed)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultFollowPollInterval = time.Second
+
+// FollowOptions configures FollowMongoLogFile.
+type FollowOptions struct {
+	// PollInterval is how long to wait for new lines once the end of the file is reached.
+	PollInterval time.Duration
+	// CheckpointFile, if set, is where the byte offset of the last redacted line is persisted, so
+	// a restart resumes where the previous run left off.
+	CheckpointFile string
+	// OutputFile, if set, is written instead of the writer passed to FollowMongoLogFile, and rotated
+	// once it reaches MaxOutputBytes (if positive), keeping MaxOutputFiles rotated files.
+	OutputFile     string
+	MaxOutputBytes int64
+	MaxOutputFiles int
+}
+
+// followedFile is the input file being followed, and how far into it redaction has progressed.
+type followedFile struct {
+	path   string
+	file   *os.File
+	reader *bufio.Reader
+	// offset is the position right after the last complete line read.
+	offset int64
+	// head holds the first bytes of the file, up to offset, to notice when they're replaced.
+	head []byte
+}
+
+func openFollowedFile(path string, offset int64) (*followedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// Compressed files can't be followed: appended data would be in the middle of a compressed
+	// stream. They're recognized from their content, whatever their name.
+	header := make([]byte, magicSize)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		f.Close()
+		return nil, err
+	}
+	if compression := DetectCompression(header[:n]); compression != CompressionNone {
+		f.Close()
+		return nil, fmt.Errorf("cannot follow %s: it's a %s file", path, compression)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &followedFile{path: path, file: f, reader: bufio.NewReader(f), offset: offset}, nil
+}
+
+// rotated reports whether the path now refers to a different file than the one open, as happens
+// when mongod renames its log on logRotate.
+func (ff *followedFile) rotated() bool {
+	current, err := os.Stat(ff.path)
+	if err != nil {
+		// The new file may not have been created yet.
+		return false
+	}
+	opened, err := ff.file.Stat()
+	return err == nil && !os.SameFile(opened, current)
+}
+
+// truncated reports whether the open file was truncated after being copied (copytruncate): it
+// shrank below the current position, or its first bytes changed because it grew again since.
+func (ff *followedFile) truncated(pending int) bool {
+	info, err := ff.file.Stat()
+	if err != nil {
+		return false
+	}
+	if info.Size() < ff.offset+int64(pending) {
+		return true
+	}
+	head := make([]byte, min(ff.offset, fingerprintSize))
+	if _, err := ff.file.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		return false
+	}
+	if !bytes.Equal(head[:len(ff.head)], ff.head) {
+		return true
+	}
+	ff.head = head
+	return false
+}
+
+func (ff *followedFile) rewind() error {
+	if _, err := ff.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	ff.reader.Reset(ff.file)
+	ff.offset = 0
+	ff.head = nil
+	return nil
+}
+
+func (ff *followedFile) checkpoint() (*Checkpoint, error) {
+	fingerprint, err := fileFingerprint(ff.file, ff.offset)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkpoint{Path: ff.path, Offset: ff.offset, Fingerprint: fingerprint, HashKey: checkpointHashKey()}, nil
+}
+
+// FollowMongoLogFile redacts filePath and then keeps redacting lines as they're appended to it,
+// like 'tail -F', until ctx is canceled. It follows the file across renames (mongod's logRotate)
+// and starts over from the beginning if the file is truncated. The checkpoint, if any, is saved
+// every checkpointInterval while lines are being redacted, and whenever the end of the file is reached.
+func FollowMongoLogFile(ctx context.Context, filePath string, outWriter io.Writer, opts FollowOptions) error {
+	path, err := filepath.Abs(filePath)
+	if err != nil {
+		return err
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultFollowPollInterval
+	}
+
+	ff, err := openFollowedFile(path, 0)
+	if err != nil {
+		return err
+	}
+	defer func() { ff.file.Close() }()
+
+	resumed := false
+	if opts.CheckpointFile != "" {
+		cp, err := LoadCheckpoint(opts.CheckpointFile)
+		if err != nil {
+			return err
+		}
+		if cp.Matches(path, ff.file) {
+			if _, err := ff.file.Seek(cp.Offset, io.SeekStart); err != nil {
+				return err
+			}
+			ff.reader.Reset(ff.file)
+			ff.offset = cp.Offset
+			restoreHashKey(cp.HashKey)
+			resumed = true
+		}
+	}
+
+	if opts.OutputFile != "" {
+		// Only keep the previous output if we're resuming the run that produced it.
+		w, err := NewRotatingFileWriter(opts.OutputFile, opts.MaxOutputBytes, opts.MaxOutputFiles, resumed)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		outWriter = w
+	}
+
+	savedOffset := int64(-1)
+	if resumed {
+		savedOffset = ff.offset
+	}
+	saveCheckpoint := func() error {
+		if opts.CheckpointFile == "" || ff.offset == savedOffset {
+			return nil
+		}
+		cp, err := ff.checkpoint()
+		if err != nil {
+			return fmt.Errorf("failed to fingerprint %s: %w", path, err)
+		}
+		if err := cp.Save(opts.CheckpointFile); err != nil {
+			return err
+		}
+		savedOffset = ff.offset
+		return nil
+	}
+
+	var pending []byte
+	lineNumber := 0
+	rotated := false
+	lastCheckpoint := time.Now()
+	for {
+		if ctx.Err() != nil {
+			return saveCheckpoint()
+		}
+		chunk, err := ff.reader.ReadBytes('\n')
+		pending = append(pending, chunk...)
+		if err == nil {
+			ff.offset += int64(len(pending))
+			lineNumber++
+			SetCurrentLineNumber(lineNumber)
+			if line := strings.TrimRight(string(pending), "\r\n"); line != "" {
+				redactLine(line, outWriter)
+			}
+			pending = pending[:0]
+			if time.Since(lastCheckpoint) >= checkpointInterval {
+				if err := saveCheckpoint(); err != nil {
+					return err
+				}
+				lastCheckpoint = time.Now()
+			}
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		// Caught up with the end of the file.
+		if err := saveCheckpoint(); err != nil {
+			return err
+		}
+		lastCheckpoint = time.Now()
+		if rotated {
+			// The renamed file has been read to its end: a final line without a trailing newline
+			// won't be completed anymore, so redact it before switching to the new file.
+			if line := strings.TrimSpace(string(pending)); line != "" {
+				redactLine(line, outWriter)
+			}
+			next, err := openFollowedFile(path, 0)
+			if err != nil {
+				return err
+			}
+			ff.file.Close()
+			ff = next
+			pending = pending[:0]
+			lineNumber = 0
+			rotated = false
+			savedOffset = -1
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return saveCheckpoint()
+		case <-time.After(opts.PollInterval):
+		}
+		if ff.rotated() {
+			// Read whatever was appended to the old file before it was renamed, then switch.
+			rotated = true
+			continue
+		}
+		if ff.truncated(len(pending)) {
+			if err := ff.rewind(); err != nil {
+				return err
+			}
+			pending = pending[:0]
+			lineNumber = 0
+			savedOffset = -1
+		}
+	}
+}
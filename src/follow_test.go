@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const followTestPollInterval = 10 * time.Millisecond
+
+func appendToFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func countOutputLines(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func waitForOutputLines(t *testing.T, path string, expected int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if countOutputLines(path) == expected {
+			return
+		}
+		time.Sleep(followTestPollInterval)
+	}
+	t.Fatalf("Expected %d redacted lines in %s, but got %d", expected, path, countOutputLines(path))
+}
+
+// startFollowing runs FollowMongoLogFile in the background, and returns a function that stops it
+// and returns its error.
+func startFollowing(t *testing.T, input string, opts FollowOptions) func() error {
+	t.Helper()
+	opts.PollInterval = followTestPollInterval
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- FollowMongoLogFile(ctx, input, nil, opts) }()
+	stopped := false
+	stop := func() error {
+		if stopped {
+			return nil
+		}
+		stopped = true
+		cancel()
+		return <-done
+	}
+	t.Cleanup(func() { stop() })
+	return stop
+}
+
+func TestFollowMongoLogFile_AppendRotateAndTruncate(t *testing.T) {
+	setOptionsRedactedStrings()
+	dir := t.TempDir()
+	input := filepath.Join(dir, "mongod.log")
+	output := filepath.Join(dir, "redacted.log")
+	line := getFixtureContent(t, "test_fixtures/simple_find.json") + "\n"
+	appendToFile(t, input, line)
+
+	stop := startFollowing(t, input, FollowOptions{OutputFile: output})
+	waitForOutputLines(t, output, 1)
+
+	// Appended lines, including one written in two parts.
+	appendToFile(t, input, line+line[:20])
+	waitForOutputLines(t, output, 2)
+	appendToFile(t, input, line[20:])
+	waitForOutputLines(t, output, 3)
+
+	// logRotate renames the file and a new one is created.
+	if err := os.Rename(input, input+".2025-01-01T00-00-00"); err != nil {
+		t.Fatalf("Failed to rename input: %v", err)
+	}
+	appendToFile(t, input, line)
+	waitForOutputLines(t, output, 4)
+
+	// copytruncate empties the file, which then grows past the previous position again.
+	if err := os.Truncate(input, 0); err != nil {
+		t.Fatalf("Failed to truncate input: %v", err)
+	}
+	// Give the follower a few polls to notice, as 'tail -F' would need too.
+	time.Sleep(5 * followTestPollInterval)
+	otherLine := getFixtureContent(t, "test_fixtures/find_with_emails.json") + "\n"
+	appendToFile(t, input, otherLine+otherLine)
+	waitForOutputLines(t, output, 6)
+
+	if err := stop(); err != nil {
+		t.Fatalf("FollowMongoLogFile returned an unexpected error: %v", err)
+	}
+	data, _ := os.ReadFile(output)
+	if strings.Contains(string(data), "simple string") {
+		t.Errorf("Expected redacted output, but got:\n%s", data)
+	}
+}
+
+func TestFollowMongoLogFile_ResumesFromCheckpoint(t *testing.T) {
+	setOptionsRedactedStrings()
+	dir := t.TempDir()
+	input := filepath.Join(dir, "mongod.log")
+	output := filepath.Join(dir, "redacted.log")
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	line := getFixtureContent(t, "test_fixtures/simple_find.json") + "\n"
+	appendToFile(t, input, line+line)
+	opts := FollowOptions{OutputFile: output, CheckpointFile: checkpoint}
+
+	stop := startFollowing(t, input, opts)
+	waitForOutputLines(t, output, 2)
+	if err := stop(); err != nil {
+		t.Fatalf("FollowMongoLogFile returned an unexpected error: %v", err)
+	}
+	cp, err := LoadCheckpoint(checkpoint)
+	if err != nil || cp == nil {
+		t.Fatalf("Expected a checkpoint, but got %v (error: %v)", cp, err)
+	}
+	if cp.Offset != int64(2*len(line)) {
+		t.Errorf("Expected checkpoint offset %d, but got %d", 2*len(line), cp.Offset)
+	}
+
+	// Lines appended while anonymongo isn't running are redacted once it's restarted, and the
+	// lines redacted before aren't redacted again.
+	appendToFile(t, input, line)
+	stop = startFollowing(t, input, opts)
+	waitForOutputLines(t, output, 3)
+	if err := stop(); err != nil {
+		t.Fatalf("FollowMongoLogFile returned an unexpected error: %v", err)
+	}
+
+	// A checkpoint taken on another file is ignored, and the output is started over.
+	if err := os.WriteFile(input, []byte(line), 0o644); err != nil {
+		t.Fatalf("Failed to replace input: %v", err)
+	}
+	stop = startFollowing(t, input, opts)
+	waitForOutputLines(t, output, 1)
+	if err := stop(); err != nil {
+		t.Fatalf("FollowMongoLogFile returned an unexpected error: %v", err)
+	}
+}
+
+// checkpointRecorder records the offset of the checkpoint saved before each line it's written.
+type checkpointRecorder struct {
+	checkpointFile string
+	offsets        []int64
+}
+
+func (r *checkpointRecorder) Write(p []byte) (int, error) {
+	offset := int64(-1)
+	if cp, err := LoadCheckpoint(r.checkpointFile); err == nil && cp != nil {
+		offset = cp.Offset
+	}
+	r.offsets = append(r.offsets, offset)
+	return len(p), nil
+}
+
+func TestFollowMongoLogFile_SavesCheckpointsPeriodically(t *testing.T) {
+	setOptionsRedactedStrings()
+	defer func(interval time.Duration) { checkpointInterval = interval }(checkpointInterval)
+	checkpointInterval = 0
+	dir := t.TempDir()
+	input := filepath.Join(dir, "mongod.log")
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	line := getFixtureContent(t, "test_fixtures/simple_find.json") + "\n"
+	appendToFile(t, input, strings.Repeat(line, 3))
+
+	recorder := &checkpointRecorder{checkpointFile: checkpoint}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- FollowMongoLogFile(ctx, input, recorder, FollowOptions{PollInterval: followTestPollInterval, CheckpointFile: checkpoint})
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if cp, _ := LoadCheckpoint(checkpoint); cp != nil && cp.Offset == int64(3*len(line)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a checkpoint at the end of the input")
+		}
+		time.Sleep(followTestPollInterval)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("FollowMongoLogFile returned an unexpected error: %v", err)
+	}
+	// Lines are redacted in a single read of the file, so the checkpoints before the second and
+	// third lines were saved before reaching its end.
+	expected := []int64{-1, int64(len(line)), int64(2 * len(line))}
+	if len(recorder.offsets) != len(expected) {
+		t.Fatalf("Expected %d redacted lines, but got %d", len(expected), len(recorder.offsets))
+	}
+	for i, offset := range expected {
+		if recorder.offsets[i] != offset {
+			t.Errorf("Expected the checkpoint offset before line %d to be %d, but got %d", i+1, offset, recorder.offsets[i])
+		}
+	}
+}
+
+func TestFollowMongoLogFile_CompressedInput(t *testing.T) {
+	dir := t.TempDir()
+	// A gzipped file without a .gz extension, e.g. a rotated log compressed in place.
+	input := filepath.Join(dir, "mongod.log")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(getFixtureContent(t, "test_fixtures/simple_find.json") + "\n"))
+	gz.Close()
+	if err := os.WriteFile(input, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	err := FollowMongoLogFile(context.Background(), input, nil, FollowOptions{})
+	if err == nil || !strings.Contains(err.Error(), "gzip") {
+		t.Errorf("Expected an error for a gzipped input, but got %v", err)
+	}
+}
+
+func TestFollowMongoLogFile_RotatesOutput(t *testing.T) {
+	setOptionsRedactedStrings()
+	dir := t.TempDir()
+	input := filepath.Join(dir, "mongod.log")
+	output := filepath.Join(dir, "redacted.log")
+	line := getFixtureContent(t, "test_fixtures/simple_find.json") + "\n"
+	appendToFile(t, input, strings.Repeat(line, 5))
+
+	redacted, err := RedactMongoLog(line)
+	if err != nil {
+		t.Fatalf("RedactMongoLog returned an unexpected error: %v", err)
+	}
+	redactedLine, _ := MarshalOrdered(redacted)
+	// Two redacted lines fit in a file.
+	maxBytes := int64(2*(len(redactedLine)+1) + 1)
+	stop := startFollowing(t, input, FollowOptions{OutputFile: output, MaxOutputBytes: maxBytes, MaxOutputFiles: 1})
+	deadline := time.Now().Add(5 * time.Second)
+	for countOutputLines(output+".1") != 2 || countOutputLines(output) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 lines in %s.1 and 1 in %s, but got %d and %d", output, output, countOutputLines(output+".1"), countOutputLines(output))
+		}
+		time.Sleep(followTestPollInterval)
+	}
+	if err := stop(); err != nil {
+		t.Fatalf("FollowMongoLogFile returned an unexpected error: %v", err)
+	}
+	if _, err := os.Stat(output + ".2"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 1 rotated file to be kept")
+	}
+}
+
+func TestFollowMongoLogFile_MissingInput(t *testing.T) {
+	err := FollowMongoLogFile(context.Background(), filepath.Join(t.TempDir(), "missing.log"), nil, FollowOptions{})
+	if err == nil {
+		t.Errorf("Expected an error for a missing input file")
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression is how an input is compressed or archived, as detected from its first bytes.
+type Compression string
+
+const (
+	CompressionNone  Compression = "none"
+	CompressionGzip  Compression = "gzip"
+	CompressionZstd  Compression = "zstd"
+	CompressionBzip2 Compression = "bzip2"
+	CompressionXz    Compression = "xz"
+	CompressionZip   Compression = "zip"
+	CompressionTar   Compression = "tar"
+)
+
+var compressionMagic = []struct {
+	compression Compression
+	offset      int
+	magic       []byte
+}{
+	{CompressionGzip, 0, []byte{0x1f, 0x8b}},
+	{CompressionZstd, 0, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{CompressionBzip2, 0, []byte("BZh")},
+	{CompressionXz, 0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{CompressionZip, 0, []byte("PK\x03\x04")},
+	{CompressionTar, 257, []byte("ustar")},
+}
+
+// magicSize is the number of leading bytes needed to recognize every compression.
+const magicSize = 262
+
+// maxCompressionNesting bounds how many layers of compression are undone, e.g., 2 for a .tar.gz.
+const maxCompressionNesting = 3
+
+// DetectCompression returns how the data starting with header is compressed or archived.
+func DetectCompression(header []byte) Compression {
+	for _, m := range compressionMagic {
+		if len(header) >= m.offset+len(m.magic) && bytes.Equal(header[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.compression
+		}
+	}
+	return CompressionNone
+}
+
+// InputFormat is how log entries are laid out in a decompressed input.
+type InputFormat string
+
+const (
+	// FormatJSONLines is one JSON entry per line, as written by mongod since 4.4.
+	FormatJSONLines InputFormat = "jsonLines"
+	// FormatPrettyJSON is JSON entries spanning several lines, or wrapped in an array.
+	FormatPrettyJSON InputFormat = "prettyJSON"
+	// FormatLegacyText is the text log format of mongod before 4.4.
+	FormatLegacyText InputFormat = "legacyText"
+	FormatUnknown    InputFormat = "unknown"
+)
+
+// EntryFormat is what the entries of an input are, which tells how they're redacted.
+type EntryFormat string
+
+const (
+	// EntryFormatLog is structured log entries, as written by mongod and mongos.
+	EntryFormatLog EntryFormat = "log"
+	// EntryFormatProfile is profiler documents, e.g., exported from system.profile with mongoexport.
+	EntryFormatProfile EntryFormat = "profile"
+	// EntryFormatExplain is the output of explain(), e.g., written with EJSON.stringify in mongosh.
+	EntryFormatExplain EntryFormat = "explain"
+	// EntryFormatCurrentOp is the output of db.currentOp(), or the operations returned by $currentOp.
+	EntryFormatCurrentOp EntryFormat = "currentOp"
+	// EntryFormatAudit is the events of a JSON audit log, as written by mongod and mongos.
+	EntryFormatAudit EntryFormat = "audit"
+)
+
+// ParseEntryFormat returns the EntryFormat for its name, ignoring case.
+func ParseEntryFormat(name string) (EntryFormat, error) {
+	for _, format := range []EntryFormat{EntryFormatLog, EntryFormatProfile, EntryFormatExplain, EntryFormatCurrentOp, EntryFormatAudit} {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid format %q (expected 'log', 'profile', 'explain', 'currentOp' or 'audit')", name)
+}
+
+// legacyLogLineRegex matches the start of a legacy text log line, e.g.,
+// '2020-01-01T00:00:00.000+0000 I  NETWORK  [conn1] ...'.
+var legacyLogLineRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?\s+[FEWID]\d?\s+\S+\s+\[[^\]]*\]`)
+
+// prettyJSONFieldRegex matches a line starting with a field of a pretty-printed JSON object.
+var prettyJSONFieldRegex = regexp.MustCompile(`^"(?:[^"\\]|\\.)*"\s*:`)
+
+// DetectInputFormat returns the format of the decompressed input starting with header.
+func DetectInputFormat(header []byte) InputFormat {
+	data := bytes.TrimLeft(header, " \t\r\n")
+	if len(data) == 0 {
+		return FormatJSONLines
+	}
+	line, _, complete := bytes.Cut(data, []byte("\n"))
+	switch data[0] {
+	case '[':
+		return FormatPrettyJSON
+	case '{':
+		// Pretty-printed JSON is only recognized by its layout: an opening brace alone on its line,
+		// or a field starting the next line. Anything else is JSON lines, even when the first line
+		// isn't valid JSON, e.g., a truncated entry, which is then skipped rather than failing the
+		// whole input.
+		if bytes.Equal(bytes.TrimSpace(line), []byte("{")) {
+			return FormatPrettyJSON
+		}
+		if complete {
+			next, _, _ := bytes.Cut(bytes.TrimLeft(data[len(line)+1:], " \t\r\n"), []byte("\n"))
+			if prettyJSONFieldRegex.Match(next) {
+				return FormatPrettyJSON
+			}
+		}
+		return FormatJSONLines
+	}
+	if legacyLogLineRegex.Match(line) {
+		return FormatLegacyText
+	}
+	return FormatUnknown
+}
+
+// logStream is the log content of an input, decompressed and extracted from archives. The entries
+// of an archive are read one after the other.
+type logStream struct {
+	io.Reader
+	// Compression is the outermost compression of the input.
+	Compression Compression
+	Format      InputFormat
+	// archived is whether the input holds a tar or zip archive, at any level of compression.
+	archived bool
+	closers  []func() error
+}
+
+// openLogStream detects how r is compressed or archived, and the format of the log it holds,
+// from their first bytes.
+func openLogStream(r io.Reader) (*logStream, error) {
+	s := &logStream{}
+	content, compression, err := s.decompress(r, 0)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	br := bufio.NewReaderSize(content, sniffSize)
+	header, _ := br.Peek(sniffSize)
+	s.Reader = br
+	s.Compression = compression
+	s.Format = DetectInputFormat(header)
+	return s, nil
+}
+
+// openLogInput is openLogStream for input that's about to be redacted line by line, rejecting
+// formats that can't be.
+func openLogInput(r io.Reader) (*logStream, error) {
+	s, err := openLogStream(r)
+	if err != nil {
+		return nil, err
+	}
+	if s.Format == FormatLegacyText {
+		s.Close()
+		return nil, errors.New("input is a legacy text log, written by MongoDB before 4.4; only structured (JSON) logs can be redacted")
+	}
+	return s, nil
+}
+
+// maxLogLineSize is the size of the longest log line that can be read.
+const maxLogLineSize = 16 * 1024 * 1024
+
+// forEachLogEntry calls fn with each log entry of s on a single line, and its line number. When
+// s holds pretty-printed JSON, its documents (or the elements of top-level arrays) are compacted,
+// and numbered in order instead.
+func forEachLogEntry(s *logStream, fn func(line string, lineNumber int)) error {
+	if s.Format == FormatPrettyJSON {
+		return forEachJSONDocument(s, fn)
+	}
+	scanner := bufio.NewScanner(s)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLineSize)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fn(scanner.Text(), lineNumber)
+	}
+	return scanner.Err()
+}
+
+func forEachJSONDocument(r io.Reader, fn func(line string, lineNumber int)) error {
+	dec := json.NewDecoder(r)
+	number := 0
+	var buf bytes.Buffer
+	emit := func(raw json.RawMessage) error {
+		buf.Reset()
+		if err := json.Compact(&buf, raw); err != nil {
+			return err
+		}
+		number++
+		fn(buf.String(), number)
+		return nil
+	}
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("invalid JSON after entry %d: %w", number, err)
+		}
+		if raw[0] != '[' {
+			if err := emit(raw); err != nil {
+				return err
+			}
+			continue
+		}
+		var elements []json.RawMessage
+		if err := json.Unmarshal(raw, &elements); err != nil {
+			return fmt.Errorf("invalid JSON after entry %d: %w", number, err)
+		}
+		for _, element := range elements {
+			if err := emit(element); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// openLogFileInput opens filePath with openLogInput. A file named '.gz' that isn't gzipped is
+// reported as corrupted rather than read as text.
+func openLogFileInput(fileReader FileReader, filePath string, progress *Progress) (*logStream, error) {
+	file, err := fileReader.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	s, err := openLogInput(progress.Reader(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// The file is closed after everything reading from it.
+	s.closers = append([]func() error{file.Close}, s.closers...)
+	if fileReader.GetExtension(filePath) == ".gz" && s.Compression != CompressionGzip {
+		s.Close()
+		return nil, fmt.Errorf("failed to create gzip reader: %s isn't gzipped", filePath)
+	}
+	return s, nil
+}
+
+// Close releases the decompressors and temporary files used by the stream.
+func (s *logStream) Close() error {
+	var errs []error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		errs = append(errs, s.closers[i]())
+	}
+	s.closers = nil
+	return errors.Join(errs...)
+}
+
+func (s *logStream) decompress(r io.Reader, depth int) (io.Reader, Compression, error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(magicSize)
+	compression := DetectCompression(header)
+	if depth >= maxCompressionNesting {
+		return br, CompressionNone, nil
+	}
+
+	var inner io.Reader
+	switch compression {
+	case CompressionNone:
+		return br, compression, nil
+	case CompressionGzip:
+		gzReader, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, compression, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		s.closers = append(s.closers, gzReader.Close)
+		inner = gzReader
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, compression, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		s.closers = append(s.closers, func() error { decoder.Close(); return nil })
+		inner = decoder
+	case CompressionBzip2:
+		inner = bzip2.NewReader(br)
+	case CompressionXz:
+		xzReader, err := xz.NewReader(br)
+		if err != nil {
+			return nil, compression, fmt.Errorf("failed to create xz reader: %w", err)
+		}
+		inner = xzReader
+	case CompressionTar:
+		s.archived = true
+		tr := tar.NewReader(br)
+		return &entriesReader{next: func() (io.Reader, string, error) {
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					return nil, "", err
+				}
+				if hdr.Typeflag == tar.TypeReg {
+					return tr, hdr.Name, nil
+				}
+			}
+		}, open: s.entryOpener(depth)}, compression, nil
+	case CompressionZip:
+		s.archived = true
+		var zr *zip.Reader
+		var err error
+		if f, at := inputFile(r); depth == 0 && f != nil {
+			zr, err = openZipFile(f, at)
+		} else {
+			zr, err = s.openZip(br)
+		}
+		if err != nil {
+			return nil, compression, err
+		}
+		files := zr.File
+		return &entriesReader{next: func() (io.Reader, string, error) {
+			for len(files) > 0 {
+				f := files[0]
+				files = files[1:]
+				if f.Mode().IsRegular() {
+					rc, err := f.Open()
+					if err != nil {
+						return nil, f.Name, err
+					}
+					s.closers = append(s.closers, rc.Close)
+					return rc, f.Name, nil
+				}
+			}
+			return nil, "", io.EOF
+		}, open: s.entryOpener(depth)}, compression, nil
+	}
+	// The decompressed data may be an archive itself, e.g., a .tar.gz.
+	content, _, err := s.decompress(inner, depth+1)
+	return content, compression, err
+}
+
+func (s *logStream) entryOpener(depth int) func(io.Reader) (io.Reader, error) {
+	return func(r io.Reader) (io.Reader, error) {
+		content, _, err := s.decompress(r, depth+1)
+		return content, err
+	}
+}
+
+// inputFile returns the regular file r reads, if any, with the reader to read it at any offset,
+// which counts the progress of r.
+func inputFile(r io.Reader) (*os.File, io.ReaderAt) {
+	var p *Progress
+	if pr, ok := r.(*progressReader); ok {
+		r, p = pr.r, pr.p
+	}
+	f, ok := r.(*os.File)
+	if !ok {
+		return nil, nil
+	}
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	if p == nil {
+		return f, f
+	}
+	return f, &readerAt{r: f, p: p}
+}
+
+// openZipFile reads a zip archive in place from f, through at.
+func openZipFile(f *os.File, at io.ReaderAt) (*zip.Reader, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	zr, err := zip.NewReader(at, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zip reader: %w", err)
+	}
+	return zr, nil
+}
+
+// openZip reads a zip archive that isn't a regular file, e.g., from stdin. Its directory is at the
+// end, so the archive is first copied to a temporary file, which os.CreateTemp makes only readable
+// by its owner (0600). It's removed right away where an open file can be, so that it's gone even
+// if the process exits without closing s.
+func (s *logStream) openZip(r io.Reader) (*zip.Reader, error) {
+	tmp, err := os.CreateTemp("", "anonymongo-zip-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zip reader: %w", err)
+	}
+	removed := os.Remove(tmp.Name()) == nil
+	s.closers = append(s.closers, func() error {
+		err := tmp.Close()
+		if !removed {
+			err = os.Remove(tmp.Name())
+		}
+		return err
+	})
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zip reader: %w", err)
+	}
+	return zr, nil
+}
+
+// entriesReader reads the entries of an archive one after the other, decompressing each one, and
+// making sure each ends with a new line so the last line of an entry isn't joined with the next.
+type entriesReader struct {
+	next    func() (io.Reader, string, error)
+	open    func(io.Reader) (io.Reader, error)
+	current io.Reader
+	// lastByte is the last byte read from the current entry.
+	lastByte    byte
+	needNewline bool
+}
+
+func (er *entriesReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if er.needNewline {
+			er.needNewline = false
+			p[0] = '\n'
+			return 1, nil
+		}
+		if er.current == nil {
+			entry, name, err := er.next()
+			if err != nil {
+				if name != "" {
+					err = fmt.Errorf("failed to read %s: %w", name, err)
+				}
+				return 0, err
+			}
+			if er.current, err = er.open(entry); err != nil {
+				return 0, fmt.Errorf("failed to read %s: %w", name, err)
+			}
+			er.lastByte = '\n'
+		}
+		n, err := er.current.Read(p)
+		if n > 0 {
+			er.lastByte = p[n-1]
+		}
+		if errors.Is(err, io.EOF) {
+			er.current = nil
+			er.needNewline = er.lastByte != '\n'
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
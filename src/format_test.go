@@ -0,0 +1,345 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+func zstdBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("Failed to create zstd writer: %v", err)
+	}
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+func xzBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("Failed to create xz writer: %v", err)
+	}
+	w.Write([]byte(data))
+	w.Close()
+	return buf.Bytes()
+}
+
+func tarBytes(t *testing.T, entries map[string][]byte, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for _, name := range names {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(entries[name]))})
+		tw.Write(entries[name])
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, entries map[string][]byte, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("logs/")
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create zip entry: %v", err)
+		}
+		w.Write(entries[name])
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestOpenLogStream(t *testing.T) {
+	line := getFixtureContent(t, "test_fixtures/simple_find.json") + "\n"
+	otherLine := getFixtureContent(t, "test_fixtures/find_with_emails.json") + "\n"
+	bzip2Data, err := os.ReadFile(filepath.Join("..", "test_fixtures", "simple_find.log.bz2"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	// Entries without a trailing new line are still read as separate lines.
+	entries := map[string][]byte{
+		"logs/mongod.log":    []byte(strings.TrimSuffix(line, "\n")),
+		"logs/mongod.log.gz": gzipBytes(t, otherLine),
+	}
+
+	tests := []struct {
+		name            string
+		input           []byte
+		wantCompression Compression
+		wantContent     string
+	}{
+		{"plain", []byte(line), CompressionNone, line},
+		{"gzip", gzipBytes(t, line), CompressionGzip, line},
+		{"zstd", zstdBytes(t, line), CompressionZstd, line},
+		{"bzip2", bzip2Data, CompressionBzip2, line},
+		{"xz", xzBytes(t, line), CompressionXz, line},
+		{"tar", tarBytes(t, entries, "logs/mongod.log", "logs/mongod.log.gz"), CompressionTar, line + otherLine},
+		{"tar.gz", gzipBytes(t, string(tarBytes(t, entries, "logs/mongod.log", "logs/mongod.log.gz"))), CompressionGzip, line + otherLine},
+		{"zip", zipBytes(t, entries, "logs/mongod.log", "logs/mongod.log.gz"), CompressionZip, line + otherLine},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := openLogStream(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("openLogStream() error = %v", err)
+			}
+			defer s.Close()
+			content, err := io.ReadAll(s)
+			if err != nil {
+				t.Fatalf("Failed to read stream: %v", err)
+			}
+			if s.Compression != tt.wantCompression {
+				t.Errorf("Compression = %q, want %q", s.Compression, tt.wantCompression)
+			}
+			if s.Format != FormatJSONLines {
+				t.Errorf("Format = %q, want %q", s.Format, FormatJSONLines)
+			}
+			if string(content) != tt.wantContent {
+				t.Errorf("Content =\n%s\nwant\n%s", content, tt.wantContent)
+			}
+		})
+	}
+}
+
+// TestOpenLogStreamZipTemporaryFile checks that a zip archive in a file is read in place, and that
+// one read from a stream is never left in a temporary file.
+func TestOpenLogStreamZipTemporaryFile(t *testing.T) {
+	line := getFixtureContent(t, "test_fixtures/simple_find.json") + "\n"
+	archive := zipBytes(t, map[string][]byte{"mongod.log": []byte(line)}, "mongod.log")
+	archivePath := filepath.Join(t.TempDir(), "logs.zip")
+	if err := os.WriteFile(archivePath, archive, 0o644); err != nil {
+		t.Fatalf("Failed to write archive: %v", err)
+	}
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	tests := []struct {
+		name string
+		open func(t *testing.T) (*logStream, error)
+	}{
+		{"file", func(t *testing.T) (*logStream, error) {
+			return openLogFileInput(&DefaultFileReader{}, archivePath, nil)
+		}},
+		{"file with progress", func(t *testing.T) (*logStream, error) {
+			return openLogFileInput(&DefaultFileReader{}, archivePath, NewProgress(ProgressJSON, io.Discard, archivePath, int64(len(archive))))
+		}},
+		{"stream", func(t *testing.T) (*logStream, error) {
+			if runtime.GOOS == "windows" {
+				t.Skip("Open files can't be removed on Windows")
+			}
+			return openLogStream(bytes.NewReader(archive))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.open(t)
+			if err != nil {
+				t.Fatalf("Failed to open archive: %v", err)
+			}
+			defer s.Close()
+			if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+				t.Errorf("Temporary directory holds %d file(s) while reading the archive, want none", len(entries))
+			}
+			if content, err := io.ReadAll(s); err != nil || string(content) != line {
+				t.Errorf("Content = %q, %v, want %q", content, err, line)
+			}
+		})
+	}
+}
+
+func TestProcessMongoLogFileFromReader_Compressed(t *testing.T) {
+	setOptionsRedactedStrings()
+	logContent := getFixtureContent(t, "test_fixtures/simple_find.json")
+	expectedOutput := generateExpectedOutput(t, logContent)
+
+	// Piped 'curl' output of an Atlas download is gzipped, without any file name to tell.
+	var outBuffer bytes.Buffer
+	if err := ProcessMongoLogFileFromReader(bytes.NewReader(gzipBytes(t, logContent)), &outBuffer, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+	}
+	if outBuffer.String() != expectedOutput {
+		t.Errorf("Unexpected output.\nGot:\n%s\nWant:\n%s", outBuffer.String(), expectedOutput)
+	}
+
+	// A renamed download, without the .gz extension.
+	path := filepath.Join(t.TempDir(), "mongodb")
+	if err := os.WriteFile(path, zstdBytes(t, logContent), 0o644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	outBuffer.Reset()
+	if err := ProcessMongoLogFile(&DefaultFileReader{}, path, &outBuffer, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFile returned an unexpected error: %v", err)
+	}
+	if outBuffer.String() != expectedOutput {
+		t.Errorf("Unexpected output.\nGot:\n%s\nWant:\n%s", outBuffer.String(), expectedOutput)
+	}
+}
+
+func TestProcessMongoLogFileFromReader_UnsupportedFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		wantErr string
+	}{
+		{"legacy text log", []byte("2020-01-01T00:00:00.000+0000 I  NETWORK  [listener] connection accepted from 127.0.0.1:50000 #1\n"), "legacy text log"},
+		{"gzipped legacy text log", gzipBytes(t, "2020-01-01T00:00:00.000+0000 I  NETWORK  [listener] connection accepted\n"), "legacy text log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var outBuffer bytes.Buffer
+			err := ProcessMongoLogFileFromReader(bytes.NewReader(tt.input), &outBuffer, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ProcessMongoLogFileFromReader() error = %v, want an error about a %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectCompression(t *testing.T) {
+	tarHeader := tarBytes(t, map[string][]byte{"a": []byte("a")}, "a")
+	tests := []struct {
+		name   string
+		header []byte
+		want   Compression
+	}{
+		{"gzip", gzipBytes(t, "x"), CompressionGzip},
+		{"zstd", zstdBytes(t, "x"), CompressionZstd},
+		{"bzip2", []byte("BZh91AY&SY"), CompressionBzip2},
+		{"xz", xzBytes(t, "x"), CompressionXz},
+		{"zip", zipBytes(t, nil), CompressionZip},
+		{"tar", tarHeader, CompressionTar},
+		{"truncated tar header", tarHeader[:200], CompressionNone},
+		{"JSON", []byte(`{"t":1}`), CompressionNone},
+		{"empty", nil, CompressionNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectCompression(tt.header); got != tt.want {
+				t.Errorf("DetectCompression() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectInputFormat(t *testing.T) {
+	pretty, err := os.ReadFile(filepath.Join("..", "test_fixtures", "simple_find.json"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	line := getFixtureContent(t, "test_fixtures/simple_find.json")
+	tests := []struct {
+		name   string
+		header string
+		want   InputFormat
+	}{
+		{"JSON lines", line + "\n" + line + "\n", FormatJSONLines},
+		{"JSON line longer than the header", line[:100], FormatJSONLines},
+		{"leading blank lines", "\n\r\n" + line + "\n", FormatJSONLines},
+		{"pretty-printed entry", string(pretty), FormatPrettyJSON},
+		{"array of entries", "[" + line + "," + line + "]", FormatPrettyJSON},
+		{"pretty-printed entry with a field on its first line", "{\"t\": {\"$date\": \"2025-05-30T09:47:39.001+00:00\"},\n  \"msg\": \"x\"\n}\n", FormatPrettyJSON},
+		{"JSON lines with a corrupted first line", line[:100] + "\n" + line + "\n", FormatJSONLines},
+		{"JSON lines with a first line that isn't JSON", "{not json\n" + line + "\n", FormatJSONLines},
+		{"legacy text log", "2019-05-07T10:30:00.123+0000 I  NETWORK  [listener] connection accepted from 127.0.0.1:50000 #1 (1 connection now open)\n", FormatLegacyText},
+		{"legacy text log with debug severity", "2019-05-07T10:30:00.123Z D1 COMMAND  [conn1] run command admin.$cmd { isMaster: 1 }\n", FormatLegacyText},
+		{"arbitrary text", "hello world\n", FormatUnknown},
+		{"empty", "", FormatJSONLines},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectInputFormat([]byte(tt.header)); got != tt.want {
+				t.Errorf("DetectInputFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForEachLogEntry(t *testing.T) {
+	line := getFixtureContent(t, "test_fixtures/simple_find.json")
+	otherLine := getFixtureContent(t, "test_fixtures/find_with_emails.json")
+	pretty, err := os.ReadFile(filepath.Join("..", "test_fixtures", "simple_find.json"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	compact := func(s string) string {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(s)); err != nil {
+			t.Fatalf("Failed to compact JSON: %v", err)
+		}
+		return buf.String()
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{"JSON lines", line + "\n" + otherLine + "\n", []string{line, otherLine}, false},
+		{"pretty-printed entry", string(pretty), []string{compact(line)}, false},
+		{"concatenated pretty-printed entries", string(pretty) + "\n" + string(pretty), []string{compact(line), compact(line)}, false},
+		{"array of entries", "[\n  " + line + ",\n  " + otherLine + "\n]\n", []string{compact(line), compact(otherLine)}, false},
+		{"arrays and entries", "[" + line + "]\n" + string(pretty) + "[]", []string{compact(line), compact(line)}, false},
+		{"invalid JSON", string(pretty) + "{\n  \"t\": ", []string{compact(line)}, true},
+		// A bad first line is skipped like any other, rather than failing the whole input.
+		{"JSON lines with a truncated first line", line[:100] + "\n" + line + "\n" + otherLine + "\n", []string{line[:100], line, otherLine}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := openLogStream(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("openLogStream() error = %v", err)
+			}
+			defer s.Close()
+			var got []string
+			var numbers []int
+			err = forEachLogEntry(s, func(line string, lineNumber int) {
+				got = append(got, line)
+				numbers = append(numbers, lineNumber)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("forEachLogEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("forEachLogEntry() entries =\n%v\nwant\n%v", got, tt.want)
+			}
+			for i, n := range numbers {
+				if n != i+1 {
+					t.Errorf("Entry %d has number %d, want %d", i, n, i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestParseEntryFormat(t *testing.T) {
+	for name, want := range map[string]EntryFormat{"log": EntryFormatLog, "Profile": EntryFormatProfile, "explain": EntryFormatExplain, "currentOp": EntryFormatCurrentOp, "currentop": EntryFormatCurrentOp, "audit": EntryFormatAudit} {
+		if got, err := ParseEntryFormat(name); err != nil || got != want {
+			t.Errorf("ParseEntryFormat(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseEntryFormat("oplog"); err == nil {
+		t.Errorf("ParseEntryFormat(\"oplog\") error = nil, want an error")
+	}
+}
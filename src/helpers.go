@@ -13,6 +13,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 )
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
@@ -57,18 +58,31 @@ func ParsePlanSummary(planSummary string) []string {
 
 // HashName returns a consistent hash for a field name.
 func HashName(field string) string {
-	trimmed := strings.TrimLeft(field, "$")
-	parts := strings.Split(trimmed, ".")
+	parts := strings.Split(strings.TrimLeft(field, "$"), ".")
 	hashedParts := make([]string, len(parts))
 	for i, part := range parts {
-		h := sha256.Sum256([]byte(part))
-		hashed := fmt.Sprintf("%s_%x", redactedString, h[:8])
+		hashed := hashNamePart(part)
 		RedactedFieldMapping[part] = hashed
+		reportHashedName(part)
 		hashedParts[i] = hashed
 	}
 	return strings.Join(hashedParts, ".")
 }
 
+// hashedName returns the same hash as HashName, without recording the mapping or reporting it.
+func hashedName(field string) string {
+	parts := strings.Split(strings.TrimLeft(field, "$"), ".")
+	for i, part := range parts {
+		parts[i] = hashNamePart(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+func hashNamePart(part string) string {
+	h := sha256.Sum256([]byte(part))
+	return fmt.Sprintf("%s_%x", redactedString, h[:8])
+}
+
 func RemoveElementAfter(slice []string, marker string) []string {
 	for i, v := range slice {
 		if v == marker && i+1 < len(slice) {
@@ -100,7 +114,11 @@ func UnmarshalOrdered(data []byte) (*orderedmap.OrderedMap[string, any], error)
 	if err != nil {
 		return nil, err
 	}
-	return val.(*orderedmap.OrderedMap[string, any]), nil
+	obj, ok := val.(*orderedmap.OrderedMap[string, any])
+	if !ok {
+		return nil, fmt.Errorf("expected a JSON object, got %T", val)
+	}
+	return obj, nil
 }
 
 func parseValue(dec *json.Decoder) (any, error) {
@@ -202,6 +220,12 @@ func MarshalOrdered(m *orderedmap.OrderedMap[string, any]) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// formatISODate formats a number of milliseconds since the epoch as an ISO-8601 date, the way
+// dates are written in extended JSON.
+func formatISODate(millis int64) string {
+	return time.UnixMilli(millis).UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
 func FileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
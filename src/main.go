@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -31,18 +37,82 @@ func main() {
 		eagerRedactionPaths  []string
 		redactedFieldsRegexp string
 		atlasProjectId       string
-		atlasClusterName     string
+		atlasClusterNames    []string
+		atlasAllClusters     bool
 		atlasPublicKey       string
 		atlasPrivateKey      string
+		atlasClientId        string
+		atlasClientSecret    string
 		atlasLogStartDate    int
 		atlasLogEndDate      int
+		atlasSince           string
+		atlasFrom            string
+		atlasTo              string
+		atlasChunkSize       time.Duration
+		atlasLogTypeNames    []string
+		atlasHostRoleNames   []string
+		atlasShards          []string
+		atlasConcurrency     int
+		atlasMaxRetries      int
+		atlasAllowPartial    bool
+		atlasMergeOutput     bool
+		atlasTempFiles       bool
+		outputTemplate       string
 		encryptionKeyFile    string
 		redactNamespaces     bool
+		fieldRuleSpecs       []string
+		operatorCatalogFile  string
+		strictOperators      bool
+		reportFile           string
+		dryRun               bool
+		follow               bool
+		checkpointFile       string
+		outputMaxSizeMB      int
+		outputMaxFiles       int
+		resume               bool
+		inputDir             string
+		outputDir            string
+		nonLogFiles          string
+		progressOutput       string
+		indent               int
+		entryFormatName      string
+		atlasBaseUrl         string
+		opsManagerUrl        string
+		opsManagerProjectId  string
+		opsManagerResource   string
+		opsManagerResType    string
+		opsManagerLogTypes   []string
+		opsManagerPublicKey  string
+		opsManagerPrivateKey string
+		opsManagerTimeout    time.Duration
 	)
 	// Flag for the "decrypt" command
 	var (
 		decryptionKeyFile string
 	)
+	// Flag for the "operators" command
+	var (
+		operatorsCatalogFile string
+	)
+	// Flag for the "diff" command
+	var (
+		diffComponents []string
+		diffNamespaces []string
+		diffLines      string
+		diffNoColor    bool
+	)
+	// Flag for the "redact-query" command
+	var (
+		queryNamespace string
+		queryCommand   string
+	)
+	// Flag for the "scan" command
+	var (
+		scanSecrets           []string
+		scanSecretsFile       string
+		scanDecryptionKeyFile string
+		scanShowValues        bool
+	)
 
 	var rootCmd = &cobra.Command{
 		Use:   "anonymongo",
@@ -52,8 +122,77 @@ making them safe to share. It also provides utilities for related tasks.`,
 		// No 'Run' function, so it will show help if called without a subcommand.
 	}
 
+	// applyRedactionOptions configures the redaction engine from the flags shared by the "redact" and
+	// "scan" commands.
+	applyRedactionOptions := func() {
+		SetRedactedString(replacement)
+		SetRedactNumbers(redactNumbers)
+		SetRedactIPs(redactIPs)
+		SetRedactBooleans(redactBooleans)
+		SetEagerRedactionPaths(eagerRedactionPaths)
+		SetRedactNamespaces(redactNamespaces)
+		SetRedactedFieldsRegexp(redactedFieldsRegexp)
+		rules, err := ParseFieldRules(fieldRuleSpecs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		SetFieldRules(rules)
+		if operatorCatalogFile != "" {
+			catalog, err := LoadOperatorCatalogFile(operatorCatalogFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading operator catalog: %v\n", err)
+				os.Exit(1)
+			}
+			SetOperatorCatalog(catalog)
+		}
+		SetStrictOperators(strictOperators)
+	}
+
+	// applyEntryFormat sets the --format of the input of the "scan" and "diff" commands.
+	applyEntryFormat := func() {
+		format, err := ParseEntryFormat(entryFormatName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		SetEntryFormat(format)
+	}
+
+	// applyEncryptionOptions sets up --encrypt, creating the key file if it doesn't exist yet.
+	applyEncryptionOptions := func() {
+		if !encrypt || encryptionKeyFile == "" {
+			if slices.ContainsFunc(fieldRules, func(r FieldRule) bool { return r.Action == FieldActionHash }) {
+				fmt.Fprintln(os.Stderr, "Note: hashed field values are keyed with a random key for this run, so they can't be compared\nwith those of other runs. Use --encrypt to key them with the encryption key instead.")
+			}
+			return
+		}
+		SetShouldEncrypt(encrypt)
+		keyfileExists := FileExists(encryptionKeyFile)
+		if !keyfileExists {
+			newKey, err := GenerateKey()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error generating encryption key: %v\n", err)
+				os.Exit(1)
+			}
+			err = WriteKeyToFile(encryptionKeyFile, newKey)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing encryption key to file: %v\n", err)
+				os.Exit(1)
+			}
+			SetEncryptionKey(newKey)
+		} else {
+			key, err := ReadKeyFromFile(encryptionKeyFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading encryption key file: %v\n", err)
+				os.Exit(1)
+			}
+			SetEncryptionKey(key)
+		}
+	}
+
 	var redactCmd = &cobra.Command{
-		Use:   "redact [JSON file or gzipped MongoDB log file]",
+		Use:   "redact [JSON file, gzipped MongoDB log file, or archive]",
 		Short: "Redact MongoDB log files",
 		Long: `Redact MongoDB log files by replacing sensitive information with generic placeholders.
 
@@ -82,21 +221,95 @@ You can provide input either as a file (as the first argument) or by piping logs
 			stdinHasData := (stat.Mode() & os.ModeCharDevice) == 0
 
 			// Atlas-related parameter detection
-			atlasParamsSet := atlasProjectId != "" || atlasClusterName != "" || atlasLogStartDate != 0 || atlasLogEndDate != 0 || atlasPublicKey != "" || atlasPrivateKey != ""
+			atlasParamsSet := atlasProjectId != "" || len(atlasClusterNames) > 0 || atlasAllClusters || atlasLogStartDate != 0 || atlasLogEndDate != 0 || atlasSince != "" || atlasFrom != "" || atlasTo != "" || atlasPublicKey != "" || atlasPrivateKey != "" || atlasClientId != "" || atlasClientSecret != "" || cmd.Flags().Changed("atlasLogTypes") || len(atlasHostRoleNames) > 0 || len(atlasShards) > 0 ||
+				cmd.Flags().Changed("atlasConcurrency") || cmd.Flags().Changed("atlasMaxRetries") || cmd.Flags().Changed("atlasChunkSize") || atlasAllowPartial || atlasMergeOutput || atlasTempFiles || cmd.Flags().Changed("atlasBaseUrl")
+			opsManagerParamsSet := opsManagerProjectId != "" || opsManagerResource != "" || opsManagerPublicKey != "" || opsManagerPrivateKey != "" || cmd.Flags().Changed("opsManagerUrl") ||
+				cmd.Flags().Changed("opsManagerResourceType") || cmd.Flags().Changed("opsManagerLogTypes") || cmd.Flags().Changed("opsManagerTimeout")
 
 			if redactedFieldsRegexp != "" && len(eagerRedactionPaths) > 0 {
 				fmt.Fprintln(os.Stderr, "Error: Cannot provide both --redactedFieldsRegexp and --redactFieldNames flags. Please use only one.")
 				os.Exit(1)
 			}
+			format, err := ParseEntryFormat(entryFormatName)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if format != EntryFormatLog && (atlasParamsSet || opsManagerParamsSet || inputDir != "" || (len(args) == 1 && IsArchive(args[0]))) {
+				fmt.Fprintf(os.Stderr, "Error: --format %s cannot be used with Atlas or Ops Manager parameters, --inputDir or an archive input, which only hold logs.\n", format)
+				os.Exit(1)
+			}
+			logTypes, err := ParseAtlasLogTypes(atlasLogTypeNames)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			hostRoles, err := ParseHostRoles(atlasHostRoleNames)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			resourceType, err := ParseLogCollectionResourceType(opsManagerResType)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			collectedLogTypes, err := ParseLogCollectionLogTypes(opsManagerLogTypes)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if atlasConcurrency < 1 || atlasMaxRetries < 0 {
+				fmt.Fprintln(os.Stderr, "Error: --atlasConcurrency must be at least 1, and --atlasMaxRetries must not be negative.")
+				os.Exit(1)
+			}
+			if atlasAllowPartial && atlasMergeOutput && !atlasTempFiles {
+				fmt.Fprintln(os.Stderr, "Error: --atlasAllowPartial with --atlasMergeOutput requires --atlasTempFiles.")
+				os.Exit(1)
+			}
+			if indent < 0 {
+				fmt.Fprintln(os.Stderr, "Error: --indent must not be negative.")
+				os.Exit(1)
+			}
+			progressMode, err := ParseProgressMode(progressOutput)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			// A bar would be mixed up with redacted output written to the same terminal.
+			if progressMode == ProgressAuto && outputFile == "" && isTerminal(os.Stdout) {
+				progressMode = ProgressNone
+			}
 			// Validation: atlasLogStartDate and atlasLogEndDate must be both set or both unset
 			if (atlasLogStartDate != 0 && atlasLogEndDate == 0) || (atlasLogStartDate == 0 && atlasLogEndDate != 0) {
 				fmt.Fprintln(os.Stderr, "Error: Both --atlasLogStartDate and --atlasLogEndDate must be set together, or neither.")
 				os.Exit(1)
 			}
+			if (atlasSince != "" || atlasFrom != "" || atlasTo != "") && (atlasLogStartDate != 0 || atlasLogEndDate != 0) {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide --since, --from or --to with --atlasLogStartDate and --atlasLogEndDate.")
+				os.Exit(1)
+			}
+			rangeStart, rangeEnd, err := ResolveTimeRange(atlasSince, atlasFrom, atlasTo, time.Now())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if rangeEnd != 0 {
+				atlasLogStartDate, atlasLogEndDate = rangeStart, rangeEnd
+			}
+			if atlasChunkSize < time.Minute {
+				fmt.Fprintln(os.Stderr, "Error: --atlasChunkSize must be at least 1m.")
+				os.Exit(1)
+			}
 
-			// Validation: atlasProjectId and atlasClusterName must be both set or both unset
-			if (atlasProjectId != "" && atlasClusterName == "") || (atlasProjectId == "" && atlasClusterName != "") {
-				fmt.Fprintln(os.Stderr, "Error: Both --atlasProjectId and --atlasClusterName must be set together, or neither.")
+			// Validation: atlasProjectId and atlasClusterName (or atlasAllClusters) must be both set or both unset
+			clustersSet := len(atlasClusterNames) > 0 || atlasAllClusters
+			if (atlasProjectId != "" && !clustersSet) || (atlasProjectId == "" && clustersSet) {
+				fmt.Fprintln(os.Stderr, "Error: Both --atlasProjectId and --atlasClusterName (or --atlasAllClusters) must be set together, or neither.")
+				os.Exit(1)
+			}
+			if len(atlasClusterNames) > 0 && atlasAllClusters {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both --atlasClusterName and --atlasAllClusters.")
 				os.Exit(1)
 			}
 
@@ -109,15 +322,90 @@ You can provide input either as a file (as the first argument) or by piping logs
 				fmt.Fprintln(os.Stderr, "Error: Cannot provide both Atlas parameters and piped input. Please use only one input source.")
 				os.Exit(1)
 			}
-			if atlasParamsSet && outputFile == "" {
-				fmt.Fprintln(os.Stderr, "Error: When using Atlas parameters, --outputFile (-o) must be specified.")
+			if dryRun && outputFile != "" {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both --dryRun and --outputFile. A dry run writes no log output.")
+				os.Exit(1)
+			}
+			if follow && (atlasParamsSet || len(args) == 0) {
+				fmt.Fprintln(os.Stderr, "Error: --follow requires an input file.")
+				os.Exit(1)
+			}
+			if follow && dryRun {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both --follow and --dryRun.")
+				os.Exit(1)
+			}
+			if !follow && (checkpointFile != "" || outputMaxSizeMB != 0) {
+				fmt.Fprintln(os.Stderr, "Error: --checkpointFile and --outputMaxSizeMB can only be used with --follow.")
+				os.Exit(1)
+			}
+			if outputMaxSizeMB != 0 && outputFile == "" {
+				fmt.Fprintln(os.Stderr, "Error: --outputMaxSizeMB requires --outputFile (-o).")
+				os.Exit(1)
+			}
+			// Validation: Ops Manager logs are collected for a resource of a project
+			if opsManagerParamsSet && (opsManagerProjectId == "" || opsManagerResource == "") {
+				fmt.Fprintln(os.Stderr, "Error: Both --opsManagerProjectId and --opsManagerResourceName must be set to collect Ops Manager logs.")
+				os.Exit(1)
+			}
+			if opsManagerParamsSet && (atlasParamsSet || len(args) == 1 || stdinHasData || inputDir != "") {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both Ops Manager parameters and another input source. Please use only one input source.")
+				os.Exit(1)
+			}
+			if opsManagerTimeout <= 0 {
+				fmt.Fprintln(os.Stderr, "Error: --opsManagerTimeout must be positive.")
+				os.Exit(1)
+			}
+			bundleInput := inputDir
+			if len(args) == 1 && IsArchive(args[0]) {
+				bundleInput = args[0]
+			}
+			// The archive of an Ops Manager log collection job is redacted like an archive input.
+			bundleOutput := bundleInput != "" || opsManagerParamsSet
+			if inputDir != "" && (len(args) == 1 || stdinHasData || atlasParamsSet) {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both --inputDir and another input source. Please use only one input source.")
+				os.Exit(1)
+			}
+			if !bundleOutput && outputDir != "" {
+				fmt.Fprintln(os.Stderr, "Error: --outputDir can only be used with --inputDir, an archive input or Ops Manager parameters.")
+				os.Exit(1)
+			}
+			if bundleInput != "" && (follow || resume) {
+				fmt.Fprintln(os.Stderr, "Error: --follow and --resume cannot be used with --inputDir or an archive input.")
+				os.Exit(1)
+			}
+			if bundleOutput && outputFile != "" && (outputDir != "" || !IsArchive(outputFile)) {
+				fmt.Fprintln(os.Stderr, "Error: With --inputDir, an archive input or Ops Manager parameters, use either --outputDir or an --outputFile ending in .tar, .tar.gz or .tgz.")
+				os.Exit(1)
+			}
+			if bundleOutput && outputFile == "" && outputDir == "" && !dryRun {
+				fmt.Fprintln(os.Stderr, "Error: With --inputDir, an archive input or Ops Manager parameters, --outputDir or --outputFile (-o) must be specified.")
+				os.Exit(1)
+			}
+			if resume && (atlasParamsSet || len(args) == 0 || outputFile == "" || follow || dryRun) {
+				fmt.Fprintln(os.Stderr, "Error: --resume requires an input file and --outputFile (-o), and cannot be used with --follow or --dryRun.")
+				os.Exit(1)
+			}
+			if outputTemplate != "" && (!atlasParamsSet || atlasMergeOutput) {
+				fmt.Fprintln(os.Stderr, "Error: --outputTemplate only names the outputs of Atlas logs, and cannot be used with --atlasMergeOutput.")
+				os.Exit(1)
+			}
+			if err := ValidateOutputTemplate(outputTemplate); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			if atlasParamsSet && outputFile == "" && outputTemplate == "" && !dryRun {
+				fmt.Fprintln(os.Stderr, "Error: When using Atlas parameters, --outputFile (-o) or --outputTemplate must be specified.")
+				os.Exit(1)
+			}
+			if atlasMergeOutput && outputFile == "" && !dryRun {
+				fmt.Fprintln(os.Stderr, "Error: --atlasMergeOutput requires --outputFile (-o).")
 				os.Exit(1)
 			}
 			if !atlasParamsSet && len(args) == 1 && stdinHasData {
 				fmt.Fprintln(os.Stderr, "Error: Cannot provide both a file and piped input. Please provide only one source.")
 				os.Exit(1)
 			}
-			if encrypt && (stdinHasData || outputFile == "") && !atlasParamsSet {
+			if encrypt && (stdinHasData || (outputFile == "" && outputDir == "")) && !atlasParamsSet {
 				fmt.Fprintln(os.Stderr, "Error: --encrypt cannot be used with stdin or stdout. Please specify input and output files when using encryption.")
 				os.Exit(1)
 			}
@@ -125,57 +413,124 @@ You can provide input either as a file (as the first argument) or by piping logs
 				inputFile = args[0]
 			} else if stdinHasData {
 				useStdin = true
-			} else if !atlasParamsSet {
-				fmt.Fprintln(os.Stderr, "Error: No input provided. Please specify a file, pipe data to stdin, or use Atlas parameters.")
+			} else if !atlasParamsSet && !opsManagerParamsSet && inputDir == "" {
+				fmt.Fprintln(os.Stderr, "Error: No input provided. Please specify a file, pipe data to stdin, or use Atlas or Ops Manager parameters.")
 				os.Exit(1)
 			}
 
-			SetRedactedString(replacement)
-			SetRedactNumbers(redactNumbers)
-			SetRedactIPs(redactIPs)
-			SetRedactBooleans(redactBooleans)
-			SetEagerRedactionPaths(eagerRedactionPaths)
+			applyRedactionOptions()
+			SetOutputIndent(indent)
+			SetEntryFormat(format)
 			SetAtlasLogStartDate(atlasLogStartDate)
 			SetAtlasLogEndDate(atlasLogEndDate)
-			SetRedactNamespaces(redactNamespaces)
-			SetRedactedFieldsRegexp(redactedFieldsRegexp)
+			defer PrintUnknownOperatorsSummary(os.Stderr)
+			if reportFile != "" || dryRun {
+				EnableRedactionReport()
+				defer func() {
+					if err := WriteRedactionReport(reportFile); err != nil {
+						fmt.Fprintf(os.Stderr, "Error writing redaction report: %v\n", err)
+					}
+				}()
+			}
 
-			var outWriter *os.File
-			var err error
-			if outputFile != "" {
-				outWriter, err = os.Create(outputFile)
+			applyEncryptionOptions()
+
+			// --- Follow mode ---
+			if follow {
+				ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+				defer stop()
+				opts := FollowOptions{
+					CheckpointFile: checkpointFile,
+					OutputFile:     outputFile,
+					MaxOutputBytes: int64(outputMaxSizeMB) * 1024 * 1024,
+					MaxOutputFiles: outputMaxFiles,
+				}
+				if err := FollowMongoLogFile(ctx, inputFile, os.Stdout, opts); err != nil {
+					fmt.Fprintf(os.Stderr, "Error following log file: %v\n", err)
+					os.Exit(1)
+				}
+				return
+			}
+
+			// --- Ops Manager mode ---
+			if opsManagerParamsSet {
+				publicKey := opsManagerPublicKey
+				privateKey := opsManagerPrivateKey
+				if publicKey == "" {
+					publicKey = os.Getenv("OPS_MANAGER_PUBLIC_KEY")
+				}
+				if privateKey == "" {
+					privateKey = os.Getenv("OPS_MANAGER_PRIVATE_KEY")
+				}
+				if publicKey == "" || privateKey == "" {
+					fmt.Fprintln(os.Stderr, "Error: Ops Manager public/private key not set. Please provide --opsManagerPublicKey and --opsManagerPrivateKey or set OPS_MANAGER_PUBLIC_KEY and OPS_MANAGER_PRIVATE_KEY environment variables.")
+					os.Exit(1)
+				}
+				client := NewAtlasClient(nil)
+				client.BaseURL = strings.TrimRight(opsManagerUrl, "/")
+				client.MaxRetries = atlasMaxRetries
+				ctx, cancel := context.WithTimeout(cmd.Context(), opsManagerTimeout)
+				archivePath, err := client.CollectLogs(ctx, publicKey, privateKey, opsManagerProjectId, LogCollectionRequest{
+					ResourceType:      resourceType,
+					ResourceName:      opsManagerResource,
+					LogTypesToCollect: collectedLogTypes,
+				})
+				cancel()
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error opening output file: %v\n", err)
+					fmt.Fprintf(os.Stderr, "Error collecting Ops Manager logs: %v\n", err)
 					os.Exit(1)
 				}
-				defer outWriter.Close()
-			} else {
-				outWriter = os.Stdout
+				defer os.Remove(archivePath)
+				bundleInput = archivePath
 			}
 
-			if encrypt && encryptionKeyFile != "" {
-				SetShouldEncrypt(encrypt)
-				keyfileExists := FileExists(encryptionKeyFile)
-				if !keyfileExists {
-					newKey, err := GenerateKey()
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Error generating encryption key: %v\n", err)
-						os.Exit(1)
+			// --- Bundle mode ---
+			if bundleInput != "" {
+				policy, err := ParseNonLogFilePolicy(nonLogFiles)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				output := outputDir
+				if outputFile != "" {
+					output = outputFile
+				}
+				if dryRun {
+					output = ""
+				}
+				result, err := RedactBundle(bundleInput, output, policy)
+				if result != nil {
+					for _, file := range result.Unrecognized {
+						fmt.Fprintf(os.Stderr, "Warning: %s is named like a log file, but isn't a MongoDB structured log; treated as a non-log file\n", file)
 					}
-					err = WriteKeyToFile(encryptionKeyFile, newKey)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Error writing encryption key to file: %v\n", err)
-						os.Exit(1)
+					for _, file := range result.Undetermined {
+						fmt.Fprintf(os.Stderr, "Warning: %s is compressed or archived, and its content couldn't be recognized; excluded, since it may hold logs\n", file)
 					}
-					SetEncryptionKey(newKey)
-				} else {
-					key, err := ReadKeyFromFile(encryptionKeyFile)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Error reading encryption key file: %v\n", err)
-						os.Exit(1)
+					for _, file := range result.Excluded {
+						fmt.Fprintf(os.Stderr, "Excluded non-log file: %s\n", file)
 					}
-					SetEncryptionKey(key)
+					fmt.Fprintf(os.Stderr, "Redacted %d log file(s), copied %d and excluded %d non-log file(s)\n", len(result.Redacted), len(result.Copied), len(result.Excluded)+len(result.Undetermined))
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error redacting %s: %v\n", bundleInput, err)
+					os.Exit(1)
 				}
+				return
+			}
+
+			// Redacting a file to a file records checkpoints, and writes the output itself.
+			resumable := inputFile != "" && outputFile != "" && !dryRun
+			var outWriter io.Writer = os.Stdout
+			if dryRun {
+				outWriter = io.Discard
+			} else if outputFile != "" && !resumable && !atlasParamsSet {
+				outFile, err := os.Create(outputFile)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error opening output file: %v\n", err)
+					os.Exit(1)
+				}
+				defer outFile.Close()
+				outWriter = outFile
 			}
 
 			// --- Atlas mode ---
@@ -188,119 +543,234 @@ You can provide input either as a file (as the first argument) or by piping logs
 				if privateKey == "" {
 					privateKey = os.Getenv("ATLAS_PRIVATE_KEY")
 				}
-				if publicKey == "" || privateKey == "" {
-					fmt.Fprintln(os.Stderr, "Error: Atlas public/private key not set. Please provide --atlasPublicKey and --atlasPrivateKey or set ATLAS_PUBLIC_KEY and ATLAS_PRIVATE_KEY environment variables.")
+				clientID := atlasClientId
+				clientSecret := atlasClientSecret
+				if clientID == "" {
+					clientID = os.Getenv("ATLAS_CLIENT_ID")
+				}
+				if clientSecret == "" {
+					clientSecret = os.Getenv("ATLAS_CLIENT_SECRET")
+				}
+				if (clientID == "") != (clientSecret == "") {
+					fmt.Fprintln(os.Stderr, "Error: Both the Atlas service account client ID and secret must be set. Please provide --atlasClientId and --atlasClientSecret or set ATLAS_CLIENT_ID and ATLAS_CLIENT_SECRET environment variables.")
+					os.Exit(1)
+				}
+				if clientID == "" && (publicKey == "" || privateKey == "") {
+					fmt.Fprintln(os.Stderr, "Error: Atlas public/private key not set. Please provide --atlasPublicKey and --atlasPrivateKey or set ATLAS_PUBLIC_KEY and ATLAS_PRIVATE_KEY environment variables, or use a service account with --atlasClientId and --atlasClientSecret.")
 					os.Exit(1)
 				}
 				client := NewAtlasClient(nil)
+				client.BaseURL = strings.TrimRight(atlasBaseUrl, "/")
+				// A service account is used over an API key when both are set.
+				client.ClientID = clientID
+				client.ClientSecret = clientSecret
+				client.Concurrency = atlasConcurrency
+				client.MaxRetries = atlasMaxRetries
+				client.AllowPartial = atlasAllowPartial
+				client.ChunkSize = atlasChunkSize
 				start, end := GetStartAndEndDates()
-				files, err := client.DownloadClusterLogs(cmd.Context(), publicKey, privateKey, atlasProjectId, atlasClusterName, start, end)
+				clusterNames, err := client.ResolveClusterNames(cmd.Context(), publicKey, privateKey, atlasProjectId, atlasClusterNames, atlasAllClusters)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error downloading Atlas logs: %v\n", err)
+					fmt.Fprintf(os.Stderr, "Error resolving Atlas clusters: %v\n", err)
 					os.Exit(1)
 				}
-				// Always clean up downloaded log files, even if redaction fails
-				defer func() {
-					if delErr := client.DeleteClusterLogs(cmd.Context(), files); delErr != nil {
-						fmt.Fprintf(os.Stderr, "Error cleaning up Atlas log files: %v\n", delErr)
+				filter := HostFilter{Roles: hostRoles, Shards: atlasShards}
+				var files []AtlasLogFile
+				var fileReader FileReader = &DefaultFileReader{}
+				if atlasTempFiles {
+					files, err = client.DownloadClusterLogs(cmd.Context(), publicKey, privateKey, atlasProjectId, clusterNames, filter, logTypes, start, end)
+					var partialErr *PartialDownloadError
+					if errors.As(err, &partialErr) && len(files) > 0 {
+						// The failures are reported again once the other logs are redacted.
+						fmt.Fprintf(os.Stderr, "Warning: %v\nRedacting the %d other log(s).\n", partialErr, len(files))
+						defer fmt.Fprintf(os.Stderr, "Warning: these logs couldn't be downloaded and weren't redacted: %v\n", partialErr)
+					} else if err != nil {
+						fmt.Fprintf(os.Stderr, "Error downloading Atlas logs: %v\n", err)
+						os.Exit(1)
 					}
-				}()
-				fileReader := &DefaultFileReader{}
-				for i, file := range files {
-					// Compose output file path with serial integer
-					outPath := fmt.Sprintf("%s.%d", outputFile, i)
-					outWriter, err := os.Create(outPath)
+					// Always clean up downloaded log files, even if redaction fails
+					defer func() {
+						if delErr := client.DeleteClusterLogs(cmd.Context(), files); delErr != nil {
+							fmt.Fprintf(os.Stderr, "Error cleaning up Atlas log files: %v\n", delErr)
+						}
+					}()
+				} else {
+					// The logs are redacted as they're streamed, without temporary files.
+					files, err = client.ListClusterLogs(cmd.Context(), publicKey, privateKey, atlasProjectId, clusterNames, filter, logTypes)
 					if err != nil {
-						fmt.Fprintf(os.Stderr, "Error opening output file %s: %v\n", outPath, err)
+						fmt.Fprintf(os.Stderr, "Error listing Atlas logs: %v\n", err)
 						os.Exit(1)
 					}
-					defer outWriter.Close()
-					// Progress bar logic per file
-					var bar *progressbar.ProgressBar
-					totalLines, err := countLines(fileReader, file)
+					streams := client.NewLogStreams(cmd.Context(), publicKey, privateKey, atlasProjectId, files, start, end)
+					defer streams.Close()
+					fileReader = streams
+				}
+				if atlasMergeOutput {
+					var outWriter io.Writer = io.Discard
+					closeOutput := func() error { return nil }
+					if !dryRun {
+						outWriter, closeOutput, err = CreateOutputFile(outputFile)
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "Error opening output file: %v\n", err)
+							os.Exit(1)
+						}
+					}
+					err := MergeAtlasLogs(fileReader, files, outWriter)
+					if closeErr := closeOutput(); err == nil {
+						err = closeErr
+					}
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "Error merging Atlas logs: %v\n", err)
+						os.Exit(1)
+					}
+					return
+				}
+				outputNames := AtlasOutputNames{
+					Template:   outputTemplate,
+					OutputFile: outputFile,
+					ProjectID:  atlasProjectId,
+					StartDate:  start,
+					EndDate:    end,
+				}
+				outPaths, err := outputNames.Paths(files)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				var failures []LogDownloadFailure
+				for i, file := range files {
+					SetEntryFormat(file.LogType.EntryFormat())
+					progress := NewProgress(progressMode, os.Stderr, filepath.Base(file.Path), fileSize(file.Path))
+					// The log is opened before its output is created, so that a log that can't be
+					// streamed leaves no output behind.
+					input, err := openLogFileInput(fileReader, file.Path, progress)
+					if err == nil {
+						var outWriter io.Writer = io.Discard
+						closeOutput := func() error { return nil }
+						if !dryRun {
+							outWriter, closeOutput, err = CreateOutputFile(outPaths[i])
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "Error opening output file %s: %v\n", outPaths[i], err)
+								os.Exit(1)
+							}
+						}
+						err = redactLogStream(input, outWriter, progress)
+						input.Close()
+						if closeErr := closeOutput(); closeErr != nil {
+							fmt.Fprintf(os.Stderr, "Error writing output file %s: %v\n", outPaths[i], closeErr)
+							os.Exit(1)
+						}
+					}
+					if err != nil && atlasAllowPartial && !atlasTempFiles {
+						// The part of a log redacted before its stream failed is kept.
+						fmt.Fprintf(os.Stderr, "Warning: failed to stream %s logs for host %s: %v\n", file.LogType, file.Host, err)
+						failures = append(failures, LogDownloadFailure{Host: file.Host, LogType: file.LogType, Err: err})
+						continue
+					}
 					if err != nil {
-						fmt.Fprintf(os.Stderr, "Error counting lines in %s: %v\n", file, err)
+						fmt.Fprintf(os.Stderr, "Error processing %s log of %s: %v\n", file.LogType, file.Host, err)
 						os.Exit(1)
 					}
-					bar = progressbar.NewOptions64(int64(totalLines),
-						progressbar.OptionEnableColorCodes(true),
-						progressbar.OptionSetWidth(50),
-						progressbar.OptionSetDescription("Redacting..."),
-						progressbar.OptionSetTheme(progressbar.Theme{
-							Saucer:        "[green]=[reset]",
-							SaucerHead:    "[green]>[reset]",
-							SaucerPadding: " ",
-							BarStart:      "[",
-							BarEnd:        "]",
-						}),
-						progressbar.OptionSetRenderBlankState(true),
-						progressbar.OptionSetPredictTime(false),
-						progressbar.OptionShowCount(),
-						progressbar.OptionShowIts(),
-						progressbar.OptionSetItsString("entries"),
-						progressbar.OptionShowElapsedTimeOnFinish(),
-						progressbar.OptionOnCompletion(func() {
-							fmt.Fprintln(os.Stdout, "\n\nRedaction complete - finalizing output...")
-						}),
-						progressbar.OptionSetWriter(os.Stdout),
-						progressbar.OptionThrottle(250*time.Millisecond),
-					)
-					if err := ProcessMongoLogFile(fileReader, file, outWriter, bar); err != nil {
-						fmt.Fprintf(os.Stderr, "Error processing log file %s: %v\n", file, err)
-						outWriter.Close()
+				}
+				if len(failures) > 0 {
+					fmt.Fprintf(os.Stderr, "Warning: these logs couldn't be streamed and weren't redacted, or only in part: %v\n", &PartialDownloadError{Failures: failures})
+					if len(failures) == len(files) {
 						os.Exit(1)
 					}
-					outWriter.Close()
 				}
 				return
 			}
 
 			// --- Non-Atlas mode ---
 			if useStdin {
-				if err := ProcessMongoLogFileFromReader(os.Stdin, outWriter, nil); err != nil {
+				progress := NewInputProgress(progressMode, os.Stderr, "", os.Stdin)
+				if err := ProcessMongoLogFileFromReader(os.Stdin, outWriter, progress); err != nil {
 					fmt.Fprintf(os.Stderr, "Error processing stdin: %v\n", err)
 					os.Exit(1)
 				}
 			} else {
 				fileReader := &DefaultFileReader{}
-				var bar *progressbar.ProgressBar
-				if outputFile != "" {
-					totalLines, err := countLines(fileReader, inputFile)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "Error counting lines: %v\n", err)
+				progress := NewProgress(progressMode, os.Stderr, filepath.Base(inputFile), fileSize(inputFile))
+				if resumable {
+					ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+					defer stop()
+					if err := RedactMongoLogFileResumable(ctx, inputFile, outputFile, resume, progress); err != nil {
+						fmt.Fprintf(os.Stderr, "\nError processing log file: %v\n", err)
+						if FileExists(CheckpointPath(outputFile)) {
+							fmt.Fprintln(os.Stderr, "Progress was saved; run the same command with --resume to continue.")
+						}
 						os.Exit(1)
 					}
-					bar = progressbar.NewOptions64(int64(totalLines),
-						progressbar.OptionEnableColorCodes(true),
-						progressbar.OptionSetWidth(50),
-						progressbar.OptionSetDescription("Redacting MongoDB logs..."),
-						progressbar.OptionSetTheme(progressbar.Theme{
-							Saucer:        "[green]=[reset]",
-							SaucerHead:    "[green]>[reset]",
-							SaucerPadding: " ",
-							BarStart:      "[",
-							BarEnd:        "]",
-						}),
-						progressbar.OptionSetRenderBlankState(true),
-						progressbar.OptionSetPredictTime(false),
-						progressbar.OptionShowCount(),
-						progressbar.OptionShowIts(),
-						progressbar.OptionSetItsString("entries"),
-						progressbar.OptionShowElapsedTimeOnFinish(),
-						progressbar.OptionOnCompletion(func() {
-							fmt.Fprintln(os.Stdout, "\n\nRedaction complete - finalizing output...")
-						}),
-						progressbar.OptionSetWriter(os.Stdout),
-						progressbar.OptionThrottle(250*time.Millisecond),
-					)
+					return
 				}
-				if err := ProcessMongoLogFile(fileReader, inputFile, outWriter, bar); err != nil {
+				if err := ProcessMongoLogFile(fileReader, inputFile, outWriter, progress); err != nil {
 					fmt.Fprintf(os.Stderr, "Error processing log file: %v\n", err)
 					os.Exit(1)
 				}
 			}
 		},
 	}
+	var redactQueryCmd = &cobra.Command{
+		Use:   "redact-query [query]",
+		Short: "Redact a single query, update or aggregation pipeline",
+		Long: `Redact a single filter, update, or aggregation pipeline, e.g., copied from mongosh, the same way as
+it would be redacted in a log entry.
+
+The query is written in (extended) JSON, or in the relaxed syntax of mongosh, with unquoted field names,
+single-quoted strings, regular expression literals and helpers such as ObjectId() or ISODate(). It's printed
+back redacted in the same syntax. You can provide it either as the first argument or by piping it to stdin.`,
+		Args: cobra.MaximumNArgs(1),
+		Example: `
+	# Redact a filter copied from mongosh:
+	anonymongo redact-query "{ email: 'jdoe@example.com', createdAt: { \$gte: ISODate('2024-01-01') } }"
+
+	# Redact a pipeline, with the field names of a namespace:
+	anonymongo redact-query --namespace shop.orders --redactFieldNames 'shop.*' < pipeline.json
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			stat, _ := os.Stdin.Stat()
+			stdinHasData := (stat.Mode() & os.ModeCharDevice) == 0
+			if len(args) == 1 && stdinHasData {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both a query and piped input. Please provide only one source.")
+				os.Exit(1)
+			}
+			if len(args) == 0 && !stdinHasData {
+				fmt.Fprintln(os.Stderr, "Error: No query provided. Please pass it as an argument or pipe it to stdin.")
+				os.Exit(1)
+			}
+			if redactedFieldsRegexp != "" && len(eagerRedactionPaths) > 0 {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both --redactedFieldsRegexp and --redactFieldNames flags. Please use only one.")
+				os.Exit(1)
+			}
+			command, err := ParseQueryCommand(queryCommand)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			query := ""
+			if len(args) == 1 {
+				query = args[0]
+			} else {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+					os.Exit(1)
+				}
+				query = string(data)
+			}
+
+			applyRedactionOptions()
+			applyEncryptionOptions()
+			defer PrintUnknownOperatorsSummary(os.Stderr)
+			redacted, err := RedactQuery(query, QueryOptions{Namespace: queryNamespace, Command: command})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error redacting query: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(redacted)
+		},
+	}
+
 	var decryptCmd = &cobra.Command{
 		Use:   "decrypt <value>",
 		Short: "Decrypt a value using the provided key file",
@@ -332,6 +802,159 @@ You can provide input either as a file (as the first argument) or by piping logs
 		},
 	}
 
+	var operatorsCmd = &cobra.Command{
+		Use:   "operators",
+		Short: "Print the effective operator catalog",
+		Long: `Print the catalog of MongoDB operators that anonymongo recognizes, and how each of them is redacted,
+in the operator catalog file format. Use --operatorCatalog to preview the result of merging your own
+catalog file on top of the built-in one.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			catalog, err := LoadOperatorCatalog(nil)
+			if operatorsCatalogFile != "" {
+				catalog, err = LoadOperatorCatalogFile(operatorsCatalogFile)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error loading operator catalog: %v\n", err)
+				os.Exit(1)
+			}
+			out, err := catalog.MarshalIndent()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error rendering operator catalog: %v\n", err)
+				os.Exit(1)
+			}
+			os.Stdout.Write(out)
+		},
+	}
+
+	var scanCmd = &cobra.Command{
+		Use:   "scan [JSON file or gzipped MongoDB log file]",
+		Short: "Scan log files for sensitive values that survived redaction",
+		Long: `Scan a redacted (or raw) MongoDB log file for values that still look sensitive, before it's shared.
+
+Each entry is redacted again with the given redaction options, and every value or field name that would
+still change is reported, along with email addresses, IP addresses and known secrets found anywhere in
+the entry. Values are masked in the report, unless --showValues is set. The command exits with a non-zero
+status if anything is found.
+
+You can provide input either as a file (as the first argument) or by piping logs to stdin.`,
+		Args: cobra.MaximumNArgs(1),
+		Example: `
+	# Check a redacted file before sharing it:
+	anonymongo scan redacted.log --secretsFile ./secrets.txt
+
+	# Check a file redacted with --redactNumbers and --encrypt:
+	anonymongo scan redacted.log --redactNumbers --decryptionKeyFile ./anonymongo.enc.key
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			stat, _ := os.Stdin.Stat()
+			stdinHasData := (stat.Mode() & os.ModeCharDevice) == 0
+			if len(args) == 1 && stdinHasData {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both a file and piped input. Please provide only one source.")
+				os.Exit(1)
+			}
+			if len(args) == 0 && !stdinHasData {
+				fmt.Fprintln(os.Stderr, "Error: No input provided. Please specify a file or pipe data to stdin.")
+				os.Exit(1)
+			}
+
+			applyRedactionOptions()
+			applyEntryFormat()
+			secrets := scanSecrets
+			if scanSecretsFile != "" {
+				fileSecrets, err := ReadSecretsFile(scanSecretsFile)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					os.Exit(1)
+				}
+				secrets = append(secrets, fileSecrets...)
+			}
+			SetKnownSecrets(secrets)
+			SetScanShowValues(scanShowValues)
+			if scanDecryptionKeyFile != "" {
+				key, err := ReadKeyFromFile(scanDecryptionKeyFile)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error reading key file: %v\n", err)
+					os.Exit(1)
+				}
+				SetEncryptionKey(key)
+			}
+
+			var findings int
+			var err error
+			if len(args) == 1 {
+				findings, err = ScanMongoLogFile(&DefaultFileReader{}, args[0], os.Stdout)
+			} else {
+				findings, err = ScanMongoLogFromReader(os.Stdin, os.Stdout)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error scanning log file: %v\n", err)
+				os.Exit(1)
+			}
+			if findings > 0 {
+				fmt.Fprintf(os.Stderr, "Found %d potentially sensitive value(s)\n", findings)
+				os.Exit(1)
+			}
+			fmt.Fprintln(os.Stderr, "No sensitive values found")
+		},
+	}
+
+	var diffCmd = &cobra.Command{
+		Use:   "diff [JSON file or gzipped MongoDB log file]",
+		Short: "Show how redaction changes each log entry",
+		Long: `Redact MongoDB log files in memory and print every changed entry as a structural diff, with the
+JSON path, original value and redacted value of each change. Nothing is written to disk.
+
+Use it to tune redaction options, or a custom operator catalog, against real logs. You can provide input
+either as a file (as the first argument) or by piping logs to stdin.`,
+		Args: cobra.MaximumNArgs(1),
+		Example: `
+	# Show how slow queries on one collection are redacted:
+	anonymongo diff mongod.log --component COMMAND --namespace 'shop.orders'
+
+	# Preview a custom operator catalog on the first 1000 lines:
+	anonymongo diff mongod.log --lines 1-1000 --operatorCatalog ./operators.json
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			stat, _ := os.Stdin.Stat()
+			stdinHasData := (stat.Mode() & os.ModeCharDevice) == 0
+			if len(args) == 1 && stdinHasData {
+				fmt.Fprintln(os.Stderr, "Error: Cannot provide both a file and piped input. Please provide only one source.")
+				os.Exit(1)
+			}
+			if len(args) == 0 && !stdinHasData {
+				fmt.Fprintln(os.Stderr, "Error: No input provided. Please specify a file or pipe data to stdin.")
+				os.Exit(1)
+			}
+			lines, err := ParseLineRanges(diffLines)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			outStat, _ := os.Stdout.Stat()
+			opts := DiffOptions{
+				Components: diffComponents,
+				Namespaces: diffNamespaces,
+				Lines:      lines,
+				Color:      !diffNoColor && os.Getenv("NO_COLOR") == "" && (outStat.Mode()&os.ModeCharDevice) != 0,
+			}
+
+			applyRedactionOptions()
+			applyEntryFormat()
+			var changed int
+			if len(args) == 1 {
+				changed, err = DiffMongoLogFile(&DefaultFileReader{}, args[0], os.Stdout, opts)
+			} else {
+				changed, err = DiffMongoLogFromReader(os.Stdin, os.Stdout, opts)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error processing log file: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "%d changed entries\n", changed)
+		},
+	}
+
 	var versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Print the version number",
@@ -342,7 +965,11 @@ You can provide input either as a file (as the first argument) or by piping logs
 
 	// Add subcommands to the root command
 	rootCmd.AddCommand(redactCmd)
+	rootCmd.AddCommand(redactQueryCmd)
 	rootCmd.AddCommand(decryptCmd)
+	rootCmd.AddCommand(operatorsCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(diffCmd)
 	rootCmd.AddCommand(versionCmd)
 
 	var (
@@ -354,31 +981,119 @@ You can provide input either as a file (as the first argument) or by piping logs
 		redactIPsDesc           = "Redact network locations to 255.255.255.255:65535"
 		outputFileDesc          = "Write output to file instead of stdout"
 		eagerRedactionPathsDesc = `[EXPERIMENTAL] Specify namespaces whose field names should be redacted in
-addition to their values. The structure is a namespace; e.g., 'dbName.collName'`
+addition to their values. The structure is a namespace; e.g., 'dbName.collName', a database
+name ('dbName'), or a glob ('dbName.*')`
 		redactedFieldsRegexpDesc = `Specify a regular expression for field names to redact.
 PLEASE NOTE: Using this flag will not redact fields that don't match the pattern`
 		atlasProjectIdDesc   = "Atlas project ID, if reading logs from an Atlas cluster"
-		atlasClusterNameDesc = "Atlas cluster name, if reading logs from an Atlas cluster"
-		atlasPublicKeyDesc   = `Atlas API public key, if reading logs from an Atlas cluster
+		atlasClusterNameDesc = `Atlas cluster name, if reading logs from an Atlas cluster. Several clusters can be given,
+comma-separated or repeated, and names can be globs (e.g., 'prod-*')`
+		atlasAllClustersDesc = `Read the logs of every cluster of the Atlas project, except paused ones. Logs of several clusters
+are redacted together, so a name is hashed the same way in all of them`
+		atlasPublicKeyDesc = `Atlas API public key, if reading logs from an Atlas cluster
 (Environment variable ATLAS_PUBLIC_KEY)`
 		atlasPrivateKeyDesc = `Atlas API private key, if reading logs from an Atlas cluster
 (Environment variable ATLAS_PRIVATE_KEY)`
+		atlasClientIdDesc = `Client ID of an Atlas service account, to authenticate with OAuth access tokens instead of an API
+key (Environment variable ATLAS_CLIENT_ID)`
+		atlasClientSecretDesc = `Client secret of an Atlas service account (Environment variable ATLAS_CLIENT_SECRET)`
 		atlasLogStartDateDesc = `Atlas log start date in epoch seconds, if reading logs from an Atlas cluster.
 Extract the last 7 days if not provided`
 		atlasLogEndDateDesc = `Atlas log end date in epoch seconds, if reading logs from an Atlas cluster.
 Extract the last 7 days if not provided`
+		atlasSinceDesc = `Download the Atlas logs of this long before now, e.g., '36h', '90m' or '3d'`
+		atlasFromDesc  = `Download the Atlas logs from this time, e.g., '2025-06-01T00:00Z', '2025-06-01T12:30' or '2025-06-01'
+(UTC unless a time zone is given), to --to or now`
+		atlasToDesc = `Download the Atlas logs up to this time, in the same formats as --from. Without --from, the 7 days
+before it are downloaded`
+		atlasChunkSizeDesc = `Longest range of Atlas logs downloaded at once. Longer ranges are downloaded in chunks of this size,
+concatenated into one output per host without the lines the chunks share`
+		atlasLogTypesDesc = `Atlas logs to download from each host, comma-separated: 'mongodb', 'mongos', 'mongodb-audit-log'
+or 'mongos-audit-log'. Each is written to '<outputFile>.<host>.<log type>' (see --outputTemplate), and
+audit logs are redacted as audit events`
+		atlasHostRolesDesc = `Only download the logs of the hosts with these roles, comma-separated: 'primary', 'secondary',
+'mongos' or 'config'. The hosts of every shard member, config server and mongos are found with the
+Atlas processes API. By default, the logs of every host are downloaded`
+		atlasShardsDesc      = `Only download the logs of the hosts of these shards (or replica sets), comma-separated`
+		atlasConcurrencyDesc = `Number of Atlas logs requested at the same time. Streamed logs are still redacted one after the
+other, while the next ones are prepared by Atlas; with --atlasTempFiles, they're downloaded in full at the same time`
+		atlasMaxRetriesDesc = `Number of times an Atlas log download is retried after a network error, or when the API is
+rate limiting (429) or failing (5xx), with exponential backoff or after the delay it asks for (Retry-After).
+Interrupted downloads are resumed where they stopped when possible`
+		atlasAllowPartialDesc = `When some Atlas logs can't be downloaded, redact the others instead of failing, and report
+the hosts whose logs are missing`
+		atlasTempFilesDesc = `Download each Atlas log to a temporary file before redacting it, instead of redacting it as it's
+streamed, to download several logs in full at once (--atlasConcurrency), and retry downloads from the start when
+they can't be resumed. The temporary files hold unredacted logs until they're deleted`
+		atlasMergeOutputDesc = `Write the logs of every Atlas host to --outputFile as a single log, interleaved by timestamp, with
+the host of each entry in a 'host' field (hashed with --redactIPs)`
+		outputTemplateDesc = `Name the output of each Atlas log after a template instead of --outputFile, with the placeholders
+{project}, {cluster}, {host}, {role}, {logType}, {start} and {end}; e.g., '{cluster}/{host}/{logType}-{start}-{end}.log.gz'.
+Missing directories are created, and outputs ending in .gz are gzipped`
 		redactNamespacesDesc = "Redact database and collection names"
+		fieldRuleDesc        = `Keep, redact, or hash the values of an exact field path in matching namespaces,
+in the form '<namespace>:<field path>=<keep|redact|hash>'; e.g., 'shop.users:address.zip=keep'.
+Namespaces accept globs ('shop.*'), and field paths accept '*' for one segment and '**' for any
+number of segments. Later rules take precedence over earlier ones and over --redactFieldsRegexp.
+'hash' only pseudonymises values: it's keyed with the --encrypt key, or with a random key for the run,
+but equal values still get equal hashes`
+		reportFileDesc = `Write a JSON report of what redaction changed, with counts per namespace, log component and
+field path`
+		dryRunDesc = `Redact without writing any log output; print the redaction report to stdout, or to the
+--report file if provided`
+		followDesc = `Keep redacting lines appended to the input file, like 'tail -F', until interrupted. The file is
+followed across renames (mongod's logRotate) and read again from the start if it's truncated`
+		checkpointFileDesc = `With --follow, persist the position of the last redacted line to this file every few seconds,
+so a restart resumes where the previous run left off`
+		outputMaxSizeMBDesc = "With --follow, rotate the output file once it reaches this size in megabytes"
+		outputMaxFilesDesc  = "With --outputMaxSizeMB, the number of rotated output files to keep"
+		resumeDesc          = `Continue an interrupted redaction of an input file from the checkpoint it left next to the
+--outputFile, instead of starting over`
+		inputDirDesc = `Redact every MongoDB log in a directory (e.g., an extracted support bundle), recognized by its
+content; a .tar, .tar.gz or .tgz archive can be given as the input file instead`
+		outputDirDesc = `With --inputDir or an archive input, write the redacted files to this directory with the same
+structure. Use --outputFile with a .tar, .tar.gz or .tgz name to write an archive instead`
+		nonLogFilesDesc = `With --inputDir or an archive input, what to do with files that aren't MongoDB logs: 'exclude'
+them from the output, or 'copy' them as they are`
+		atlasBaseUrlDesc        = `Base URL of the Atlas Administration API, e.g., for Atlas for Government`
+		opsManagerUrlDesc       = `Base URL of Ops Manager, to collect logs with a log collection job. Cloud Manager by default`
+		opsManagerProjectIdDesc = `Ops Manager project ID, if collecting logs from Ops Manager or Cloud Manager. The logs are
+collected as an archive, which is redacted like an archive input (see --outputDir and --nonLogFiles)`
+		opsManagerResourceDesc = `Name of the cluster, replica set or process ('<host>:<port>') whose logs are collected`
+		opsManagerResTypeDesc  = `Type of the resource whose logs are collected: 'cluster', 'replicaset' or 'process'`
+		opsManagerLogTypesDesc = `Logs to collect, comma-separated: 'mongodb', 'automation_agent', 'monitoring_agent',
+'backup_agent' or 'ftdc'`
+		opsManagerPublicKeyDesc  = `Ops Manager API public key (Environment variable OPS_MANAGER_PUBLIC_KEY)`
+		opsManagerPrivateKeyDesc = `Ops Manager API private key (Environment variable OPS_MANAGER_PRIVATE_KEY)`
+		opsManagerTimeoutDesc    = `How long to wait for the log collection job to complete and its logs to download`
+		indentDesc               = `Pretty-print each redacted entry with this many spaces of indentation, e.g., to paste it back
+into a ticket. By default, each entry is written on a single line`
+		entryFormatDesc = `What the input holds: 'log' for MongoDB logs, 'profile' for profiler documents (e.g.,
+exported from system.profile with mongoexport), 'explain' for the output of explain(), 'currentOp' for
+the output of db.currentOp() or $currentOp, or 'audit' for JSON audit logs`
+		progressDesc = `How to report progress on stderr: 'auto' shows a bar when stderr is a terminal, 'bar',
+'json' writes one JSON progress event per line for wrappers to parse, or 'none'`
+		strictOperatorsDesc = `Redact the entire value of any '$'-prefixed key missing from the operator catalog, instead of
+treating it as a field name`
+		operatorCatalogDesc = `Path to a JSON operator catalog merged on top of the built-in one, to recognize operators
+added in newer MongoDB releases or change how they are redacted (see 'anonymongo operators')`
 	)
 	outputOptions := pflag.NewFlagSet("Output Options", pflag.ExitOnError)
 	atlasFlags := pflag.NewFlagSet("Atlas Options", pflag.ExitOnError)
+	opsManagerFlags := pflag.NewFlagSet("Ops Manager Options", pflag.ExitOnError)
 	redactionFlags := pflag.NewFlagSet("Redaction Options", pflag.ExitOnError)
 	encryptionFlags := pflag.NewFlagSet("Encryption Options", pflag.ExitOnError)
+	bundleFlags := pflag.NewFlagSet("Bundle Options", pflag.ExitOnError)
+	inputFlags := pflag.NewFlagSet("Input Options", pflag.ExitOnError)
 
 	flagGroups := map[string]*pflag.FlagSet{
 		outputOptions.Name():   outputOptions,
 		atlasFlags.Name():      atlasFlags,
+		opsManagerFlags.Name(): opsManagerFlags,
 		redactionFlags.Name():  redactionFlags,
 		encryptionFlags.Name(): encryptionFlags,
+		bundleFlags.Name():     bundleFlags,
+		inputFlags.Name():      inputFlags,
 	}
 
 	redactCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
@@ -405,80 +1120,90 @@ Extract the last 7 days if not provided`
 	encryptionFlags.BoolVarP(&encrypt, "encrypt", "y", false, encryptDesc)
 	redactionFlags.BoolVarP(&redactIPs, "redactIPs", "i", false, redactIPsDesc)
 	outputOptions.StringVarP(&outputFile, "outputFile", "o", "", outputFileDesc)
+	outputOptions.StringVarP(&outputTemplate, "outputTemplate", "", "", outputTemplateDesc)
+	outputOptions.StringVarP(&reportFile, "report", "", "", reportFileDesc)
+	outputOptions.BoolVarP(&dryRun, "dryRun", "", false, dryRunDesc)
+	outputOptions.BoolVarP(&resume, "resume", "", false, resumeDesc)
+	outputOptions.BoolVarP(&follow, "follow", "F", false, followDesc)
+	outputOptions.StringVarP(&checkpointFile, "checkpointFile", "", "", checkpointFileDesc)
+	outputOptions.IntVarP(&outputMaxSizeMB, "outputMaxSizeMB", "", 0, outputMaxSizeMBDesc)
+	outputOptions.IntVarP(&outputMaxFiles, "outputMaxFiles", "", 5, outputMaxFilesDesc)
+	outputOptions.IntVarP(&indent, "indent", "", 0, indentDesc)
+	outputOptions.StringVarP(&progressOutput, "progress", "", string(ProgressAuto), progressDesc)
 	redactionFlags.StringArrayVarP(&eagerRedactionPaths, "redactFieldNames", "f", nil, eagerRedactionPathsDesc)
 	redactionFlags.StringVarP(&redactedFieldsRegexp, "redactFieldsRegexp", "z", "", redactedFieldsRegexpDesc)
 	atlasFlags.StringVarP(&atlasProjectId, "atlasProjectId", "p", "", atlasProjectIdDesc)
-	atlasFlags.StringVarP(&atlasClusterName, "atlasClusterName", "c", "", atlasClusterNameDesc)
+	atlasFlags.StringSliceVarP(&atlasClusterNames, "atlasClusterName", "c", nil, atlasClusterNameDesc)
+	atlasFlags.BoolVarP(&atlasAllClusters, "atlasAllClusters", "", false, atlasAllClustersDesc)
 	atlasFlags.StringVarP(&atlasPublicKey, "atlasPublicKey", "", "", atlasPublicKeyDesc)
 	atlasFlags.StringVarP(&atlasPrivateKey, "atlasPrivateKey", "", "", atlasPrivateKeyDesc)
+	atlasFlags.StringVarP(&atlasClientId, "atlasClientId", "", "", atlasClientIdDesc)
+	atlasFlags.StringVarP(&atlasClientSecret, "atlasClientSecret", "", "", atlasClientSecretDesc)
 	atlasFlags.IntVarP(&atlasLogStartDate, "atlasLogStartDate", "s", 0, atlasLogStartDateDesc)
 	atlasFlags.IntVarP(&atlasLogEndDate, "atlasLogEndDate", "e", 0, atlasLogEndDateDesc)
+	atlasFlags.StringVarP(&atlasSince, "since", "", "", atlasSinceDesc)
+	atlasFlags.StringVarP(&atlasFrom, "from", "", "", atlasFromDesc)
+	atlasFlags.StringVarP(&atlasTo, "to", "", "", atlasToDesc)
+	atlasFlags.DurationVarP(&atlasChunkSize, "atlasChunkSize", "", defaultLogChunkSize, atlasChunkSizeDesc)
+	atlasFlags.StringSliceVarP(&atlasHostRoleNames, "atlasHostRoles", "", nil, atlasHostRolesDesc)
+	atlasFlags.StringSliceVarP(&atlasShards, "atlasShards", "", nil, atlasShardsDesc)
+	atlasFlags.IntVarP(&atlasConcurrency, "atlasConcurrency", "", defaultDownloadConcurrency, atlasConcurrencyDesc)
+	atlasFlags.IntVarP(&atlasMaxRetries, "atlasMaxRetries", "", defaultMaxRetries, atlasMaxRetriesDesc)
+	atlasFlags.BoolVarP(&atlasAllowPartial, "atlasAllowPartial", "", false, atlasAllowPartialDesc)
+	atlasFlags.BoolVarP(&atlasMergeOutput, "atlasMergeOutput", "", false, atlasMergeOutputDesc)
+	atlasFlags.BoolVarP(&atlasTempFiles, "atlasTempFiles", "", false, atlasTempFilesDesc)
+	atlasFlags.StringSliceVarP(&atlasLogTypeNames, "atlasLogTypes", "", []string{string(AtlasLogMongod)}, atlasLogTypesDesc)
 	redactionFlags.BoolVarP(&redactNamespaces, "redactNamespaces", "w", false, redactNamespacesDesc)
+	redactionFlags.StringArrayVarP(&fieldRuleSpecs, "fieldRule", "", nil, fieldRuleDesc)
+	redactionFlags.StringVarP(&operatorCatalogFile, "operatorCatalog", "", "", operatorCatalogDesc)
+	atlasFlags.StringVarP(&atlasBaseUrl, "atlasBaseUrl", "", atlasAPIBaseURL, atlasBaseUrlDesc)
+	opsManagerFlags.StringVarP(&opsManagerUrl, "opsManagerUrl", "", opsManagerBaseURL, opsManagerUrlDesc)
+	opsManagerFlags.StringVarP(&opsManagerProjectId, "opsManagerProjectId", "", "", opsManagerProjectIdDesc)
+	opsManagerFlags.StringVarP(&opsManagerResource, "opsManagerResourceName", "", "", opsManagerResourceDesc)
+	opsManagerFlags.StringVarP(&opsManagerResType, "opsManagerResourceType", "", "cluster", opsManagerResTypeDesc)
+	opsManagerFlags.StringSliceVarP(&opsManagerLogTypes, "opsManagerLogTypes", "", []string{"mongodb"}, opsManagerLogTypesDesc)
+	opsManagerFlags.StringVarP(&opsManagerPublicKey, "opsManagerPublicKey", "", "", opsManagerPublicKeyDesc)
+	opsManagerFlags.StringVarP(&opsManagerPrivateKey, "opsManagerPrivateKey", "", "", opsManagerPrivateKeyDesc)
+	opsManagerFlags.DurationVarP(&opsManagerTimeout, "opsManagerTimeout", "", 30*time.Minute, opsManagerTimeoutDesc)
+	bundleFlags.StringVarP(&inputDir, "inputDir", "", "", inputDirDesc)
+	bundleFlags.StringVarP(&outputDir, "outputDir", "", "", outputDirDesc)
+	bundleFlags.StringVarP(&nonLogFiles, "nonLogFiles", "", string(NonLogFilesExclude), nonLogFilesDesc)
+	redactionFlags.BoolVarP(&strictOperators, "strictOperators", "", false, strictOperatorsDesc)
+	inputFlags.StringVarP(&entryFormatName, "format", "", string(EntryFormatLog), entryFormatDesc)
 
 	redactCmd.Flags().AddFlagSet(outputOptions)
 	redactCmd.Flags().AddFlagSet(atlasFlags)
+	redactCmd.Flags().AddFlagSet(opsManagerFlags)
 	redactCmd.Flags().AddFlagSet(redactionFlags)
 	redactCmd.Flags().AddFlagSet(encryptionFlags)
+	redactCmd.Flags().AddFlagSet(bundleFlags)
+	redactCmd.Flags().AddFlagSet(inputFlags)
+	// Bind flags to the "redact-query" subcommand; it redacts queries with the same options as "redact"
+	redactQueryCmd.Flags().AddFlagSet(redactionFlags)
+	redactQueryCmd.Flags().AddFlagSet(encryptionFlags)
+	redactQueryCmd.Flags().StringVarP(&queryNamespace, "namespace", "", "", "Namespace the query runs on (e.g., 'shop.orders'), for --redactFieldNames and --fieldRule")
+	redactQueryCmd.Flags().StringVarP(&queryCommand, "command", "", "", "Command the query is used with: 'find' (a filter), 'aggregate' (a pipeline or a stage) or 'update'; guessed if not set")
 	// Bind flags to the "decrypt" subcommand
 	decryptCmd.Flags().StringVarP(&decryptionKeyFile, "decryptionKeyFile", "", "./anonymongo.enc.key", "Path to the AES256 encryption key file")
+	// Bind flags to the "operators" subcommand
+	operatorsCmd.Flags().StringVarP(&operatorsCatalogFile, "operatorCatalog", "", "", "Path to a JSON operator catalog to merge on top of the built-in one")
+	// Bind flags to the "diff" subcommand; it redacts entries with the same options as "redact"
+	diffCmd.Flags().AddFlagSet(redactionFlags)
+	diffCmd.Flags().AddFlagSet(inputFlags)
+	diffCmd.Flags().StringArrayVarP(&diffComponents, "component", "", nil, "Only show entries of this log component (e.g., COMMAND); may be repeated")
+	diffCmd.Flags().StringArrayVarP(&diffNamespaces, "namespace", "", nil, "Only show entries on this namespace, database, or glob (e.g., 'shop.*'); may be repeated")
+	diffCmd.Flags().StringVarP(&diffLines, "lines", "", "", "Only show entries on these lines, as a comma-separated list of numbers and ranges (e.g., '5,10-20,100-')")
+	diffCmd.Flags().BoolVarP(&diffNoColor, "noColor", "", false, "Disable colored output")
+	// Bind flags to the "scan" subcommand; it redacts entries again with the same options as "redact"
+	scanCmd.Flags().AddFlagSet(redactionFlags)
+	scanCmd.Flags().AddFlagSet(inputFlags)
+	scanCmd.Flags().StringArrayVarP(&scanSecrets, "secret", "", nil, "A known secret value to look for; may be repeated")
+	scanCmd.Flags().StringVarP(&scanSecretsFile, "secretsFile", "", "", "Path to a file of known secret values to look for, one per line")
+	scanCmd.Flags().StringVarP(&scanDecryptionKeyFile, "decryptionKeyFile", "", "", "Path to the AES256 encryption key file, if the log was redacted with --encrypt")
+	scanCmd.Flags().BoolVarP(&scanShowValues, "showValues", "", false, "Print the values found as they are, instead of masked")
 
 	if err := rootCmd.Execute(); err != nil {
 		// Cobra already prints the error, so we don't need to double-print it.
 		os.Exit(1)
 	}
 }
-
-// countLines returns the number of lines in a file using a FileReader.
-func countLines(fileReader FileReader, filename string) (int, error) {
-	f, err := fileReader.Open(filename)
-	if err != nil {
-		return 0, err
-	}
-	defer f.Close()
-
-	buf := make([]byte, 32*1024)
-	count := 0
-	lineSep := []byte{'\n'}
-
-	for {
-		c, err := f.Read(buf)
-		count += countOccurrences(buf[:c], lineSep)
-		switch {
-		case err == nil:
-		case err == io.EOF:
-			return count, nil
-		default:
-			return count, err
-		}
-	}
-}
-
-func countOccurrences(b, sep []byte) int {
-	count := 0
-	idx := 0
-	for {
-		i := indexOf(b[idx:], sep)
-		if i == -1 {
-			break
-		}
-		count++
-		idx += i + len(sep)
-	}
-	return count
-}
-
-func indexOf(b, sep []byte) int {
-	for i := 0; i+len(sep) <= len(b); i++ {
-		match := true
-		for j := range sep {
-			if b[i+j] != sep[j] {
-				match = false
-				break
-			}
-		}
-		if match {
-			return i
-		}
-	}
-	return -1
-}
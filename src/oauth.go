@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// atlasTokenPath is the path of the endpoint that issues access tokens to Atlas service accounts.
+const atlasTokenPath = "/api/oauth/token"
+
+// tokenExpiryMargin is how long before it expires an access token is replaced, so that it doesn't
+// expire during a request.
+const tokenExpiryMargin = time.Minute
+
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// serviceAccountTokens obtains the access tokens of an Atlas service account with the OAuth 2.0
+// client credentials flow, and replaces them before they expire. It's shared by the concurrent
+// requests of a client.
+type serviceAccountTokens struct {
+	client       *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// Token returns a valid access token, requesting a new one if there's none yet or it's about to
+// expire.
+func (s *serviceAccountTokens) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Now().Before(s.expiry.Add(-tokenExpiryMargin)) {
+		return s.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to obtain an access token for the service account: unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	var token accessTokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+	if token.AccessToken == "" || (token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer")) {
+		return "", fmt.Errorf("unexpected token response: no bearer token")
+	}
+	s.token = token.AccessToken
+	s.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// invalidate discards token if it's still the current one, so that the next request gets a new one.
+func (s *serviceAccountTokens) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// bearerTransport authenticates requests with the access tokens of a service account. A request
+// rejected with 401, e.g., because its token was revoked, is sent once more with a new token if it
+// has no body to send again.
+type bearerTransport struct {
+	tokens    *serviceAccountTokens
+	transport http.RoundTripper
+}
+
+func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := t.tokens.Token(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		authReq := req.Clone(req.Context())
+		authReq.Header.Set("Authorization", "Bearer "+token)
+		resp, err := t.transport.RoundTrip(authReq)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized || attempt > 0 || req.Body != nil {
+			return resp, err
+		}
+		resp.Body.Close()
+		t.tokens.invalidate(token)
+	}
+}
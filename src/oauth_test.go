@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newServiceAccountServer stands in for the Atlas API with a token endpoint for the service account
+// 'client1', whose tokens expire after expiresIn seconds. The API only accepts the latest token,
+// unless revoked is set, in which case the first one is rejected too.
+func newServiceAccountServer(t *testing.T, expiresIn int, revoked bool) (*httptest.Server, *atomic.Int32) {
+	var tokenRequests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "client_credentials" || !ok || clientID != "client1" || clientSecret != "secret1" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		n := tokenRequests.Add(1)
+		fmt.Fprintf(w, `{"access_token":"token%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	})
+	mux.HandleFunc("/api/atlas/v2/groups/project1/clusters/cluster1", func(w http.ResponseWriter, r *http.Request) {
+		latest := fmt.Sprintf("Bearer token%d", tokenRequests.Load())
+		if r.Header.Get("Authorization") != latest || (revoked && latest == "Bearer token1") {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"connectionStrings":{"standard":"mongodb://cluster1-shard-00-00.abcde.mongodb.net:27017"}}`))
+	})
+	return httptest.NewServer(mux), &tokenRequests
+}
+
+func TestServiceAccountAuthentication(t *testing.T) {
+	tests := []struct {
+		name              string
+		expiresIn         int
+		revoked           bool
+		requests          int
+		wantTokenRequests int32
+	}{
+		{name: "token reused", expiresIn: 3600, requests: 3, wantTokenRequests: 1},
+		{name: "token about to expire", expiresIn: 30, requests: 3, wantTokenRequests: 3},
+		{name: "token revoked", expiresIn: 3600, revoked: true, requests: 2, wantTokenRequests: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, tokenRequests := newServiceAccountServer(t, tt.expiresIn, tt.revoked)
+			defer server.Close()
+			client := NewAtlasClient(server.Client())
+			client.BaseURL = server.URL
+			client.ClientID = "client1"
+			client.ClientSecret = "secret1"
+
+			for range tt.requests {
+				if _, err := client.getAtlasClusterInfo(context.Background(), "", "", "project1", "cluster1"); err != nil {
+					t.Fatalf("getAtlasClusterInfo returned an unexpected error: %v", err)
+				}
+			}
+			if got := tokenRequests.Load(); got != tt.wantTokenRequests {
+				t.Errorf("Expected %d token requests, got %d", tt.wantTokenRequests, got)
+			}
+		})
+	}
+}
+
+func TestServiceAccountAuthentication_Concurrent(t *testing.T) {
+	server, tokenRequests := newServiceAccountServer(t, 3600, false)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+	client.ClientID = "client1"
+	client.ClientSecret = "secret1"
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.getAtlasClusterInfo(context.Background(), "", "", "project1", "cluster1"); err != nil {
+				t.Errorf("getAtlasClusterInfo returned an unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := tokenRequests.Load(); got != 1 {
+		t.Errorf("Expected the requests to share a token, got %d token requests", got)
+	}
+}
+
+func TestServiceAccountAuthentication_InvalidCredentials(t *testing.T) {
+	server, _ := newServiceAccountServer(t, 3600, false)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+	client.ClientID = "client1"
+	client.ClientSecret = "wrong"
+
+	_, err := client.getAtlasClusterInfo(context.Background(), "", "", "project1", "cluster1")
+	if err == nil || !strings.Contains(err.Error(), "failed to obtain an access token for the service account: unexpected status 401") {
+		t.Errorf("Expected the token request to fail, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// opsManagerBaseURL is the base URL of Cloud Manager; Ops Manager is hosted by its users.
+const opsManagerBaseURL = "https://cloud.mongodb.com"
+
+const (
+	// defaultLogCollectionPollInterval is how often the status of a log collection job is checked.
+	defaultLogCollectionPollInterval = 5 * time.Second
+	// logCollectionSizePerFile is the size of the logs collected from each file of each process.
+	logCollectionSizePerFile = 100 * 1024 * 1024
+)
+
+// LogCollectionResourceType is the kind of resource whose logs a log collection job collects.
+type LogCollectionResourceType string
+
+const (
+	LogCollectionCluster    LogCollectionResourceType = "CLUSTER"
+	LogCollectionReplicaSet LogCollectionResourceType = "REPLICASET"
+	LogCollectionProcess    LogCollectionResourceType = "PROCESS"
+)
+
+var logCollectionResourceTypes = []LogCollectionResourceType{LogCollectionCluster, LogCollectionReplicaSet, LogCollectionProcess}
+
+// ParseLogCollectionResourceType returns the resource type for its name, e.g., 'cluster'.
+func ParseLogCollectionResourceType(name string) (LogCollectionResourceType, error) {
+	resourceType := LogCollectionResourceType(strings.ToUpper(strings.TrimSpace(name)))
+	if !slices.Contains(logCollectionResourceTypes, resourceType) {
+		return "", fmt.Errorf("invalid resource type %q (expected 'cluster', 'replicaset' or 'process')", name)
+	}
+	return resourceType, nil
+}
+
+var logCollectionLogTypes = []string{"MONGODB", "AUTOMATION_AGENT", "MONITORING_AGENT", "BACKUP_AGENT", "FTDC"}
+
+// ParseLogCollectionLogTypes returns the log types a log collection job collects for their names,
+// e.g., 'mongodb' or 'automation_agent'.
+func ParseLogCollectionLogTypes(names []string) ([]string, error) {
+	var logTypes []string
+	for _, name := range names {
+		logType := strings.ToUpper(strings.TrimSpace(name))
+		if !slices.Contains(logCollectionLogTypes, logType) {
+			return nil, fmt.Errorf("invalid log type %q (expected 'mongodb', 'automation_agent', 'monitoring_agent', 'backup_agent' or 'ftdc')", name)
+		}
+		if !slices.Contains(logTypes, logType) {
+			logTypes = append(logTypes, logType)
+		}
+	}
+	return logTypes, nil
+}
+
+// LogCollectionRequest is what a log collection job collects: the logs of a cluster, a replica set
+// or a process ('<host>:<port>'), by name.
+type LogCollectionRequest struct {
+	ResourceType              LogCollectionResourceType `json:"resourceType"`
+	ResourceName              string                    `json:"resourceName"`
+	Redacted                  bool                      `json:"redacted"`
+	SizeRequestedPerFileBytes int64                     `json:"sizeRequestedPerFileBytes"`
+	LogTypesToCollect         []string                  `json:"logTypesToCollect"`
+}
+
+type logCollectionJob struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// CollectLogs collects logs with an Ops Manager (or Cloud Manager) log collection job: it creates
+// the job, waits for Ops Manager to gather the logs from the agents, and downloads the archive it
+// produces to a temporary .tar.gz file, whose path it returns. The job isn't deleted; Ops Manager
+// expires it.
+func (c *AtlasClient) CollectLogs(ctx context.Context, publicKey, privateKey, projectID string, request LogCollectionRequest) (string, error) {
+	if request.SizeRequestedPerFileBytes == 0 {
+		request.SizeRequestedPerFileBytes = logCollectionSizePerFile
+	}
+	fmt.Fprintf(os.Stdout, "Creating a log collection job for %s %s...\n", strings.ToLower(string(request.ResourceType)), request.ResourceName)
+	jobID, err := c.createLogCollectionJob(ctx, publicKey, privateKey, projectID, request)
+	if err != nil {
+		return "", fmt.Errorf("failed to create log collection job: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "Waiting for log collection job %s...\n", jobID)
+	if err := c.waitForLogCollectionJob(ctx, publicKey, privateKey, projectID, jobID); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(os.Stdout, "Downloading the logs of log collection job %s...\n", jobID)
+	url := fmt.Sprintf("%s/api/public/v1.0/groups/%s/logCollectionJobs/%s/download", c.BaseURL, projectID, jobID)
+	header := http.Header{}
+	header.Set("Accept", "application/gzip")
+	tmpFile, err := os.CreateTemp("", fmt.Sprintf("logCollectionJob_%s_*.tar.gz", jobID))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer tmpFile.Close()
+	if err := c.downloadToFile(ctx, c.authenticatedClient(publicKey, privateKey), url, header, tmpFile); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("failed to download the logs of log collection job %s: %w", jobID, err)
+	}
+	return tmpFile.Name(), nil
+}
+
+func (c *AtlasClient) createLogCollectionJob(ctx context.Context, publicKey, privateKey, projectID string, request LogCollectionRequest) (string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	url := fmt.Sprintf("%s/api/public/v1.0/groups/%s/logCollectionJobs", c.BaseURL, projectID)
+	var job logCollectionJob
+	if err := c.doOpsManagerRequest(ctx, publicKey, privateKey, http.MethodPost, url, body, &job); err != nil {
+		return "", err
+	}
+	if job.ID == "" {
+		return "", fmt.Errorf("no job ID in response")
+	}
+	return job.ID, nil
+}
+
+// waitForLogCollectionJob checks the status of the job every c.LogCollectionPollInterval until
+// it succeeds, fails, or ctx is done.
+func (c *AtlasClient) waitForLogCollectionJob(ctx context.Context, publicKey, privateKey, projectID, jobID string) error {
+	pollInterval := c.LogCollectionPollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultLogCollectionPollInterval
+	}
+	url := fmt.Sprintf("%s/api/public/v1.0/groups/%s/logCollectionJobs/%s", c.BaseURL, projectID, jobID)
+	for {
+		var job logCollectionJob
+		if err := c.doOpsManagerRequest(ctx, publicKey, privateKey, http.MethodGet, url, nil, &job); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("log collection job %s didn't complete: %w", jobID, ctx.Err())
+			}
+			return fmt.Errorf("failed to get status of log collection job %s: %w", jobID, err)
+		}
+		switch job.Status {
+		case "SUCCESS":
+			return nil
+		case "FAILURE", "MARKED_FOR_EXPIRY", "EXPIRED":
+			return fmt.Errorf("log collection job %s ended with status %s", jobID, job.Status)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("log collection job %s didn't complete: %w", jobID, ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// doOpsManagerRequest sends a request with a JSON body, if any, to the Ops Manager API, and
+// unmarshals its JSON response into result.
+func (c *AtlasClient) doOpsManagerRequest(ctx context.Context, publicKey, privateKey, method, url string, body []byte, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.authenticatedClient(publicKey, privateKey).Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(respBody))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
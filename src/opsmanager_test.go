@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const opsManagerLogLine = `{"t":{"$date":"2025-10-01T10:00:00.000+00:00"},"s":"I","c":"COMMAND","id":51803,"ctx":"conn1","msg":"Slow query","attr":{"type":"command","ns":"shop.users","command":{"find":"users","filter":{"name":"alice"}}}}`
+
+// newLogCollectionArchive returns a gzipped tar archive laid out like the ones of log collection
+// jobs, with a mongod log and an agent log.
+func newLogCollectionArchive(t *testing.T) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	files := map[string]string{
+		"cluster0/host1_27017/mongodb.log":                  opsManagerLogLine + "\n",
+		"cluster0/host1_27017/automation-agent-verbose.log": "[2025-10-01T10:00:00.000+0000] [.info] started\n",
+	}
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// newOpsManagerServer stands in for the Ops Manager API of project1. Log collection jobs are in
+// progress on their first status check, and then end with finalStatus.
+func newOpsManagerServer(t *testing.T, finalStatus string) *httptest.Server {
+	archive := newLogCollectionArchive(t)
+	var statusChecks atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/public/v1.0/groups/project1/logCollectionJobs", func(w http.ResponseWriter, r *http.Request) {
+		var request LogCollectionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.ResourceType != LogCollectionCluster || request.ResourceName != "cluster0" || request.SizeRequestedPerFileBytes == 0 || len(request.LogTypesToCollect) != 2 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"job1"}`))
+	})
+	mux.HandleFunc("GET /api/public/v1.0/groups/project1/logCollectionJobs/job1", func(w http.ResponseWriter, r *http.Request) {
+		status := "IN_PROGRESS"
+		if statusChecks.Add(1) > 1 {
+			status = finalStatus
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": "job1", "status": status})
+	})
+	mux.HandleFunc("GET /api/public/v1.0/groups/project1/logCollectionJobs/job1/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	})
+	return httptest.NewServer(mux)
+}
+
+func newTestOpsManagerClient(server *httptest.Server) *AtlasClient {
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+	client.LogCollectionPollInterval = time.Millisecond
+	return client
+}
+
+func TestCollectLogs(t *testing.T) {
+	server := newOpsManagerServer(t, "SUCCESS")
+	defer server.Close()
+	client := newTestOpsManagerClient(server)
+
+	archivePath, err := client.CollectLogs(context.Background(), "pubKey", "privKey", "project1", LogCollectionRequest{
+		ResourceType:      LogCollectionCluster,
+		ResourceName:      "cluster0",
+		LogTypesToCollect: []string{"MONGODB", "AUTOMATION_AGENT"},
+	})
+	if err != nil {
+		t.Fatalf("CollectLogs returned an unexpected error: %v", err)
+	}
+	defer os.Remove(archivePath)
+
+	outputDir := t.TempDir()
+	result, err := RedactBundle(archivePath, outputDir, NonLogFilesExclude)
+	if err != nil {
+		t.Fatalf("RedactBundle returned an unexpected error: %v", err)
+	}
+	if len(result.Redacted) != 1 || len(result.Excluded) != 1 {
+		t.Errorf("Expected 1 redacted and 1 excluded file, got %+v", result)
+	}
+	redacted, err := os.ReadFile(filepath.Join(outputDir, "cluster0", "host1_27017", "mongodb.log"))
+	if err != nil {
+		t.Fatalf("Failed to read the redacted log: %v", err)
+	}
+	if strings.Contains(string(redacted), "alice") || !strings.Contains(string(redacted), `"name":"REDACTED"`) {
+		t.Errorf("Expected the log to be redacted, got %s", redacted)
+	}
+}
+
+func TestCollectLogs_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		finalStatus string
+		timeout     time.Duration
+		wantErr     string
+	}{
+		{name: "job failed", finalStatus: "FAILURE", timeout: time.Minute, wantErr: "log collection job job1 ended with status FAILURE"},
+		{name: "job expired", finalStatus: "EXPIRED", timeout: time.Minute, wantErr: "ended with status EXPIRED"},
+		{name: "timeout", finalStatus: "IN_PROGRESS", timeout: 50 * time.Millisecond, wantErr: "log collection job job1 didn't complete: context deadline exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newOpsManagerServer(t, tt.finalStatus)
+			defer server.Close()
+			client := newTestOpsManagerClient(server)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			_, err := client.CollectLogs(ctx, "pubKey", "privKey", "project1", LogCollectionRequest{
+				ResourceType:      LogCollectionCluster,
+				ResourceName:      "cluster0",
+				LogTypesToCollect: []string{"MONGODB", "AUTOMATION_AGENT"},
+			})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CollectLogs() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseLogCollectionOptions(t *testing.T) {
+	if resourceType, err := ParseLogCollectionResourceType("replicaset"); err != nil || resourceType != LogCollectionReplicaSet {
+		t.Errorf("ParseLogCollectionResourceType(replicaset) = %q, %v", resourceType, err)
+	}
+	if _, err := ParseLogCollectionResourceType("shard"); err == nil {
+		t.Errorf("Expected an invalid resource type to be rejected")
+	}
+	logTypes, err := ParseLogCollectionLogTypes([]string{"mongodb", "automation_agent", "MONGODB"})
+	if err != nil || strings.Join(logTypes, ",") != "MONGODB,AUTOMATION_AGENT" {
+		t.Errorf("ParseLogCollectionLogTypes() = %v, %v", logTypes, err)
+	}
+	if _, err := ParseLogCollectionLogTypes([]string{"mongos"}); err == nil {
+		t.Errorf("Expected an invalid log type to be rejected")
+	}
+}
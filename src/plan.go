@@ -0,0 +1,260 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// redactQueryPlan redacts a query plan, as found in the execStats of profiler documents and in
+// explain output, walking every section of it. In its stages, filters and index bounds hold
+// literal values; key patterns, index names and index bounds hold field names.
+func redactQueryPlan(node *orderedmap.OrderedMap[string, any], redactFieldNames bool) *orderedmap.OrderedMap[string, any] {
+	_, isStage := node.Get("stage")
+	for el := node.Front(); el != nil; el = el.Next() {
+		switch v := el.Value.(type) {
+		case *orderedmap.OrderedMap[string, any]:
+			switch {
+			case isStage && (el.Key == "filter" || el.Key == "transformBy"), el.Key == "parsedQuery":
+				node.Set(el.Key, redactQueryValues(v, redactFieldNames, false, nil, []string{}))
+			case isStage && el.Key == "indexBounds":
+				node.Set(el.Key, redactIndexBounds(v, redactFieldNames))
+			case isStage && (el.Key == "keyPattern" || el.Key == "sortPattern" || el.Key == "multiKeyPaths"):
+				if redactFieldNames {
+					node.Set(el.Key, hashFieldNames(v))
+				}
+			case el.Key == "command":
+				redactCommand(v, redactFieldNames)
+				if redactNamespaces {
+					redactNamespace(v)
+				}
+			case el.Key == "slotBasedPlan":
+				redactSlotBasedPlan(v, redactFieldNames)
+			default:
+				node.Set(el.Key, redactQueryPlan(v, redactFieldNames))
+			}
+		case []any:
+			switch el.Key {
+			case "stages":
+				node.Set(el.Key, redactExplainedPipeline(v, redactFieldNames))
+			case "shardsPart", "mergerPart":
+				node.Set(el.Key, redactPipeline(v, redactFieldNames))
+			default:
+				for i, item := range v {
+					if child, ok := item.(*orderedmap.OrderedMap[string, any]); ok {
+						v[i] = redactQueryPlan(child, redactFieldNames)
+					}
+				}
+			}
+		case string:
+			if isStage && el.Key == "indexName" && redactFieldNames {
+				node.Set(el.Key, HashName(v))
+			}
+			// The stages of slot-based plans print their filter as an expression.
+			if isStage && el.Key == "filter" {
+				node.Set(el.Key, redactSlotBasedPlanString(v, redactFieldNames))
+			}
+			if el.Key == "namespace" && redactNamespaces {
+				node.Set(el.Key, HashName(v))
+			}
+		}
+	}
+	return node
+}
+
+// redactExplainedPipeline redacts the stages of an explained aggregation, each of which holds an
+// aggregation stage next to its execution statistics. The query plan of the stages pushed down to
+// the query engine is under $cursor.
+func redactExplainedPipeline(stages []any, redactFieldNames bool) []any {
+	for i, item := range stages {
+		stage, ok := item.(*orderedmap.OrderedMap[string, any])
+		if !ok {
+			continue
+		}
+		redacted := orderedmap.NewOrderedMap[string, any]()
+		for el := stage.Front(); el != nil; el = el.Next() {
+			switch v := el.Value.(type) {
+			case *orderedmap.OrderedMap[string, any]:
+				if el.Key == "$cursor" {
+					redacted.Set(el.Key, redactQueryPlan(v, redactFieldNames))
+					continue
+				}
+				if strings.HasPrefix(el.Key, "$") {
+					single := orderedmap.NewOrderedMap[string, any]()
+					single.Set(el.Key, v)
+					for r := redactPipeline([]any{single}, redactFieldNames)[0].(*orderedmap.OrderedMap[string, any]).Front(); r != nil; r = r.Next() {
+						redacted.Set(r.Key, r.Value)
+					}
+					continue
+				}
+			}
+			redacted.Set(el.Key, el.Value)
+		}
+		stages[i] = redacted
+	}
+	return stages
+}
+
+// hashFieldNames hashes the keys of a key pattern, and the field paths listed in its values (as in
+// multiKeyPaths).
+func hashFieldNames(m *orderedmap.OrderedMap[string, any]) *orderedmap.OrderedMap[string, any] {
+	hashed := orderedmap.NewOrderedMap[string, any]()
+	for el := m.Front(); el != nil; el = el.Next() {
+		v := el.Value
+		if paths, ok := v.([]any); ok {
+			for i, p := range paths {
+				if s, ok := p.(string); ok {
+					paths[i] = HashName(s)
+				}
+			}
+		}
+		hashed.Set(HashName(el.Key), v)
+	}
+	return hashed
+}
+
+// redactIndexBounds redacts the literal values in the intervals of index bounds, e.g.,
+// {ssn: ["[\"123\", \"123\"]"]}, and their field names if redactFieldNames is set.
+func redactIndexBounds(bounds *orderedmap.OrderedMap[string, any], redactFieldNames bool) *orderedmap.OrderedMap[string, any] {
+	redacted := orderedmap.NewOrderedMap[string, any]()
+	for el := bounds.Front(); el != nil; el = el.Next() {
+		field := el.Key
+		v := el.Value
+		if intervals, ok := v.([]any); ok {
+			for i, interval := range intervals {
+				if s, ok := interval.(string); ok {
+					intervals[i] = redactIndexInterval(field, s)
+				}
+			}
+		}
+		if redactFieldNames {
+			field = HashName(field)
+		}
+		redacted.Set(field, v)
+	}
+	return redacted
+}
+
+// redactIndexInterval redacts both ends of an interval, such as '["a", "b")' or '[1, inf.0]'.
+// Intervals that can't be parsed are replaced entirely.
+func redactIndexInterval(field, interval string) string {
+	if len(interval) < 2 || !strings.ContainsRune("[(", rune(interval[0])) || !strings.ContainsRune("])", rune(interval[len(interval)-1])) {
+		return redactString(interval, redactedString)
+	}
+	bounds := splitIndexInterval(interval[1 : len(interval)-1])
+	if len(bounds) != 2 {
+		return redactString(interval, redactedString)
+	}
+	return interval[:1] + redactIndexBound(field, bounds[0]) + ", " + redactIndexBound(field, bounds[1]) + interval[len(interval)-1:]
+}
+
+// splitIndexInterval splits the inside of an interval at the comma separating its two ends,
+// ignoring commas inside strings, regular expressions and nested values.
+func splitIndexInterval(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '/' && depth == 0 && strings.TrimSpace(s[start:i]) == "":
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+var (
+	indexBoundNumberRegex   = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][-+]?\d+)?$`)
+	indexBoundObjectIdRegex = regexp.MustCompile(`^ObjectId\('([0-9a-fA-F]*)'\)$`)
+	indexBoundDateRegex     = regexp.MustCompile(`^new Date\((-?\d+)\)$`)
+	indexBoundRegexRegex    = regexp.MustCompile(`^/(.*)/([a-z]*)$`)
+)
+
+// indexBoundKeywords are the ends of intervals that don't hold a value, such as the ones bracketing
+// all values of a type.
+var indexBoundKeywords = []string{"MinKey", "MaxKey", "inf.0", "-inf.0", "nan", "{}", "[]", "null", "undefined", `""`, "new Date(9223372036854775807)", "new Date(-9223372036854775808)"}
+
+func redactIndexBound(field, bound string) string {
+	for _, keyword := range indexBoundKeywords {
+		if bound == keyword {
+			return bound
+		}
+	}
+	keyPath := []string{field}
+	switch {
+	case len(bound) >= 2 && bound[0] == '"' && bound[len(bound)-1] == '"':
+		s := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(bound[1 : len(bound)-1])
+		return quoteIndexBound(redactScalarValue(keyPath, s, false, false))
+	case indexBoundNumberRegex.MatchString(bound):
+		switch v := redactScalarValue(keyPath, json.Number(bound), false, false).(type) {
+		case string:
+			return quoteIndexBound(v)
+		case float64:
+			return "0"
+		}
+		return bound
+	case bound == "true" || bound == "false":
+		if v, ok := redactScalarValue(keyPath, bound == "true", false, false).(bool); ok {
+			if v {
+				return "true"
+			}
+			return "false"
+		}
+	}
+	if m := indexBoundObjectIdRegex.FindStringSubmatch(bound); m != nil {
+		return "ObjectId('" + redactBoundString(append(keyPath, "$oid"), m[1]) + "')"
+	}
+	if m := indexBoundDateRegex.FindStringSubmatch(bound); m != nil {
+		millis, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return quoteIndexBound(redactString(bound, redactedString))
+		}
+		date := formatISODate(millis)
+		redacted := redactBoundString(append(keyPath, "$date"), date)
+		if redacted == date {
+			return bound
+		}
+		if t, err := time.Parse(time.RFC3339Nano, redacted); err == nil {
+			return "new Date(" + strconv.FormatInt(t.UnixMilli(), 10) + ")"
+		}
+		return "new Date(" + quoteIndexBound(redacted) + ")"
+	}
+	if m := indexBoundRegexRegex.FindStringSubmatch(bound); m != nil {
+		return "/" + redactBoundString(keyPath, m[1]) + "/" + m[2]
+	}
+	// Binary data, timestamps, documents, ...
+	return quoteIndexBound(redactString(bound, redactedString))
+}
+
+func redactBoundString(keyPath []string, s string) string {
+	if redacted, ok := redactScalarValue(keyPath, s, false, false).(string); ok {
+		return redacted
+	}
+	return redactedString
+}
+
+func quoteIndexBound(v any) string {
+	s, ok := v.(string)
+	if !ok {
+		s = redactedString
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
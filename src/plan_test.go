@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+func TestRedactIndexInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  func()
+		interval string
+		expected string
+	}{
+		{"string equality", setOptionsRedactedStrings, `["123", "123"]`, `["REDACTED", "REDACTED"]`},
+		{"string with a comma and quotes", setOptionsRedactedStrings, `["a, \"b\"", "c")`, `["REDACTED", "REDACTED")`},
+		{"email", setOptionsRedactedStrings, `["jdoe@example.com", "jdoe@example.com"]`, `["redacted@redacted.com", "redacted@redacted.com"]`},
+		{"type bracket", setOptionsRedactedStrings, `["", {})`, `["", {})`},
+		{"min and max keys", setOptionsRedactedStrings, `[MinKey, MaxKey]`, `[MinKey, MaxKey]`},
+		{"numbers", setOptionsRedactedStrings, `(5, inf.0]`, `(5, inf.0]`},
+		{"redacted numbers", setOptionsRedactedAll, `[-2.5, 1e3)`, `[0, 0)`},
+		{"booleans", setOptionsRedactedStrings, `[true, true]`, `[true, true]`},
+		{"redacted booleans", setOptionsRedactedAll, `[true, true]`, `[false, false]`},
+		{"object ids", setOptionsRedactedStrings, `[ObjectId('6857dd3e0b8e9e87105654b1'), ObjectId('6857dd3e0b8e9e87105654b1')]`, `[ObjectId('000000000000000000000000'), ObjectId('000000000000000000000000')]`},
+		{"dates", setOptionsRedactedStrings, `[new Date(9223372036854775807), new Date(1704067200000)]`, `[new Date(9223372036854775807), new Date(0)]`},
+		{"regular expression", setOptionsRedactedStrings, `[/^jo, hn/i, /^jo, hn/i]`, `[/REDACTED/i, /REDACTED/i]`},
+		{"other values", setOptionsRedactedStrings, `[BinData(0, 0102), BinData(0, 0102)]`, `["REDACTED", "REDACTED"]`},
+		{"not an interval", setOptionsRedactedStrings, `abc`, `REDACTED`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options()
+			defer setOptionsRedactedStrings()
+			if got := redactIndexInterval("ssn", tt.interval); got != tt.expected {
+				t.Errorf("redactIndexInterval(%q) = %q, want %q", tt.interval, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRedactIndexInterval_FieldRules(t *testing.T) {
+	setOptionsRedactedStrings()
+	defer setOptionsRedactedStrings()
+	rules, err := ParseFieldRules([]string{"*:status=keep"})
+	if err != nil {
+		t.Fatalf("ParseFieldRules returned an unexpected error: %v", err)
+	}
+	SetFieldRules(rules)
+	if got := redactIndexInterval("status", `["A", "A"]`); got != `["A", "A"]` {
+		t.Errorf("Expected the field rule to keep the bounds of status, but got %s", got)
+	}
+	SetRedactedFieldsRegexp("^ssn$")
+	if got := redactIndexInterval("name", `["jo", "jo"]`); got != `["jo", "jo"]` {
+		t.Errorf("Expected bounds of fields not matching --redactFieldsRegexp to be kept, but got %s", got)
+	}
+	if got := redactIndexInterval("ssn", `["1", "1"]`); got != `["REDACTED", "REDACTED"]` {
+		t.Errorf("Expected bounds of fields matching --redactFieldsRegexp to be redacted, but got %s", got)
+	}
+}
+
+func TestRedactQueryPlan_FieldNames(t *testing.T) {
+	setOptionsRedactedStrings()
+	stage, err := UnmarshalOrdered([]byte(`{"stage":"FETCH","filter":{"a":{"$eq":"x"}},"inputStage":{"stage":"IXSCAN","keyPattern":{"ssn":1},"indexName":"ssn_1","multiKeyPaths":{"ssn":["ssn"]},"indexBounds":{"ssn":["[\"1\", \"1\"]"]}}}`))
+	if err != nil {
+		t.Fatalf("Failed to parse stage: %v", err)
+	}
+	redacted := redactQueryPlan(stage, true)
+	got, err := MarshalOrdered(redacted)
+	if err != nil {
+		t.Fatalf("Failed to marshal stage: %v", err)
+	}
+	ssn := HashName("ssn")
+	expected := `{"stage":"FETCH","filter":{"` + HashName("a") + `":{"$eq":"REDACTED"}},"inputStage":{"stage":"IXSCAN","keyPattern":{"` + ssn + `":1},"indexName":"` + HashName("ssn_1") + `","multiKeyPaths":{"` + ssn + `":["` + ssn + `"]},"indexBounds":{"` + ssn + `":["[\"REDACTED\", \"REDACTED\"]"]}}}`
+	if string(got) != expected {
+		t.Errorf("Unexpected stage.\nGot:\n%s\nWant:\n%s", got, expected)
+	}
+
+	// Without eager redaction, field names are kept.
+	stage = orderedmap.NewOrderedMap[string, any]()
+	stage.Set("stage", "IXSCAN")
+	stage.Set("indexName", "ssn_1")
+	if name, _ := redactQueryPlan(stage, false).Get("indexName"); name != "ssn_1" {
+		t.Errorf("Expected the index name to be kept, but got %v", name)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// processesPageSize is the number of processes requested per page of the processes API.
+const processesPageSize = 500
+
+// AtlasProcess is a mongod or mongos process of an Atlas project, as listed by the processes API.
+type AtlasProcess struct {
+	Hostname       string `json:"hostname"`
+	Port           int    `json:"port"`
+	TypeName       string `json:"typeName"`
+	ReplicaSetName string `json:"replicaSetName"`
+	ShardName      string `json:"shardName"`
+	UserAlias      string `json:"userAlias"`
+}
+
+type atlasProcessesPage struct {
+	Results    []AtlasProcess `json:"results"`
+	TotalCount int            `json:"totalCount"`
+}
+
+// HostRole is the role of a process in a cluster, to select the hosts whose logs are downloaded.
+type HostRole string
+
+const (
+	HostRolePrimary   HostRole = "primary"
+	HostRoleSecondary HostRole = "secondary"
+	HostRoleMongos    HostRole = "mongos"
+	HostRoleConfig    HostRole = "config"
+)
+
+var hostRoles = []HostRole{HostRolePrimary, HostRoleSecondary, HostRoleMongos, HostRoleConfig}
+
+// ParseHostRoles returns the roles for their names.
+func ParseHostRoles(names []string) ([]HostRole, error) {
+	var roles []HostRole
+	for _, name := range names {
+		role := HostRole(strings.ToLower(strings.TrimSpace(name)))
+		if !slices.Contains(hostRoles, role) {
+			return nil, fmt.Errorf("invalid host role %q (expected 'primary', 'secondary', 'mongos' or 'config')", name)
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// Role returns the role of the process. Config servers only have the config role, whether they're
+// primary or secondary.
+func (p AtlasProcess) Role() HostRole {
+	switch {
+	case p.TypeName == "SHARD_MONGOS":
+		return HostRoleMongos
+	case strings.HasPrefix(p.TypeName, "SHARD_CONFIG") || strings.Contains(p.ReplicaSetName, "-config-"):
+		return HostRoleConfig
+	case p.TypeName == "REPLICA_PRIMARY" || p.TypeName == "SHARD_PRIMARY":
+		return HostRolePrimary
+	case p.TypeName == "REPLICA_SECONDARY" || p.TypeName == "SHARD_SECONDARY":
+		return HostRoleSecondary
+	}
+	return ""
+}
+
+// HostFilter selects the hosts of a cluster by the role of their processes and their shard. Empty
+// lists select every host.
+type HostFilter struct {
+	Roles  []HostRole
+	Shards []string
+}
+
+func (f HostFilter) matches(p AtlasProcess) bool {
+	if len(f.Roles) > 0 && !slices.Contains(f.Roles, p.Role()) {
+		return false
+	}
+	if len(f.Shards) > 0 && !slices.Contains(f.Shards, p.ShardName) && !slices.Contains(f.Shards, p.ReplicaSetName) {
+		return false
+	}
+	return true
+}
+
+// ResolveClusterHosts returns the hosts of every node of a cluster selected by filter: the members of
+// its shards, its config servers and its mongos routers. They're listed with the processes API, which
+// covers the project, and kept if they belong to the cluster (see clusterMembership).
+func (c *AtlasClient) ResolveClusterHosts(ctx context.Context, publicKey, privateKey, projectID, clusterName string, filter HostFilter) ([]string, error) {
+	processes, err := c.resolveClusterProcesses(ctx, publicKey, privateKey, projectID, clusterName, filter)
+	if err != nil {
+		return nil, err
+	}
+	return processHosts(processes), nil
+}
+
+// resolveClusterProcesses returns the processes of the cluster selected by filter, as described in
+// ResolveClusterHosts.
+func (c *AtlasClient) resolveClusterProcesses(ctx context.Context, publicKey, privateKey, projectID, clusterName string, filter HostFilter) ([]AtlasProcess, error) {
+	info, err := c.getAtlasClusterInfo(ctx, publicKey, privateKey, projectID, clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cluster info: %w", err)
+	}
+	processes, err := c.listProcesses(ctx, publicKey, privateKey, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	inCluster := clusterMembership(clusterName, info, processes)
+
+	var selected []AtlasProcess
+	for _, p := range processes {
+		if inCluster(p) && filter.matches(p) {
+			selected = append(selected, p)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no host of cluster %s matches the selected roles and shards", clusterName)
+	}
+	return selected, nil
+}
+
+// processHosts returns the hosts of the processes, in order. A mongos and a mongod can run on the
+// same host; its logs are downloaded once.
+func processHosts(processes []AtlasProcess) []string {
+	var hosts []string
+	for _, p := range processes {
+		if !slices.Contains(hosts, p.Hostname) {
+			hosts = append(hosts, p.Hostname)
+		}
+	}
+	return hosts
+}
+
+// hostRole returns the role of the process of host that writes the logs of logType: its mongos for
+// the mongos logs, and its mongod for the others.
+func hostRole(processes []AtlasProcess, host string, logType AtlasLogType) HostRole {
+	var role HostRole
+	for _, p := range processes {
+		if p.Hostname != host {
+			continue
+		}
+		if (p.Role() == HostRoleMongos) == logType.isMongos() {
+			return p.Role()
+		}
+		if role == "" {
+			role = p.Role()
+		}
+	}
+	return role
+}
+
+// clusterMembership returns whether a process belongs to a cluster. Atlas names the hosts of a
+// cluster '<prefix>-shard-<shard>-<node>', '<prefix>-config-<node>' or '<prefix>-mongos-<node>',
+// and its replica sets '<prefix>-shard-<shard>' or '<prefix>-config-<n>', where the prefix of hosts
+// is either the name of the cluster or a random one, in which case their alias (userAlias) is named
+// after the cluster. The prefixes are taken from the hosts named after the cluster and from its
+// connection string, which only lists mongos routers for sharded clusters; a process whose host, or
+// replica set, has the same prefix as one of these hosts, or as one of their replica sets, belongs
+// to the cluster. The whole prefix is compared, so that a cluster named 'prod' doesn't take the
+// hosts of 'prod-analytics'.
+func clusterMembership(clusterName string, info *AtlasClusterInfo, processes []AtlasProcess) func(AtlasProcess) bool {
+	hostPrefixes := []string{strings.ToLower(clusterName) + "-"}
+	var replicaSetPrefixes []string
+	addReplicaSet := func(replicaSet string) {
+		if prefix := atlasNamePrefix(replicaSet); prefix != "" && !slices.Contains(replicaSetPrefixes, prefix) {
+			replicaSetPrefixes = append(replicaSetPrefixes, prefix)
+		}
+	}
+	addHost := func(host string) {
+		if prefix := atlasHostPrefix(host); prefix != "" && !slices.Contains(hostPrefixes, prefix) {
+			hostPrefixes = append(hostPrefixes, prefix)
+		}
+	}
+	if cs, err := connstring.Parse(info.ConnectionStrings.Standard); err == nil {
+		addReplicaSet(cs.ReplicaSet)
+		if hosts, err := GetHostsFromConnectionString(info.ConnectionStrings.Standard); err == nil {
+			for _, host := range hosts {
+				addHost(host)
+			}
+		}
+	}
+	hasHostPrefix := func(p AtlasProcess) bool {
+		return slices.Contains(hostPrefixes, atlasHostPrefix(p.Hostname)) || slices.Contains(hostPrefixes, atlasHostPrefix(p.UserAlias))
+	}
+	for _, p := range processes {
+		if hasHostPrefix(p) {
+			addHost(p.Hostname)
+			addReplicaSet(p.ReplicaSetName)
+		}
+	}
+	return func(p AtlasProcess) bool {
+		return hasHostPrefix(p) || slices.Contains(replicaSetPrefixes, atlasNamePrefix(p.ReplicaSetName))
+	}
+}
+
+// atlasHostPrefix returns the prefix of the first label of an Atlas host name, e.g.,
+// 'cluster0-' for 'cluster0-shard-00-01.abcde.mongodb.net:27017'.
+func atlasHostPrefix(host string) string {
+	label, _, _ := strings.Cut(strings.ToLower(host), ".")
+	return atlasNamePrefix(label)
+}
+
+// atlasNamePrefix returns the prefix of the name of an Atlas host or replica set, e.g.,
+// 'atlas-6o93v6-' for 'atlas-6o93v6-shard-0'.
+func atlasNamePrefix(name string) string {
+	for _, marker := range []string{"-shard-", "-config-", "-mongos-"} {
+		if i := strings.LastIndex(name, marker); i > 0 {
+			return name[:i+1]
+		}
+	}
+	return ""
+}
+
+// listProcesses returns every process of the project, reading all the pages of the processes API.
+func (c *AtlasClient) listProcesses(ctx context.Context, publicKey, privateKey, projectID string) ([]AtlasProcess, error) {
+	var processes []AtlasProcess
+	for pageNum := 1; ; pageNum++ {
+		url := fmt.Sprintf("%s/api/atlas/v2/groups/%s/processes?pageNum=%d&itemsPerPage=%d", c.BaseURL, projectID, pageNum, processesPageSize)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Accept", "application/vnd.atlas.2023-01-01+json")
+
+		resp, err := c.authenticatedClient(publicKey, privateKey).Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("request failed: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		var page atlasProcessesPage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		processes = append(processes, page.Results...)
+		if len(page.Results) == 0 || len(processes) >= page.TotalCount {
+			return processes, nil
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newProcessesServer stands in for the Atlas API of a project with a sharded cluster named
+// 'shardedcluster', whose hosts have randomized names with their alias after the cluster, a
+// replica set named 'othercluster', and a paused cluster named 'pausedcluster'. The processes and
+// clusters are returned two per page, and the log of each host holds its name.
+func newProcessesServer(t *testing.T) *httptest.Server {
+	processes := []AtlasProcess{
+		{Hostname: "ac-q1w2e3-shard-00-00.abcde.mongodb.net", Port: 27016, TypeName: "SHARD_MONGOS", UserAlias: "shardedcluster-shard-00-00.abcde.mongodb.net"},
+		{Hostname: "ac-q1w2e3-shard-00-00.abcde.mongodb.net", Port: 27017, TypeName: "REPLICA_PRIMARY", ReplicaSetName: "atlas-k9j8h7-shard-0", ShardName: "atlas-k9j8h7-shard-0", UserAlias: "shardedcluster-shard-00-00.abcde.mongodb.net"},
+		{Hostname: "ac-q1w2e3-shard-00-01.abcde.mongodb.net", Port: 27017, TypeName: "REPLICA_SECONDARY", ReplicaSetName: "atlas-k9j8h7-shard-0", ShardName: "atlas-k9j8h7-shard-0"},
+		{Hostname: "ac-q1w2e3-shard-01-00.abcde.mongodb.net", Port: 27017, TypeName: "REPLICA_PRIMARY", ReplicaSetName: "atlas-k9j8h7-shard-1", ShardName: "atlas-k9j8h7-shard-1"},
+		{Hostname: "ac-q1w2e3-shard-01-01.abcde.mongodb.net", Port: 27017, TypeName: "REPLICA_SECONDARY", ReplicaSetName: "atlas-k9j8h7-shard-1", ShardName: "atlas-k9j8h7-shard-1"},
+		{Hostname: "ac-q1w2e3-config-00-00.abcde.mongodb.net", Port: 27017, TypeName: "REPLICA_PRIMARY", ReplicaSetName: "atlas-k9j8h7-config-0"},
+		{Hostname: "ac-q1w2e3-config-00-01.abcde.mongodb.net", Port: 27017, TypeName: "REPLICA_SECONDARY", ReplicaSetName: "atlas-k9j8h7-config-0"},
+		{Hostname: "othercluster-shard-00-00.abcde.mongodb.net", Port: 27017, TypeName: "REPLICA_PRIMARY", ReplicaSetName: "atlas-x1y2z3-shard-0", UserAlias: "othercluster-shard-00-00.abcde.mongodb.net"},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/atlas/v2/groups/project1/clusters/shardedcluster", func(w http.ResponseWriter, r *http.Request) {
+		// The connection string of a sharded cluster only lists its mongos routers.
+		w.Write([]byte(`{"clusterType":"SHARDED","connectionStrings":{"standard":"mongodb://shardedcluster-shard-00-00.abcde.mongodb.net:27016/?ssl=true&authSource=admin","standardSrv":"mongodb+srv://shardedcluster.abcde.mongodb.net"}}`))
+	})
+	mux.HandleFunc("/api/atlas/v2/groups/project1/clusters/othercluster", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"clusterType":"REPLICASET","connectionStrings":{"standard":"mongodb://othercluster-shard-00-00.abcde.mongodb.net:27017/?ssl=true&replicaSet=atlas-x1y2z3-shard-0"}}`))
+	})
+	mux.HandleFunc("/api/atlas/v2/groups/project1/processes", func(w http.ResponseWriter, r *http.Request) {
+		pageNum, _ := strconv.Atoi(r.URL.Query().Get("pageNum"))
+		start := min((pageNum-1)*2, len(processes))
+		end := min(start+2, len(processes))
+		json.NewEncoder(w).Encode(map[string]any{"results": processes[start:end], "totalCount": len(processes)})
+	})
+	clusters := []AtlasCluster{{Name: "shardedcluster"}, {Name: "othercluster"}, {Name: "pausedcluster", Paused: true}}
+	mux.HandleFunc("/api/atlas/v2/groups/project1/clusters", func(w http.ResponseWriter, r *http.Request) {
+		pageNum, _ := strconv.Atoi(r.URL.Query().Get("pageNum"))
+		start := min((pageNum-1)*2, len(clusters))
+		end := min(start+2, len(clusters))
+		json.NewEncoder(w).Encode(map[string]any{"results": clusters[start:end], "totalCount": len(clusters)})
+	})
+	mux.HandleFunc("/api/atlas/v2/groups/project1/clusters/{host}/logs/{log}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("host")))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestResolveClusterHosts(t *testing.T) {
+	server := newProcessesServer(t)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+
+	tests := []struct {
+		name     string
+		cluster  string
+		filter   HostFilter
+		expected []string
+	}{
+		{
+			name:    "every node of a sharded cluster",
+			cluster: "shardedcluster",
+			expected: []string{
+				"ac-q1w2e3-shard-00-00.abcde.mongodb.net",
+				"ac-q1w2e3-shard-00-01.abcde.mongodb.net",
+				"ac-q1w2e3-shard-01-00.abcde.mongodb.net",
+				"ac-q1w2e3-shard-01-01.abcde.mongodb.net",
+				"ac-q1w2e3-config-00-00.abcde.mongodb.net",
+				"ac-q1w2e3-config-00-01.abcde.mongodb.net",
+			},
+		},
+		{
+			name:     "primaries",
+			cluster:  "shardedcluster",
+			filter:   HostFilter{Roles: []HostRole{HostRolePrimary}},
+			expected: []string{"ac-q1w2e3-shard-00-00.abcde.mongodb.net", "ac-q1w2e3-shard-01-00.abcde.mongodb.net"},
+		},
+		{
+			name:     "mongos and config servers",
+			cluster:  "shardedcluster",
+			filter:   HostFilter{Roles: []HostRole{HostRoleMongos, HostRoleConfig}},
+			expected: []string{"ac-q1w2e3-shard-00-00.abcde.mongodb.net", "ac-q1w2e3-config-00-00.abcde.mongodb.net", "ac-q1w2e3-config-00-01.abcde.mongodb.net"},
+		},
+		{
+			name:     "secondaries of a shard",
+			cluster:  "shardedcluster",
+			filter:   HostFilter{Roles: []HostRole{HostRoleSecondary}, Shards: []string{"atlas-k9j8h7-shard-1"}},
+			expected: []string{"ac-q1w2e3-shard-01-01.abcde.mongodb.net"},
+		},
+		{
+			name:     "replica set",
+			cluster:  "othercluster",
+			expected: []string{"othercluster-shard-00-00.abcde.mongodb.net"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := client.ResolveClusterHosts(context.Background(), "pubKey", "privKey", "project1", tt.cluster, tt.filter)
+			if err != nil {
+				t.Fatalf("ResolveClusterHosts returned an unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(hosts, tt.expected) {
+				t.Errorf("ResolveClusterHosts() = %v, want %v", hosts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveClusterHosts_Errors(t *testing.T) {
+	server := newProcessesServer(t)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+
+	tests := []struct {
+		name    string
+		project string
+		cluster string
+		filter  HostFilter
+		wantErr string
+	}{
+		{"no matching host", "project1", "othercluster", HostFilter{Roles: []HostRole{HostRoleMongos}}, "no host of cluster othercluster"},
+		{"unknown shard", "project1", "shardedcluster", HostFilter{Shards: []string{"shard-9"}}, "no host of cluster shardedcluster"},
+		{"unknown cluster", "project1", "missing", HostFilter{}, "failed to get cluster info"},
+		{"unknown project", "project2", "shardedcluster", HostFilter{}, "status 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.ResolveClusterHosts(context.Background(), "pubKey", "privKey", tt.project, tt.cluster, tt.filter)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ResolveClusterHosts() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestClusterMembership checks that the hosts of a cluster whose name starts with the name of
+// another one, followed by a dash, aren't taken for hosts of the other cluster.
+func TestClusterMembership(t *testing.T) {
+	processes := []AtlasProcess{
+		{Hostname: "prod-shard-00-00.abcde.mongodb.net", TypeName: "REPLICA_PRIMARY", ReplicaSetName: "prod-shard-0"},
+		{Hostname: "prod-shard-00-01.abcde.mongodb.net", TypeName: "REPLICA_SECONDARY", ReplicaSetName: "prod-shard-0"},
+		{Hostname: "prod-mongos-00-00.abcde.mongodb.net", TypeName: "SHARD_MONGOS"},
+		{Hostname: "prod-analytics-shard-00-00.abcde.mongodb.net", TypeName: "REPLICA_PRIMARY", ReplicaSetName: "prod-analytics-shard-0"},
+		{Hostname: "prod-analytics-shard-00-01.abcde.mongodb.net", TypeName: "REPLICA_SECONDARY", ReplicaSetName: "prod-analytics-shard-0"},
+		{Hostname: "ac-r4t5y6-shard-00-00.abcde.mongodb.net", TypeName: "REPLICA_PRIMARY", ReplicaSetName: "atlas-m1n2b3-shard-0", UserAlias: "prod-analytics-shard-00-00.abcde.mongodb.net"},
+		{Hostname: "ac-r4t5y6-shard-00-01.abcde.mongodb.net", TypeName: "REPLICA_SECONDARY", ReplicaSetName: "atlas-m1n2b3-shard-0"},
+	}
+	tests := []struct {
+		cluster          string
+		connectionString string
+		expected         []string
+	}{
+		{
+			cluster:          "prod",
+			connectionString: "mongodb://prod-shard-00-00.abcde.mongodb.net:27017/?replicaSet=prod-shard-0",
+			expected:         []string{"prod-shard-00-00.abcde.mongodb.net", "prod-shard-00-01.abcde.mongodb.net", "prod-mongos-00-00.abcde.mongodb.net"},
+		},
+		{
+			cluster:          "prod-analytics",
+			connectionString: "mongodb://prod-analytics-shard-00-00.abcde.mongodb.net:27017/?replicaSet=prod-analytics-shard-0",
+			expected: []string{
+				"prod-analytics-shard-00-00.abcde.mongodb.net",
+				"prod-analytics-shard-00-01.abcde.mongodb.net",
+				"ac-r4t5y6-shard-00-00.abcde.mongodb.net",
+				"ac-r4t5y6-shard-00-01.abcde.mongodb.net",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cluster, func(t *testing.T) {
+			info := &AtlasClusterInfo{}
+			info.ConnectionStrings.Standard = tt.connectionString
+			inCluster := clusterMembership(tt.cluster, info, processes)
+			var hosts []string
+			for _, p := range processes {
+				if inCluster(p) {
+					hosts = append(hosts, p.Hostname)
+				}
+			}
+			if !reflect.DeepEqual(hosts, tt.expected) {
+				t.Errorf("clusterMembership(%s) selects %v, want %v", tt.cluster, hosts, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseHostRoles(t *testing.T) {
+	roles, err := ParseHostRoles([]string{"Primary", " mongos"})
+	if err != nil || !reflect.DeepEqual(roles, []HostRole{HostRolePrimary, HostRoleMongos}) {
+		t.Errorf("ParseHostRoles() = %v, %v", roles, err)
+	}
+	if _, err := ParseHostRoles([]string{"arbiter"}); err == nil {
+		t.Errorf("ParseHostRoles(arbiter) error = nil, want an error")
+	}
+}
+
+func TestHostRole(t *testing.T) {
+	processes := []AtlasProcess{
+		{Hostname: "shard-00-00", TypeName: "SHARD_MONGOS"},
+		{Hostname: "shard-00-00", TypeName: "REPLICA_PRIMARY", ReplicaSetName: "atlas-k9j8h7-shard-0"},
+		{Hostname: "config-00-01", TypeName: "REPLICA_SECONDARY", ReplicaSetName: "atlas-k9j8h7-config-0"},
+	}
+	tests := []struct {
+		host     string
+		logType  AtlasLogType
+		expected HostRole
+	}{
+		{"shard-00-00", AtlasLogMongod, HostRolePrimary},
+		{"shard-00-00", AtlasLogMongos, HostRoleMongos},
+		{"shard-00-00", AtlasLogMongosAudit, HostRoleMongos},
+		{"config-00-01", AtlasLogMongodAudit, HostRoleConfig},
+		// A host without a mongos has the role of its mongod for every log.
+		{"config-00-01", AtlasLogMongos, HostRoleConfig},
+		{"unknown", AtlasLogMongod, ""},
+	}
+	for _, tt := range tests {
+		if got := hostRole(processes, tt.host, tt.logType); got != tt.expected {
+			t.Errorf("hostRole(%s, %s) = %q, want %q", tt.host, tt.logType, got, tt.expected)
+		}
+	}
+}
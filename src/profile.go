@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// RedactProfileDocument redacts a document of the system.profile collection, written by the
+// database profiler. Its commands are redacted like in log entries, along with the filters and
+// index bounds of its execStats, and the client and users that ran it.
+func RedactProfileDocument(jsonStr string) (*orderedmap.OrderedMap[string, any], error) {
+	doc, err := UnmarshalOrdered([]byte(jsonStr))
+	if err != nil {
+		return nil, err
+	}
+	nsVal, _ := doc.Get("ns")
+	ns, _ := nsVal.(string)
+	SetCurrentComponent("")
+	SetCurrentNamespace(ns)
+	shouldEagerRedact := isEagerRedactionNamespace(ns)
+
+	for _, field := range []string{"command", "originatingCommand"} {
+		if cmd, ok := doc.Get(field); ok {
+			if cmdMap, ok := cmd.(*orderedmap.OrderedMap[string, any]); ok {
+				redactCommand(cmdMap, shouldEagerRedact)
+				if redactNamespaces {
+					redactNamespace(cmdMap)
+				}
+			}
+		}
+	}
+	// Profilers before MongoDB 3.6 record the filter and update of an operation on their own.
+	for _, field := range []string{"query", "updateobj"} {
+		if query, ok := doc.Get(field); ok {
+			if queryMap, ok := query.(*orderedmap.OrderedMap[string, any]); ok {
+				doc.Set(field, redactQueryValues(queryMap, shouldEagerRedact, false, nil, []string{}))
+			}
+		}
+	}
+	if execStats, ok := doc.Get("execStats"); ok {
+		if stage, ok := execStats.(*orderedmap.OrderedMap[string, any]); ok {
+			doc.Set("execStats", redactQueryPlan(stage, shouldEagerRedact))
+		}
+	}
+	if shouldEagerRedact {
+		if planSummary, ok := doc.Get("planSummary"); ok {
+			if psStr, ok := planSummary.(string); ok {
+				doc.Set("planSummary", redactFieldNamesFromPlanSummary(psStr))
+			}
+		}
+	}
+	if client, ok := doc.Get("client"); ok {
+		if _, ok := client.(string); ok {
+			reportIPDetected("client")
+			if redactIPs {
+				doc.Set("client", "255.255.255.255")
+				reportRedactedValue([]string{"client"}, nil)
+			}
+		}
+	}
+	if user, ok := doc.Get("user"); ok {
+		// The user is written '<name>@<database>'.
+		if userStr, ok := user.(string); ok && userStr != "" {
+			name, db := userStr, ""
+			if i := strings.LastIndex(userStr, "@"); i >= 0 {
+				name, db = userStr[:i], userStr[i:]
+			}
+			if redacted, ok := redactScalarValue([]string{"user"}, name, false, false).(string); ok {
+				doc.Set("user", redacted+db)
+			}
+		}
+	}
+	if allUsers, ok := doc.Get("allUsers"); ok {
+		if users, ok := allUsers.([]any); ok {
+			for _, u := range users {
+				if userMap, ok := u.(*orderedmap.OrderedMap[string, any]); ok {
+					if name, ok := userMap.Get("user"); ok {
+						userMap.Set("user", redactScalarValue([]string{"user"}, name, false, false))
+					}
+				}
+			}
+		}
+	}
+	if redactNamespaces && ns != "" {
+		doc.Set("ns", HashName(ns))
+	}
+	return doc, nil
+}
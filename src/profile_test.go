@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactProfileDocument(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       func()
+		expectedPaths map[string]any
+	}{
+		{
+			name:    "values",
+			options: setOptionsRedactedStrings,
+			expectedPaths: map[string]any{
+				"command.filter.ssn":                                  RedactedString,
+				"command.filter.hiredAt.$gte.$date":                   RedactedISODate,
+				"command.filter.email":                                "redacted@redacted.com",
+				"command.limit":                                       float64(10),
+				"execStats.inputStage.filter.email.$eq":               "redacted@redacted.com",
+				"execStats.inputStage.inputStage.indexName":           "ssn_1_hiredAt_-1",
+				"execStats.inputStage.inputStage.indexBounds.ssn":     []any{`["REDACTED", "REDACTED"]`},
+				"execStats.inputStage.inputStage.indexBounds.hiredAt": []any{"[new Date(9223372036854775807), new Date(0)]"},
+				"planSummary":                                         "IXSCAN { ssn: 1, hiredAt: -1 }",
+				"client":                                              "10.20.30.40",
+				"user":                                                RedactedString + "@admin",
+				"allUsers.0.user":                                     RedactedString,
+				"allUsers.0.db":                                       "admin",
+				"ns":                                                  "hr.employees",
+			},
+		},
+		{
+			name: "field names, namespaces and IPs",
+			options: func() {
+				setOptionsRedactedStringsAndNamespaces()
+				SetEagerRedactionPaths([]string{"hr.*"})
+				SetRedactIPs(true)
+			},
+			expectedPaths: map[string]any{
+				"command.find":                                                   HashName("employees"),
+				"command.filter." + HashName("ssn"):                              RedactedString,
+				"execStats.inputStage.inputStage.indexName":                      HashName("ssn_1_hiredAt_-1"),
+				"execStats.inputStage.inputStage.keyPattern." + HashName("ssn"):  float64(1),
+				"execStats.inputStage.inputStage.indexBounds." + HashName("ssn"): []any{`["REDACTED", "REDACTED"]`},
+				"planSummary": "IXSCAN { " + HashName("ssn") + ": 1, " + HashName("hiredAt") + ": -1 }",
+				"client":      "255.255.255.255",
+				"ns":          HashName("hr.employees"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options()
+			defer setOptionsRedactedStrings()
+			doc, err := RedactProfileDocument(getFixtureContent(t, "test_fixtures/profile_find.json"))
+			if err != nil {
+				t.Fatalf("RedactProfileDocument returned an unexpected error: %v", err)
+			}
+			for path, want := range tt.expectedPaths {
+				if got := getJSONPath(doc, path); !valuesEqual(got, want) {
+					t.Errorf("expected %s to be %s, got %s", path, getTypeInfo(want), getTypeInfo(got))
+				}
+			}
+		})
+	}
+}
+
+func TestRedactProfileDocument_LegacyFields(t *testing.T) {
+	setOptionsRedactedStrings()
+	doc, err := RedactProfileDocument(`{"op":"update","ns":"shop.orders","query":{"status":"new"},"updateobj":{"$set":{"status":"paid"}}}`)
+	if err != nil {
+		t.Fatalf("RedactProfileDocument returned an unexpected error: %v", err)
+	}
+	for _, path := range []string{"query.status", "updateobj.$set.status"} {
+		if got := getJSONPath(doc, path); got != RedactedString {
+			t.Errorf("expected %s to be redacted, got %v", path, got)
+		}
+	}
+}
+
+// TestProcessMongoLogFile_ProfileFormat redacts an array of profiler documents, as written by
+// 'mongoexport --jsonArray'.
+func TestProcessMongoLogFile_ProfileFormat(t *testing.T) {
+	setOptionsRedactedStrings()
+	SetEntryFormat(EntryFormatProfile)
+	defer SetEntryFormat(EntryFormatLog)
+
+	doc := getFixtureContent(t, "test_fixtures/profile_find.json")
+	var outBuffer bytes.Buffer
+	if err := ProcessMongoLogFileFromReader(strings.NewReader("["+doc+","+doc+"]"), &outBuffer, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(outBuffer.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 redacted documents, but got %d:\n%s", len(lines), outBuffer.String())
+	}
+	if strings.Contains(outBuffer.String(), "123-45-6789") {
+		t.Errorf("Expected the SSN to be redacted from the filter and index bounds, but got:\n%s", outBuffer.String())
+	}
+
+	outBuffer.Reset()
+	if err := ProcessMongoLogFile(&DefaultFileReader{}, filepath.Join("..", "test_fixtures", "profile_find.json"), &outBuffer, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFile returned an unexpected error: %v", err)
+	}
+	if outBuffer.String() != lines[0]+"\n" {
+		t.Errorf("Unexpected output.\nGot:\n%s\nWant:\n%s", outBuffer.String(), lines[0])
+	}
+}
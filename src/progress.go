@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// ProgressMode selects how redaction progress is reported.
+type ProgressMode string
+
+const (
+	// ProgressAuto shows a progress bar when stderr is a terminal, and nothing otherwise.
+	ProgressAuto ProgressMode = "auto"
+	ProgressBar  ProgressMode = "bar"
+	// ProgressJSON writes one JSON progress event per line, for wrappers to parse.
+	ProgressJSON ProgressMode = "json"
+	ProgressNone ProgressMode = "none"
+)
+
+// ParseProgressMode returns the ProgressMode for its name.
+func ParseProgressMode(name string) (ProgressMode, error) {
+	switch mode := ProgressMode(strings.ToLower(name)); mode {
+	case ProgressAuto, ProgressBar, ProgressJSON, ProgressNone:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid progress mode %q (expected 'auto', 'bar', 'json' or 'none')", name)
+}
+
+// progressEventInterval is how often a JSON progress event is written.
+var progressEventInterval = time.Second
+
+// ProgressEvent is the machine-readable progress of a redaction. TotalBytes and ETASeconds are
+// omitted when the size of the input isn't known, e.g., when reading from a pipe.
+type ProgressEvent struct {
+	// Event is "progress" while redacting, and "done" once the input is fully read.
+	Event            string   `json:"event"`
+	File             string   `json:"file,omitempty"`
+	BytesRead        int64    `json:"bytesRead"`
+	TotalBytes       int64    `json:"totalBytes,omitempty"`
+	Entries          int64    `json:"entries"`
+	EntriesPerSecond float64  `json:"entriesPerSecond"`
+	ElapsedSeconds   float64  `json:"elapsedSeconds"`
+	ETASeconds       *float64 `json:"etaSeconds,omitempty"`
+}
+
+// Progress tracks how far a redaction has gone through its input. It's driven by the bytes read
+// from the underlying file, before any decompression, so it works for gzipped input without a
+// first pass over the file. A nil *Progress is valid and reports nothing.
+type Progress struct {
+	mu         sync.Mutex
+	w          io.Writer
+	file       string
+	total      int64
+	bytes      int64
+	entries    int64
+	start      time.Time
+	lastUpdate time.Time
+	// baseBytes and baseEntries were processed by a previous run, and don't count towards rates.
+	baseBytes   int64
+	baseEntries int64
+	bar         *progressbar.ProgressBar
+}
+
+// NewProgress reports the progress of redacting file, of totalBytes bytes (0 if unknown), to w.
+// It returns nil when mode is ProgressNone, or ProgressAuto and w isn't a terminal.
+func NewProgress(mode ProgressMode, w io.Writer, file string, totalBytes int64) *Progress {
+	if mode == ProgressAuto {
+		mode = ProgressNone
+		if f, ok := w.(*os.File); ok && isTerminal(f) {
+			mode = ProgressBar
+		}
+	}
+	if mode == ProgressNone {
+		return nil
+	}
+	now := time.Now()
+	p := &Progress{w: w, file: file, total: totalBytes, start: now, lastUpdate: now}
+	if mode == ProgressBar {
+		barMax := totalBytes
+		if barMax <= 0 {
+			barMax = -1
+		}
+		p.bar = progressbar.NewOptions64(barMax,
+			progressbar.OptionEnableColorCodes(true),
+			progressbar.OptionSetWidth(50),
+			progressbar.OptionSetDescription(p.description()),
+			progressbar.OptionSetTheme(progressbar.Theme{
+				Saucer:        "[green]=[reset]",
+				SaucerHead:    "[green]>[reset]",
+				SaucerPadding: " ",
+				BarStart:      "[",
+				BarEnd:        "]",
+			}),
+			progressbar.OptionSetRenderBlankState(true),
+			progressbar.OptionSetPredictTime(true),
+			progressbar.OptionShowBytes(true),
+			progressbar.OptionShowCount(),
+			progressbar.OptionShowElapsedTimeOnFinish(),
+			progressbar.OptionSetWriter(w),
+			progressbar.OptionThrottle(250*time.Millisecond),
+		)
+	}
+	return p
+}
+
+// NewInputProgress is NewProgress for an input file (or stdin) whose size is taken from f.
+func NewInputProgress(mode ProgressMode, w io.Writer, name string, f *os.File) *Progress {
+	var size int64
+	if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+		size = info.Size()
+	}
+	return NewProgress(mode, w, name, size)
+}
+
+// fileSize returns the size of the file at path, or 0 if it can't be determined.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Reader returns r, counting the bytes read from it as progress.
+func (p *Progress) Reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{r: r, p: p}
+}
+
+type progressReader struct {
+	r io.Reader
+	p *Progress
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	if n > 0 {
+		pr.p.addBytes(int64(n))
+	}
+	return n, err
+}
+
+// readerAt is progressReader for reads at arbitrary offsets, e.g., of a zip archive.
+type readerAt struct {
+	r io.ReaderAt
+	p *Progress
+}
+
+func (ra *readerAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := ra.r.ReadAt(b, off)
+	if n > 0 {
+		ra.p.addBytes(int64(n))
+	}
+	return n, err
+}
+
+// Resume records that a previous run already redacted entries, and that bytes of the input were
+// skipped without being read. What was processed so far doesn't count towards the rates.
+func (p *Progress) Resume(bytes, entries int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytes += bytes
+	p.entries += entries
+	p.baseBytes, p.baseEntries = p.bytes, p.entries
+	p.start = time.Now()
+	if p.bar != nil {
+		p.bar.Set64(p.bytes)
+	}
+}
+
+// AddEntry records that a log entry was redacted.
+func (p *Progress) AddEntry() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries++
+	p.update(false)
+}
+
+func (p *Progress) addBytes(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bytes += n
+	if p.bar != nil {
+		p.bar.Add64(n)
+	}
+	p.update(false)
+}
+
+// Finish reports the final progress, once the input is fully read.
+func (p *Progress) Finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.update(true)
+	if p.bar != nil {
+		p.bar.Finish()
+		fmt.Fprintln(p.w, "\n\nRedaction complete - finalizing output...")
+	}
+}
+
+// update refreshes the bar's entry rate, or writes a JSON event, at most once per interval unless
+// done is set. The caller holds p.mu.
+func (p *Progress) update(done bool) {
+	now := time.Now()
+	interval := progressEventInterval
+	if p.bar != nil {
+		interval = 250 * time.Millisecond
+	}
+	if !done && now.Sub(p.lastUpdate) < interval {
+		return
+	}
+	p.lastUpdate = now
+	if p.bar != nil {
+		p.bar.Describe(p.description())
+		return
+	}
+	event := p.event(now)
+	if done {
+		event.Event = "done"
+		event.ETASeconds = nil
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(p.w, string(data))
+}
+
+func (p *Progress) event(now time.Time) ProgressEvent {
+	elapsed := now.Sub(p.start).Seconds()
+	event := ProgressEvent{
+		Event:          "progress",
+		File:           p.file,
+		BytesRead:      p.bytes,
+		TotalBytes:     p.total,
+		Entries:        p.entries,
+		ElapsedSeconds: elapsed,
+	}
+	if elapsed > 0 {
+		event.EntriesPerSecond = float64(p.entries-p.baseEntries) / elapsed
+	}
+	if read := p.bytes - p.baseBytes; p.total > 0 && read > 0 {
+		eta := max(float64(p.total-p.bytes), 0) * elapsed / float64(read)
+		event.ETASeconds = &eta
+	}
+	return event
+}
+
+func (p *Progress) description() string {
+	event := p.event(time.Now())
+	name := "MongoDB logs"
+	if p.file != "" {
+		name = p.file
+	}
+	return fmt.Sprintf("Redacting %s... %d entries (%.0f entries/s)", name, event.Entries, event.EntriesPerSecond)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readProgressEvents(t *testing.T, data string) []ProgressEvent {
+	t.Helper()
+	var events []ProgressEvent
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		var event ProgressEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Failed to parse progress event %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestProcessMongoLogFileProgress(t *testing.T) {
+	setOptionsRedactedStrings()
+	defer func(interval time.Duration) { progressEventInterval = interval }(progressEventInterval)
+	progressEventInterval = 0
+
+	log := strings.Repeat(getFixtureContent(t, "test_fixtures/simple_find.json")+"\n", 50)
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+	}{
+		{"plain", "mongod.log", []byte(log)},
+		{"gzip", "mongod.log.gz", gzipBytes(t, log)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, tt.content, 0o644); err != nil {
+				t.Fatalf("Failed to write input: %v", err)
+			}
+			var events bytes.Buffer
+			progress := NewProgress(ProgressJSON, &events, tt.file, fileSize(path))
+			if err := ProcessMongoLogFile(&DefaultFileReader{}, path, io.Discard, progress); err != nil {
+				t.Fatalf("ProcessMongoLogFile() error = %v", err)
+			}
+
+			got := readProgressEvents(t, events.String())
+			last := got[len(got)-1]
+			size := int64(len(tt.content))
+			if last.Event != "done" || last.Entries != 50 || last.BytesRead != size || last.TotalBytes != size || last.File != tt.file {
+				t.Errorf("Last event = %+v, want done with 50 entries and %d bytes of %s read", last, size, tt.file)
+			}
+			for i, event := range got[:len(got)-1] {
+				if event.Event != "progress" || event.BytesRead > size {
+					t.Errorf("Event %d = %+v, want progress within %d bytes", i, event, size)
+				}
+				if event.BytesRead > 0 && event.ETASeconds == nil {
+					t.Errorf("Event %d = %+v, want an ETA", i, event)
+				}
+				if i > 0 && (event.BytesRead < got[i-1].BytesRead || event.Entries < got[i-1].Entries) {
+					t.Errorf("Event %d = %+v went backwards from %+v", i, event, got[i-1])
+				}
+			}
+		})
+	}
+}
+
+func TestProgressUnknownSize(t *testing.T) {
+	var events bytes.Buffer
+	progress := NewProgress(ProgressJSON, &events, "", 0)
+	r := progress.Reader(strings.NewReader("0123456789"))
+	io.ReadAll(r)
+	progress.AddEntry()
+	progress.Finish()
+
+	got := readProgressEvents(t, events.String())
+	last := got[len(got)-1]
+	if last.Event != "done" || last.BytesRead != 10 || last.Entries != 1 || last.TotalBytes != 0 || last.ETASeconds != nil {
+		t.Errorf("Last event = %+v, want done with 10 bytes and 1 entry, without a total or ETA", last)
+	}
+	if strings.Contains(events.String(), "totalBytes") {
+		t.Errorf("Expected totalBytes to be omitted when unknown, but got:\n%s", events.String())
+	}
+}
+
+func TestProgressResume(t *testing.T) {
+	var events bytes.Buffer
+	progress := NewProgress(ProgressJSON, &events, "mongod.log", 100)
+	progress.Resume(60, 6)
+	io.ReadAll(progress.Reader(strings.NewReader(strings.Repeat("x", 40))))
+	progress.AddEntry()
+	progress.Finish()
+
+	got := readProgressEvents(t, events.String())
+	if last := got[len(got)-1]; last.BytesRead != 100 || last.Entries != 7 {
+		t.Errorf("Last event = %+v, want 100 bytes and 7 entries", last)
+	}
+}
+
+func TestNewProgress(t *testing.T) {
+	var nilProgress *Progress
+	// A nil Progress reports nothing, and passes reads through.
+	nilProgress.AddEntry()
+	nilProgress.Resume(1, 1)
+	nilProgress.Finish()
+	if r := strings.NewReader("x"); nilProgress.Reader(r) != io.Reader(r) {
+		t.Errorf("Expected a nil Progress to return the reader unchanged")
+	}
+
+	if p := NewProgress(ProgressNone, os.Stderr, "", 0); p != nil {
+		t.Errorf("NewProgress(none) = %v, want nil", p)
+	}
+	if p := NewProgress(ProgressAuto, &bytes.Buffer{}, "", 0); p != nil {
+		t.Errorf("NewProgress(auto) to a buffer = %v, want nil", p)
+	}
+	var out bytes.Buffer
+	if p := NewProgress(ProgressBar, &out, "mongod.log", 10); p == nil || p.bar == nil {
+		t.Errorf("NewProgress(bar) = %v, want a progress bar", p)
+	}
+	if strings.Contains(out.String(), "{") {
+		t.Errorf("Expected a progress bar, but got JSON: %s", out.String())
+	}
+}
+
+func TestParseProgressMode(t *testing.T) {
+	for name, want := range map[string]ProgressMode{"auto": ProgressAuto, "BAR": ProgressBar, "json": ProgressJSON, "none": ProgressNone} {
+		if got, err := ParseProgressMode(name); err != nil || got != want {
+			t.Errorf("ParseProgressMode(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseProgressMode("verbose"); err == nil {
+		t.Errorf("ParseProgressMode(\"verbose\") error = nil, want an error")
+	}
+}
@@ -0,0 +1,815 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// QueryCommand is the command a query given to 'redact-query' is used with. It tells a filter from
+// an update, and an aggregation stage from a filter.
+type QueryCommand string
+
+const (
+	// QueryCommandAuto guesses the command from the query: a pipeline for an array, an update for
+	// a document of update operators, and a filter otherwise.
+	QueryCommandAuto      QueryCommand = ""
+	QueryCommandFind      QueryCommand = "find"
+	QueryCommandAggregate QueryCommand = "aggregate"
+	QueryCommandUpdate    QueryCommand = "update"
+)
+
+// ParseQueryCommand returns the QueryCommand for its name; an empty name is QueryCommandAuto.
+func ParseQueryCommand(name string) (QueryCommand, error) {
+	switch command := QueryCommand(strings.ToLower(name)); command {
+	case QueryCommandAuto, QueryCommandFind, QueryCommandAggregate, QueryCommandUpdate:
+		return command, nil
+	}
+	return "", fmt.Errorf("invalid command %q (expected 'find', 'aggregate' or 'update')", name)
+}
+
+// QuerySyntax is how a query is written.
+type QuerySyntax string
+
+const (
+	// QuerySyntaxJSON is (extended) JSON, as found in logs.
+	QuerySyntaxJSON QuerySyntax = "json"
+	// QuerySyntaxShell is the relaxed syntax of mongosh, with unquoted keys, single-quoted strings,
+	// regular expression literals and helpers such as ObjectId() and ISODate().
+	QuerySyntaxShell QuerySyntax = "mongosh"
+)
+
+// QueryOptions tells RedactQuery how a query is used.
+type QueryOptions struct {
+	// Namespace is the namespace the query runs on, for --fieldRule and --redactFieldNames.
+	Namespace string
+	Command   QueryCommand
+}
+
+// updateOperators are the top-level keys that make a document an update rather than a filter.
+var updateOperators = []string{"$set", "$unset", "$inc", "$mul", "$rename", "$min", "$max", "$currentDate", "$setOnInsert", "$push", "$pull", "$pullAll", "$addToSet", "$pop", "$bit"}
+
+// RedactQuery redacts a single filter, update, or aggregation pipeline, the same way as in a log
+// entry, and returns it in the syntax it was written in.
+func RedactQuery(query string, opts QueryOptions) (string, error) {
+	value, syntax, err := parseQuery(query)
+	if err != nil {
+		return "", err
+	}
+	command := opts.Command
+	if command == QueryCommandAuto {
+		command = detectQueryCommand(value)
+	}
+	SetCurrentComponent("COMMAND")
+	SetCurrentNamespace(opts.Namespace)
+	redactFieldNames := isEagerRedactionNamespace(opts.Namespace)
+
+	var redacted any
+	switch v := value.(type) {
+	case *orderedmap.OrderedMap[string, any]:
+		if command == QueryCommandAggregate {
+			// A single stage, e.g., copied from a pipeline.
+			redacted = redactPipelineStage(v, redactFieldNames, []string{}, isInSearchStage(v))
+		} else {
+			redacted = redactQueryValues(v, redactFieldNames, false, nil, []string{})
+		}
+	case []any:
+		if command == QueryCommandFind {
+			return "", errors.New("a find filter must be a document, not an array")
+		}
+		// Updates accept a pipeline too.
+		redacted = redactPipeline(v, redactFieldNames)
+	default:
+		return "", errors.New("a query must be a document, or a pipeline (an array of stages)")
+	}
+
+	// Redaction drops null values, or changes them into strings in pipelines, while they hold
+	// nothing to redact and matter to what the query does. The query is parsed again because
+	// redaction changes arrays in place.
+	original, _, _ := parseQuery(query)
+	redacted = restoreNullValues(original, redacted, redactFieldNames)
+
+	if syntax == QuerySyntaxShell {
+		var b strings.Builder
+		writeShellValue(&b, redacted)
+		return b.String(), nil
+	}
+	out, err := marshalOrderedValue(redacted)
+	return string(out), err
+}
+
+// restoreNullValues puts back the null values of original into its redacted version, matching
+// fields by key like DiffValues, and array items by position.
+func restoreNullValues(original, redacted any, redactFieldNames bool) any {
+	switch o := original.(type) {
+	case *orderedmap.OrderedMap[string, any]:
+		r, ok := redacted.(*orderedmap.OrderedMap[string, any])
+		if !ok {
+			return redacted
+		}
+		restored := orderedmap.NewOrderedMap[string, any]()
+		for el := o.Front(); el != nil; el = el.Next() {
+			key := el.Key
+			value, found := r.Get(key)
+			if !found {
+				if hashed := hashedName(key); r.Has(hashed) {
+					key = hashed
+					value, found = r.Get(key)
+				}
+			}
+			switch {
+			case el.Value == nil:
+				if !found && redactFieldNames && !strings.HasPrefix(key, "$") {
+					key = HashName(key)
+				}
+				restored.Set(key, nil)
+			case found:
+				restored.Set(key, restoreNullValues(el.Value, value, redactFieldNames))
+			}
+		}
+		for el := r.Front(); el != nil; el = el.Next() {
+			if !restored.Has(el.Key) {
+				restored.Set(el.Key, el.Value)
+			}
+		}
+		return restored
+	case []any:
+		r, ok := redacted.([]any)
+		if !ok || len(r) != len(o) {
+			return redacted
+		}
+		for i := range r {
+			r[i] = restoreNullValues(o[i], r[i], redactFieldNames)
+		}
+		return r
+	}
+	return redacted
+}
+
+// parseQuery parses query as JSON, or as mongosh syntax if it isn't valid JSON.
+func parseQuery(query string) (any, QuerySyntax, error) {
+	dec := json.NewDecoder(strings.NewReader(query))
+	dec.UseNumber()
+	if value, err := parseValue(dec); err == nil {
+		if _, err := dec.Token(); errors.Is(err, io.EOF) {
+			return value, QuerySyntaxJSON, nil
+		}
+	}
+	value, err := parseShellValue(query)
+	if err != nil {
+		return nil, "", err
+	}
+	return value, QuerySyntaxShell, nil
+}
+
+func detectQueryCommand(value any) QueryCommand {
+	switch v := value.(type) {
+	case []any:
+		return QueryCommandAggregate
+	case *orderedmap.OrderedMap[string, any]:
+		for el := v.Front(); el != nil; el = el.Next() {
+			for _, op := range updateOperators {
+				if el.Key == op {
+					return QueryCommandUpdate
+				}
+			}
+		}
+	}
+	return QueryCommandFind
+}
+
+// marshalOrderedValue is MarshalOrdered for any value of a parsed document.
+func marshalOrderedValue(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case *orderedmap.OrderedMap[string, any]:
+		return MarshalOrdered(v)
+	case []any:
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			data, err := marshalOrderedValue(item)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(data)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	}
+	return json.Marshal(v)
+}
+
+// shellParser parses values in mongosh syntax into the same types as UnmarshalOrdered. Helpers
+// such as ObjectId('...') become their extended JSON form, e.g., {"$oid": "..."}, so they're
+// redacted like in logs.
+type shellParser struct {
+	src string
+	pos int
+}
+
+func parseShellValue(src string) (any, error) {
+	p := &shellParser{src: src}
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q after the query", p.src[p.pos])
+	}
+	return value, nil
+}
+
+func (p *shellParser) errorf(format string, args ...any) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	column := p.pos - strings.LastIndex(p.src[:p.pos], "\n")
+	return fmt.Errorf("invalid query at line %d, column %d: %s", line, column, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips white space and comments.
+func (p *shellParser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch {
+		case strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])):
+			p.pos++
+		case strings.HasPrefix(p.src[p.pos:], "//"):
+			if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+				p.pos += end + 1
+			} else {
+				p.pos = len(p.src)
+			}
+		case strings.HasPrefix(p.src[p.pos:], "/*"):
+			if end := strings.Index(p.src[p.pos+2:], "*/"); end >= 0 {
+				p.pos += end + 4
+			} else {
+				p.pos = len(p.src)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next character that isn't white space, or 0 at the end of the input.
+func (p *shellParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *shellParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos >= len(p.src) {
+			return p.errorf("expected %q, got the end of the query", c)
+		}
+		return p.errorf("expected %q, got %q", c, p.src[p.pos])
+	}
+	p.pos++
+	return nil
+}
+
+func isShellIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *shellParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) && isShellIdentChar(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *shellParser) value() (any, error) {
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, p.errorf("unexpected end of the query")
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '\'' || c == '"':
+		return p.string()
+	case c == '/':
+		return p.regex()
+	case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9':
+		return p.number()
+	case isShellIdentChar(c):
+		start := p.pos
+		name := p.ident()
+		switch name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null", "undefined":
+			return nil, nil
+		case "new":
+			p.skipSpace()
+			start = p.pos
+			name = p.ident()
+		}
+		if p.peek() != '(' {
+			p.pos = start
+			return nil, p.errorf("unexpected %q", name)
+		}
+		return p.call(name, start)
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+func (p *shellParser) object() (any, error) {
+	p.pos++ // '{'
+	m := orderedmap.NewOrderedMap[string, any]()
+	for p.peek() != '}' {
+		var key string
+		switch c := p.peek(); {
+		case c == '\'' || c == '"':
+			s, err := p.string()
+			if err != nil {
+				return nil, err
+			}
+			key = s.(string)
+		case isShellIdentChar(c):
+			key = p.ident()
+		default:
+			return nil, p.errorf("expected a field name or '}', got %q", c)
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, v)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect('}'); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (p *shellParser) array() (any, error) {
+	p.pos++ // '['
+	arr := []any{}
+	for p.peek() != ']' {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect(']'); err != nil {
+		return nil, err
+	}
+	return arr, nil
+}
+
+var shellEscapes = map[byte]string{'n': "\n", 't': "\t", 'r': "\r", 'b': "\b", 'f': "\f", 'v': "\v", '0': "\x00"}
+
+func (p *shellParser) string() (any, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c == '\n':
+			return nil, p.errorf("unterminated string")
+		case c == '\\' && p.pos+1 < len(p.src):
+			p.pos++
+			e := p.src[p.pos]
+			if s, ok := shellEscapes[e]; ok {
+				b.WriteString(s)
+				p.pos++
+				continue
+			}
+			if e == 'u' || e == 'x' {
+				r, n, ok := parseShellCodePoint(p.src[p.pos:])
+				if !ok {
+					return nil, p.errorf("invalid escape sequence")
+				}
+				b.WriteRune(r)
+				p.pos += n
+				continue
+			}
+			b.WriteByte(e)
+			p.pos++
+		default:
+			b.WriteByte(c)
+			p.pos++
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+// parseShellCodePoint parses the 'xHH', 'uHHHH' or 'u{H...}' escape sequence at the start of s,
+// returning the code point and the length of the sequence.
+func parseShellCodePoint(s string) (rune, int, bool) {
+	var digits string
+	var n int
+	switch {
+	case s[0] == 'x' && len(s) >= 3:
+		digits, n = s[1:3], 3
+	case strings.HasPrefix(s, "u{"):
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return 0, 0, false
+		}
+		digits, n = s[2:end], end+1
+	case s[0] == 'u' && len(s) >= 5:
+		digits, n = s[1:5], 5
+	default:
+		return 0, 0, false
+	}
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, 0, false
+	}
+	return rune(code), n, true
+}
+
+func (p *shellParser) regex() (any, error) {
+	p.pos++ // '/'
+	start := p.pos
+	inClass := false
+	for ; p.pos < len(p.src); p.pos++ {
+		switch c := p.src[p.pos]; {
+		case c == '\\':
+			p.pos++
+		case c == '\n':
+			return nil, p.errorf("unterminated regular expression")
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			pattern := p.src[start:p.pos]
+			p.pos++
+			return shellRegex(pattern, p.ident()), nil
+		}
+	}
+	return nil, p.errorf("unterminated regular expression")
+}
+
+func shellRegex(pattern, options string) any {
+	re := orderedmap.NewOrderedMap[string, any]()
+	re.Set("pattern", pattern)
+	re.Set("options", options)
+	return extendedJSON("$regularExpression", re)
+}
+
+func (p *shellParser) number() (any, error) {
+	start := p.pos
+	if c := p.src[p.pos]; c == '-' || c == '+' {
+		p.pos++
+	}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E' || (c == '-' || c == '+') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	literal := strings.TrimPrefix(p.src[start:p.pos], "+")
+	if _, err := strconv.ParseFloat(literal, 64); err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number %q", literal)
+	}
+	return shellNumber(literal), nil
+}
+
+// shellNumber returns literal as a json.Number, written the way JSON expects it.
+func shellNumber(literal string) json.Number {
+	if strings.HasPrefix(literal, ".") || strings.HasPrefix(literal, "-.") {
+		literal = strings.Replace(literal, ".", "0.", 1)
+	}
+	if strings.HasSuffix(literal, ".") {
+		literal += "0"
+	}
+	return json.Number(literal)
+}
+
+func (p *shellParser) call(name string, start int) (any, error) {
+	p.pos++ // '('
+	var args []any
+	for p.peek() != ')' {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	value, err := shellHelper(name, args)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("%v", err)
+	}
+	return value, nil
+}
+
+func extendedJSON(key string, value any) *orderedmap.OrderedMap[string, any] {
+	m := orderedmap.NewOrderedMap[string, any]()
+	m.Set(key, value)
+	return m
+}
+
+// shellNumberTypes maps the mongosh number helpers to their extended JSON key.
+var shellNumberTypes = map[string]string{
+	"NumberInt":     "$numberInt",
+	"Int32":         "$numberInt",
+	"NumberLong":    "$numberLong",
+	"Long":          "$numberLong",
+	"NumberDecimal": "$numberDecimal",
+	"Decimal128":    "$numberDecimal",
+	"Double":        "$numberDouble",
+}
+
+// shellHelper returns the extended JSON form of a call to a mongosh helper. Numbers are kept as
+// numbers inside it, instead of strings, so they're only redacted with --redactNumbers.
+func shellHelper(name string, args []any) (any, error) {
+	stringArg := func(i int) (string, bool) {
+		if i >= len(args) {
+			return "", false
+		}
+		s, ok := args[i].(string)
+		return s, ok
+	}
+	if key, ok := shellNumberTypes[name]; ok {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s() takes a number", name)
+		}
+		switch n := args[0].(type) {
+		case json.Number:
+			return extendedJSON(key, n), nil
+		case string:
+			if _, err := strconv.ParseFloat(n, 64); err == nil {
+				return extendedJSON(key, shellNumber(n)), nil
+			}
+		}
+		return nil, fmt.Errorf("%s() takes a number", name)
+	}
+	switch name {
+	case "ObjectId":
+		if s, ok := stringArg(0); ok && len(args) == 1 {
+			return extendedJSON("$oid", s), nil
+		}
+		return nil, errors.New("ObjectId() takes a hexadecimal string")
+	case "ISODate", "Date":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("%s() takes a date string or a number of milliseconds", name)
+		}
+		switch d := args[0].(type) {
+		case string:
+			return extendedJSON("$date", d), nil
+		case json.Number:
+			if millis, err := d.Int64(); err == nil {
+				return extendedJSON("$date", formatISODate(millis)), nil
+			}
+		}
+		return nil, fmt.Errorf("%s() takes a date string or a number of milliseconds", name)
+	case "UUID":
+		if s, ok := stringArg(0); ok && len(args) == 1 {
+			return extendedJSON("$uuid", s), nil
+		}
+		return nil, errors.New("UUID() takes a string")
+	case "BinData":
+		if len(args) != 2 {
+			return nil, errors.New("BinData() takes a subtype and a base64 string")
+		}
+		subType, ok := args[0].(json.Number)
+		data, isString := stringArg(1)
+		if !ok || !isString {
+			return nil, errors.New("BinData() takes a subtype and a base64 string")
+		}
+		n, err := subType.Int64()
+		if err != nil || n < 0 || n > 0xff {
+			return nil, fmt.Errorf("invalid BinData() subtype %s", subType)
+		}
+		binary := orderedmap.NewOrderedMap[string, any]()
+		binary.Set("base64", data)
+		binary.Set("subType", fmt.Sprintf("%02x", n))
+		return extendedJSON("$binary", binary), nil
+	case "Timestamp":
+		timestamp := orderedmap.NewOrderedMap[string, any]()
+		switch {
+		case len(args) == 2:
+			timestamp.Set("t", args[0])
+			timestamp.Set("i", args[1])
+		case len(args) == 1:
+			m, ok := args[0].(*orderedmap.OrderedMap[string, any])
+			if !ok {
+				return nil, errors.New("Timestamp() takes { t, i } or two numbers")
+			}
+			timestamp = m
+		default:
+			return nil, errors.New("Timestamp() takes { t, i } or two numbers")
+		}
+		return extendedJSON("$timestamp", timestamp), nil
+	case "RegExp":
+		pattern, ok := stringArg(0)
+		options, hasOptions := stringArg(1)
+		if !ok || len(args) > 2 || len(args) == 2 && !hasOptions {
+			return nil, errors.New("RegExp() takes a pattern and options")
+		}
+		return shellRegex(pattern, options), nil
+	case "MinKey", "MaxKey":
+		if len(args) != 0 {
+			return nil, fmt.Errorf("%s() takes no arguments", name)
+		}
+		return extendedJSON("$"+strings.ToLower(name[:1])+name[1:], json.Number("1")), nil
+	}
+	return nil, fmt.Errorf("unsupported function %s()", name)
+}
+
+var (
+	shellIdentRegex        = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	shellRegexOptionsRegex = regexp.MustCompile(`^[dgimsuvy]*$`)
+)
+
+// writeShellValue writes v in mongosh syntax, turning extended JSON back into mongosh helpers.
+func writeShellValue(b *strings.Builder, v any) {
+	switch v := v.(type) {
+	case *orderedmap.OrderedMap[string, any]:
+		if writeShellHelper(b, v) {
+			return
+		}
+		if v.Len() == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteString("{ ")
+		for el, i := v.Front(), 0; el != nil; el, i = el.Next(), i+1 {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			if shellIdentRegex.MatchString(el.Key) {
+				b.WriteString(el.Key)
+			} else {
+				writeShellString(b, el.Key)
+			}
+			b.WriteString(": ")
+			writeShellValue(b, el.Value)
+		}
+		b.WriteString(" }")
+	case []any:
+		if len(v) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteString("[ ")
+		for i, item := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeShellValue(b, item)
+		}
+		b.WriteString(" ]")
+	case string:
+		writeShellString(b, v)
+	case nil:
+		b.WriteString("null")
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			data = []byte(fmt.Sprint(v))
+		}
+		b.Write(data)
+	}
+}
+
+// writeShellHelper writes m as a mongosh helper call if it's the extended JSON form of one.
+func writeShellHelper(b *strings.Builder, m *orderedmap.OrderedMap[string, any]) bool {
+	if m.Len() != 1 {
+		return false
+	}
+	key := m.Front().Key
+	value := m.Front().Value
+	call := func(name string, args ...any) bool {
+		b.WriteString(name)
+		b.WriteByte('(')
+		for i, arg := range args {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeShellValue(b, arg)
+		}
+		b.WriteByte(')')
+		return true
+	}
+	s, isString := value.(string)
+	switch key {
+	case "$oid":
+		if isString {
+			return call("ObjectId", s)
+		}
+	case "$date":
+		if isString {
+			return call("ISODate", s)
+		}
+	case "$numberInt", "$numberLong", "$numberDouble":
+		return call(map[string]string{"$numberInt": "NumberInt", "$numberLong": "NumberLong", "$numberDouble": "Double"}[key], value)
+	case "$numberDecimal":
+		if n, ok := value.(json.Number); ok {
+			value = n.String()
+		}
+		return call("NumberDecimal", value)
+	case "$uuid":
+		// An encrypted UUID isn't one anymore, so it's left in extended JSON.
+		if isString && uuidRegex.MatchString(s) {
+			return call("UUID", s)
+		}
+	case "$binary":
+		if binary, ok := value.(*orderedmap.OrderedMap[string, any]); ok && binary.Len() == 2 {
+			data, _ := binary.Get("base64")
+			subType, _ := binary.Get("subType")
+			subTypeHex, _ := subType.(string)
+			if n, err := strconv.ParseUint(subTypeHex, 16, 8); err == nil {
+				return call("BinData", json.Number(strconv.FormatUint(n, 10)), data)
+			}
+		}
+	case "$timestamp":
+		return call("Timestamp", value)
+	case "$regularExpression":
+		re, ok := value.(*orderedmap.OrderedMap[string, any])
+		if !ok || re.Len() != 2 {
+			return false
+		}
+		p, _ := re.Get("pattern")
+		o, _ := re.Get("options")
+		pattern, _ := p.(string)
+		options, isString := o.(string)
+		if !isString {
+			return false
+		}
+		// An encrypted pattern may not fit in a literal.
+		if pattern == "" || strings.ContainsAny(pattern, "/\n") || !shellRegexOptionsRegex.MatchString(options) {
+			return call("RegExp", pattern, options)
+		}
+		b.WriteString("/" + pattern + "/" + options)
+		return true
+	case "$minKey":
+		return call("MinKey")
+	case "$maxKey":
+		return call("MaxKey")
+	}
+	return false
+}
+
+func writeShellString(b *strings.Builder, s string) {
+	b.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\x%02x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('\'')
+}
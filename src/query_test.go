@@ -0,0 +1,203 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactQuery(t *testing.T) {
+	setOptionsRedactedStrings()
+
+	tests := []struct {
+		name     string
+		query    string
+		opts     QueryOptions
+		expected string
+	}{
+		{
+			name:     "JSON filter",
+			query:    `{"status": "shipped", "qty": {"$gt": 5}, "customer": {"$in": ["a@b.com", "bob"]}}`,
+			expected: `{"status":"REDACTED","qty":{"$gt":5},"customer":{"$in":["redacted@redacted.com","REDACTED"]}}`,
+		},
+		{
+			name:     "extended JSON filter",
+			query:    `{"_id": {"$oid": "6857dd3e0b8e9e87105654b1"}, "createdAt": {"$gte": {"$date": "2024-01-01T00:00:00Z"}}}`,
+			expected: `{"_id":{"$oid":"000000000000000000000000"},"createdAt":{"$gte":{"$date":"1970-01-01T00:00:00.000Z"}}}`,
+		},
+		{
+			name:     "JSON pipeline",
+			query:    `[{"$match": {"status": "A"}}, {"$group": {"_id": "$cust_id", "total": {"$sum": "$amount"}}}]`,
+			expected: `[{"$match":{"status":"REDACTED"}},{"$group":{"_id":"$cust_id","total":{"$sum":"$amount"}}}]`,
+		},
+		{
+			name:     "JSON update",
+			query:    `{"$set": {"name": "Alice"}, "$inc": {"visits": 1}}`,
+			expected: `{"$set":{"name":"REDACTED"},"$inc":{"visits":1}}`,
+		},
+		{
+			name:     "mongosh filter",
+			query:    "{ status: 'shipped', // a comment\n  _id: ObjectId(\"6857dd3e0b8e9e87105654b1\"), createdAt: { $gte: ISODate('2024-01-01') }, name: /^al/i, }",
+			expected: `{ status: 'REDACTED', _id: ObjectId('000000000000000000000000'), createdAt: { $gte: ISODate('1970-01-01T00:00:00.000Z') }, name: /REDACTED/i }`,
+		},
+		{
+			name:     "mongosh numbers",
+			query:    `{ qty: NumberLong(5), price: NumberDecimal('9.99'), n: new NumberInt("3"), f: .5, 'a.b': -1 }`,
+			expected: `{ qty: NumberLong(5), price: NumberDecimal('9.99'), n: NumberInt(3), f: 0.5, 'a.b': -1 }`,
+		},
+		{
+			name:     "mongosh helpers",
+			query:    `{ u: UUID('0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60'), b: BinData(4, 'ZGF0YQ=='), ts: Timestamp({ t: 1, i: 2 }), d: new Date(86400000), min: MinKey() }`,
+			expected: `{ u: UUID('00000000-0000-0000-0000-000000000000'), b: BinData(4, 'AAAAAAAAAAAAAAAAAAA='), ts: Timestamp({ t: 1, i: 2 }), d: ISODate('1970-01-01T00:00:00.000Z'), min: MinKey() }`,
+		},
+		{
+			name:     "mongosh pipeline",
+			query:    `[{ $match: { city: "Paris" } }, { $lookup: { from: 'users', localField: 'uid', foreignField: '_id', as: 'user' } }]`,
+			expected: `[ { $match: { city: 'REDACTED' } }, { $lookup: { from: 'users', localField: 'REDACTED', foreignField: 'REDACTED', as: 'user' } } ]`,
+		},
+		{
+			name:     "mongosh strings",
+			query:    `{ "it's": 'l\'a', x: "tab\there" }`,
+			expected: `{ 'it\'s': 'REDACTED', x: 'REDACTED' }`,
+		},
+		{
+			name:     "single aggregation stage",
+			query:    `{"$match": {"status": "A"}}`,
+			opts:     QueryOptions{Command: QueryCommandAggregate},
+			expected: `{"$match":{"status":"REDACTED"}}`,
+		},
+		{
+			name:     "update pipeline",
+			query:    `[{"$set": {"status": "closed"}}]`,
+			opts:     QueryOptions{Command: QueryCommandUpdate},
+			expected: `[{"$set":{"status":"REDACTED"}}]`,
+		},
+		{
+			name:     "null values",
+			query:    `{ deletedAt: null, email: 'a@b.com', status: { $ne: null }, tags: [null, 'x'], address: { zip: null } }`,
+			expected: `{ deletedAt: null, email: 'redacted@redacted.com', status: { $ne: null }, tags: [ null, 'REDACTED' ], address: { zip: null } }`,
+		},
+		{
+			name:     "null values in a pipeline",
+			query:    `[{"$match": {"deletedAt": null, "name": "bob"}}, {"$project": {"name": 1, "n": null}}]`,
+			expected: `[{"$match":{"deletedAt":null,"name":"REDACTED"}},{"$project":{"name":1,"n":null}}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RedactQuery(tt.query, tt.opts)
+			if err != nil {
+				t.Fatalf("RedactQuery() returned an unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("RedactQuery() =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestRedactQuery_RoundTrip checks that redacted mongosh queries can be parsed again, and that
+// redacting them again doesn't change them.
+func TestRedactQuery_RoundTrip(t *testing.T) {
+	setOptionsRedactedStrings()
+	queries := []string{
+		`{ name: /^al/i, code: /a\/b/, tags: { $in: [/x/gm, RegExp('y', 's')] } }`,
+		`{ u: UUID('0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60'), id: { $uuid: '0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60' } }`,
+		`[ { $match: { email: /@example\.com$/i, session: UUID('0e3b8a0a-6d2f-4d6e-9d4a-1b2c3d4e5f60') } } ]`,
+	}
+	for _, query := range queries {
+		t.Run(query, func(t *testing.T) {
+			redacted, err := RedactQuery(query, QueryOptions{})
+			if err != nil {
+				t.Fatalf("RedactQuery() returned an unexpected error: %v", err)
+			}
+			if _, err := parseShellValue(redacted); err != nil {
+				t.Fatalf("Redacted query %s can't be parsed: %v", redacted, err)
+			}
+			again, err := RedactQuery(redacted, QueryOptions{})
+			if err != nil || again != redacted {
+				t.Errorf("RedactQuery(%s) = %s, %v, want it unchanged", redacted, again, err)
+			}
+			if strings.Contains(redacted, "RegExp(") || strings.Contains(redacted, "UUID('REDACTED')") {
+				t.Errorf("RedactQuery() = %s, want regular expression literals and valid UUIDs", redacted)
+			}
+		})
+	}
+}
+
+func TestRedactQuery_Namespace(t *testing.T) {
+	setOptionsRedactedStringsWithEagerRedaction()
+	defer setOptionsRedactedStrings()
+
+	query := `{ foo: 'bar' }`
+	got, err := RedactQuery(query, QueryOptions{Namespace: "my_db.my_coll"})
+	if err != nil {
+		t.Fatalf("RedactQuery() returned an unexpected error: %v", err)
+	}
+	if expected := "{ " + HashName("foo") + ": 'REDACTED' }"; got != expected {
+		t.Errorf("Expected field names to be redacted on my_db.my_coll, but got %s", got)
+	}
+	got, err = RedactQuery(`{ deletedAt: null, foo: 'bar' }`, QueryOptions{Namespace: "my_db.my_coll"})
+	if err != nil {
+		t.Fatalf("RedactQuery() returned an unexpected error: %v", err)
+	}
+	if expected := "{ " + HashName("deletedAt") + ": null, " + HashName("foo") + ": 'REDACTED' }"; got != expected {
+		t.Errorf("Expected null values to be kept under their redacted field name, but got %s", got)
+	}
+	got, err = RedactQuery(query, QueryOptions{Namespace: "other.coll"})
+	if err != nil {
+		t.Fatalf("RedactQuery() returned an unexpected error: %v", err)
+	}
+	if got != `{ foo: 'REDACTED' }` {
+		t.Errorf("Expected field names to be kept on other.coll, but got %s", got)
+	}
+
+	rules, err := ParseFieldRules([]string{"shop.users:address.zip=keep"})
+	if err != nil {
+		t.Fatalf("ParseFieldRules() returned an unexpected error: %v", err)
+	}
+	SetFieldRules(rules)
+	got, err = RedactQuery(`{"address.zip": "75001", "name": "Alice"}`, QueryOptions{Namespace: "shop.users"})
+	if err != nil {
+		t.Fatalf("RedactQuery() returned an unexpected error: %v", err)
+	}
+	if got != `{"address.zip":"75001","name":"REDACTED"}` {
+		t.Errorf("Expected the field rule to keep address.zip, but got %s", got)
+	}
+}
+
+func TestRedactQuery_Errors(t *testing.T) {
+	setOptionsRedactedStrings()
+
+	tests := []struct {
+		name    string
+		query   string
+		opts    QueryOptions
+		wantErr string
+	}{
+		{"pipeline as a find filter", `[{"$match": {}}]`, QueryOptions{Command: QueryCommandFind}, "must be a document"},
+		{"scalar", `"status"`, QueryOptions{}, "must be a document"},
+		{"unterminated document", "{ a: 1,\n  b: ", QueryOptions{}, "line 2"},
+		{"unknown function", `{ a: Code('x') }`, QueryOptions{}, "unsupported function Code()"},
+		{"trailing content", `{ a: 1 } }`, QueryOptions{}, "after the query"},
+		{"invalid helper argument", `{ a: NumberLong('x') }`, QueryOptions{}, "NumberLong() takes a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RedactQuery(tt.query, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("RedactQuery() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseQueryCommand(t *testing.T) {
+	for name, want := range map[string]QueryCommand{"": QueryCommandAuto, "find": QueryCommandFind, "Aggregate": QueryCommandAggregate, "update": QueryCommandUpdate} {
+		if got, err := ParseQueryCommand(name); err != nil || got != want {
+			t.Errorf("ParseQueryCommand(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseQueryCommand("delete"); err == nil {
+		t.Errorf("ParseQueryCommand(\"delete\") error = nil, want an error")
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"bufio"
-	"compress/gzip"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -10,7 +10,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/schollz/progressbar/v3"
+	"github.com/elliotchance/orderedmap/v3"
 )
 
 var (
@@ -19,6 +19,17 @@ var (
 	defaultLogDuration = 60 * 60 * 24 * 7 // 7 days
 )
 
+// outputIndent is the number of spaces redacted entries are indented with; 0 writes each one on a
+// single line.
+var outputIndent = 0
+
+func SetOutputIndent(indent int) { outputIndent = indent }
+
+// entryFormat is what the entries of the input are.
+var entryFormat = EntryFormatLog
+
+func SetEntryFormat(format EntryFormat) { entryFormat = format }
+
 func SetAtlasLogStartDate(startDate int) { atlasLogStartDate = startDate }
 func SetAtlasLogEndDate(endDate int)     { atlasLogEndDate = endDate }
 
@@ -56,74 +67,89 @@ func (d *DefaultFileReader) GetExtension(filePath string) string {
 	return strings.ToLower(filepath.Ext(filePath))
 }
 
-func addOneToBar(bar *progressbar.ProgressBar) {
-	if bar != nil {
-		err := bar.Add(1)
-		if err != nil {
-			// In a real application, you might log this error.
-			// For a progress bar, silently returning is often acceptable.
-			return
-		}
+// redactEntry redacts a single entry of the input, according to its format.
+func redactEntry(line string) (*orderedmap.OrderedMap[string, any], error) {
+	switch entryFormat {
+	case EntryFormatProfile:
+		return RedactProfileDocument(line)
+	case EntryFormatExplain:
+		return RedactExplain(line)
+	case EntryFormatCurrentOp:
+		return RedactCurrentOp(line)
+	case EntryFormatAudit:
+		return RedactAuditEvent(line)
 	}
+	return RedactMongoLog(line)
 }
 
-func processMongoLogStream(r io.Reader, outWriter io.Writer, bar *progressbar.ProgressBar) error {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		// Ensure bar is not nil before accessing its state to prevent panics.
-		// The condition itself (empty line at max progress) is specific to the original logic.
-		if line == "" && bar != nil && bar.State().CurrentNum == bar.GetMax64() {
-			addOneToBar(bar)
-			continue
-		}
-		// RedactMongoLog is not provided in the context, assuming it's defined elsewhere.
-		redacted, err := RedactMongoLog(line)
-		if err != nil {
-			addOneToBar(bar)
-			continue
-		}
-		out, err := MarshalOrdered(redacted)
-		if err != nil {
-			addOneToBar(bar)
-			continue
+// redactLine redacts a single log line and writes it to outWriter. Lines that can't be parsed are
+// dropped.
+func redactLine(line string, outWriter io.Writer) {
+	redacted, err := redactEntry(line)
+	if err != nil {
+		reportLine(true)
+		return
+	}
+	reportLine(writeRedactedEntry(redacted, outWriter) != nil)
+}
+
+// writeRedactedEntry writes a redacted entry to outWriter on its own line, or indented with
+// outputIndent.
+func writeRedactedEntry(redacted *orderedmap.OrderedMap[string, any], outWriter io.Writer) error {
+	out, err := MarshalOrdered(redacted)
+	if err != nil {
+		return err
+	}
+	if outputIndent > 0 {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, out, "", strings.Repeat(" ", outputIndent)); err == nil {
+			out = indented.Bytes()
 		}
-		fmt.Fprintln(outWriter, string(out))
-		// addOneToBar already handles the nil check for 'bar', so no need for an 'if' here.
-		addOneToBar(bar)
 	}
-	if err := scanner.Err(); err != nil {
+	fmt.Fprintln(outWriter, string(out))
+	return nil
+}
+
+func processMongoLogStream(r io.Reader, outWriter io.Writer, progress *Progress) error {
+	s, err := openLogInput(r)
+	if err != nil {
 		return err
 	}
+	defer s.Close()
+	return redactLogStream(s, outWriter, progress)
+}
+
+func redactLogStream(s *logStream, outWriter io.Writer, progress *Progress) error {
+	err := forEachLogEntry(s, func(line string, lineNumber int) {
+		SetCurrentLineNumber(lineNumber)
+		redactLine(line, outWriter)
+		progress.AddEntry()
+	})
+	if err != nil {
+		return err
+	}
+	progress.Finish()
 	return nil
 }
 
 // ProcessMongoLogFile processes a MongoDB log file from the given filePath.
 // It now accepts a FileReader interface, allowing for dependency injection.
 // In production, you would pass &DefaultFileReader{}. In tests, you can pass a mock.
-func ProcessMongoLogFile(fileReader FileReader, filePath string, outWriter io.Writer, bar *progressbar.ProgressBar) error {
-	file, err := fileReader.Open(filePath)
+// Compression is detected from the content of the file, and progress is counted in bytes of the
+// file as stored, so compressed files report it accurately.
+func ProcessMongoLogFile(fileReader FileReader, filePath string, outWriter io.Writer, progress *Progress) error {
+	s, err := openLogFileInput(fileReader, filePath, progress)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	ext := fileReader.GetExtension(filePath)
-	if ext == ".gz" {
-		gzReader, err := gzip.NewReader(file)
-		if err != nil {
-			return fmt.Errorf("failed to create gzip reader: %w", err)
-		}
-		defer gzReader.Close()
-		return processMongoLogStream(gzReader, outWriter, bar)
-	}
-	return processMongoLogStream(file, outWriter, bar)
+	defer s.Close()
+	return redactLogStream(s, outWriter, progress)
 }
 
 // ProcessMongoLogFileFromReader reads from any io.Reader (such as stdin), redacts each line, and writes the result.
 // This function remains unchanged as it already accepts an io.Reader and doesn't directly access the filesystem.
-func ProcessMongoLogFileFromReader(r io.Reader, outWriter io.Writer, bar *progressbar.ProgressBar) error {
-	return processMongoLogStream(r, outWriter, bar)
+func ProcessMongoLogFileFromReader(r io.Reader, outWriter io.Writer, progress *Progress) error {
+	return processMongoLogStream(progress.Reader(r), outWriter, progress)
 }
 
 // No changes needed here for Atlas mode; all orchestration is handled in main.go
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"errors"
 	"io"
 	"os"            // Added for reading fixture files
@@ -68,7 +69,7 @@ func getFixtureContent(t *testing.T, fixturePath string) string {
 // generateExpectedOutput generates the expected JSON output based on the mock RedactMongoLog.
 // It marshals the result of RedactMongoLog and appends a newline.
 func generateExpectedOutput(t *testing.T, inputLine string) string {
-	redacted, err := RedactMongoLog(inputLine)
+	redacted, err := redactEntry(inputLine)
 	if err != nil {
 		t.Fatalf("Mock RedactMongoLog failed for input '%s': %v", inputLine, err)
 	}
@@ -79,6 +80,23 @@ func generateExpectedOutput(t *testing.T, inputLine string) string {
 	return string(out) + "\n"
 }
 
+// fixtureEntryFormat returns the format of the entries of a fixture, from its name.
+func fixtureEntryFormat(name string) EntryFormat {
+	if strings.HasPrefix(name, "profile_") {
+		return EntryFormatProfile
+	}
+	if strings.HasPrefix(name, "explain_") {
+		return EntryFormatExplain
+	}
+	if strings.HasPrefix(name, "currentop_") {
+		return EntryFormatCurrentOp
+	}
+	if strings.HasPrefix(name, "audit_") {
+		return EntryFormatAudit
+	}
+	return EntryFormatLog
+}
+
 // TestProcessMongoLogFile_Standard tests processing a standard, non-gzipped log file.
 func TestProcessMongoLogFile_Standard(t *testing.T) {
 	logContent := getFixtureContent(t, "test_fixtures/simple_find.json")
@@ -219,3 +237,60 @@ func TestProcessMongoLogFileFromReader(t *testing.T) {
 		t.Errorf("Unexpected output from reader.\nGot:\n%s\nWant:\n%s", outBuffer.String(), expectedOutput)
 	}
 }
+
+// TestProcessMongoLogFile_PrettyPrintedFixtures redacts the pretty-printed fixtures as they are.
+func TestProcessMongoLogFile_PrettyPrintedFixtures(t *testing.T) {
+	setOptionsRedactedStrings()
+	fixtures, err := filepath.Glob(filepath.Join("..", "test_fixtures", "*.json"))
+	if err != nil {
+		t.Fatalf("Failed to list fixtures: %v", err)
+	}
+	for _, fixture := range fixtures {
+		name := filepath.Base(fixture)
+		if strings.HasPrefix(name, "cluster-info") || strings.HasPrefix(name, "processes-response") {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			SetEntryFormat(fixtureEntryFormat(name))
+			defer SetEntryFormat(EntryFormatLog)
+			expectedOutput := generateExpectedOutput(t, getFixtureContent(t, "test_fixtures/"+name))
+			var outBuffer bytes.Buffer
+			if err := ProcessMongoLogFile(&DefaultFileReader{}, fixture, &outBuffer, nil); err != nil {
+				t.Fatalf("ProcessMongoLogFile returned an unexpected error: %v", err)
+			}
+			if outBuffer.String() != expectedOutput {
+				t.Errorf("Unexpected output.\nGot:\n%s\nWant:\n%s", outBuffer.String(), expectedOutput)
+			}
+		})
+	}
+}
+
+// TestProcessMongoLogFileFromReader_Indent tests pretty-printed output, read back as input.
+func TestProcessMongoLogFileFromReader_Indent(t *testing.T) {
+	setOptionsRedactedStrings()
+	defer SetOutputIndent(0)
+	logContent := getFixtureContent(t, "test_fixtures/simple_find.json")
+	expectedOutput := generateExpectedOutput(t, logContent)
+
+	SetOutputIndent(4)
+	var outBuffer bytes.Buffer
+	if err := ProcessMongoLogFileFromReader(strings.NewReader("["+logContent+","+logContent+"]"), &outBuffer, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+	}
+	var indented bytes.Buffer
+	json.Indent(&indented, []byte(strings.TrimSuffix(expectedOutput, "\n")), "", "    ")
+	want := indented.String() + "\n" + indented.String() + "\n"
+	if outBuffer.String() != want {
+		t.Errorf("Unexpected output.\nGot:\n%s\nWant:\n%s", outBuffer.String(), want)
+	}
+
+	// Redacting the pretty-printed output again leaves it unchanged.
+	SetOutputIndent(0)
+	var again bytes.Buffer
+	if err := ProcessMongoLogFileFromReader(&outBuffer, &again, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+	}
+	if again.String() != expectedOutput+expectedOutput {
+		t.Errorf("Unexpected output.\nGot:\n%s\nWant:\n%s", again.String(), expectedOutput+expectedOutput)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ReportCounts tallies the changes made (or that would be made) by redaction.
+type ReportCounts struct {
+	ValuesRedacted   int `json:"valuesRedacted"`
+	FieldNamesHashed int `json:"fieldNamesHashed"`
+	EmailsDetected   int `json:"emailsDetected"`
+	IPsDetected      int `json:"ipsDetected"`
+	UnknownOperators int `json:"unknownOperators"`
+}
+
+// RedactionReport describes what a redaction run changed, broken down by namespace, log component
+// and field path, so it can be reviewed without reading the redacted output.
+type RedactionReport struct {
+	LinesRead        int                      `json:"linesRead"`
+	LinesWritten     int                      `json:"linesWritten"`
+	LinesDropped     int                      `json:"linesDropped"`
+	Totals           ReportCounts             `json:"totals"`
+	Namespaces       map[string]*ReportCounts `json:"namespaces"`
+	Components       map[string]*ReportCounts `json:"components"`
+	FieldPaths       map[string]*ReportCounts `json:"fieldPaths"`
+	UnknownOperators []UnknownOperator        `json:"unknownOperators"`
+}
+
+var (
+	redactionReport  *RedactionReport
+	currentComponent string
+)
+
+// noFieldPath labels values that don't belong to a named field, such as operator arguments.
+const noFieldPath = "(none)"
+
+// EnableRedactionReport starts collecting a new redaction report.
+func EnableRedactionReport() {
+	redactionReport = &RedactionReport{
+		Namespaces: map[string]*ReportCounts{},
+		Components: map[string]*ReportCounts{},
+		FieldPaths: map[string]*ReportCounts{},
+	}
+}
+
+// DisableRedactionReport stops collecting the redaction report.
+func DisableRedactionReport() { redactionReport = nil }
+
+// GetRedactionReport returns the report collected so far, or nil if reporting isn't enabled.
+func GetRedactionReport() *RedactionReport {
+	if redactionReport != nil {
+		redactionReport.UnknownOperators = UnknownOperators()
+	}
+	return redactionReport
+}
+
+func SetCurrentComponent(c string) { currentComponent = c }
+
+// WriteJSON writes the report as indented JSON.
+func (r *RedactionReport) WriteJSON(w io.Writer) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal redaction report: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// WriteRedactionReport writes the collected report to filePath, or to stdout if filePath is empty.
+func WriteRedactionReport(filePath string) error {
+	report := GetRedactionReport()
+	if report == nil {
+		return nil
+	}
+	if filePath == "" {
+		return report.WriteJSON(os.Stdout)
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer f.Close()
+	return report.WriteJSON(f)
+}
+
+func reportCountsFor(m map[string]*ReportCounts, key string) *ReportCounts {
+	counts, ok := m[key]
+	if !ok {
+		counts = &ReportCounts{}
+		m[key] = counts
+	}
+	return counts
+}
+
+// reportTally applies update to the totals and to the current namespace, component and fieldPath.
+func reportTally(fieldPath string, update func(*ReportCounts)) {
+	if redactionReport == nil {
+		return
+	}
+	if fieldPath == "" {
+		fieldPath = noFieldPath
+	}
+	update(&redactionReport.Totals)
+	update(reportCountsFor(redactionReport.Namespaces, currentNamespace))
+	update(reportCountsFor(redactionReport.Components, currentComponent))
+	update(reportCountsFor(redactionReport.FieldPaths, fieldPath))
+}
+
+func reportRedactedValue(keyPath []string, v any) any {
+	reportTally(strings.Join(fieldPathFromKeyPath(keyPath), "."), func(c *ReportCounts) { c.ValuesRedacted++ })
+	return v
+}
+
+func reportEmailDetected(keyPath []string) {
+	reportTally(strings.Join(fieldPathFromKeyPath(keyPath), "."), func(c *ReportCounts) { c.EmailsDetected++ })
+}
+
+func reportIPDetected(field string) {
+	reportTally(field, func(c *ReportCounts) { c.IPsDetected++ })
+}
+
+func reportHashedName(name string) {
+	reportTally(strings.TrimLeft(name, "$"), func(c *ReportCounts) { c.FieldNamesHashed++ })
+}
+
+func reportUnknownOperator() {
+	reportTally("", func(c *ReportCounts) { c.UnknownOperators++ })
+}
+
+func reportLine(dropped bool) {
+	if redactionReport == nil {
+		return
+	}
+	redactionReport.LinesRead++
+	if dropped {
+		redactionReport.LinesDropped++
+	} else {
+		redactionReport.LinesWritten++
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func processWithReport(t *testing.T, input string) *RedactionReport {
+	t.Helper()
+	ResetUnknownOperators()
+	EnableRedactionReport()
+	t.Cleanup(func() {
+		DisableRedactionReport()
+		ResetUnknownOperators()
+	})
+	if err := ProcessMongoLogFileFromReader(strings.NewReader(input), io.Discard, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+	}
+	return GetRedactionReport()
+}
+
+func TestRedactionReport_CountsPerNamespaceComponentAndField(t *testing.T) {
+	setOptionsRedactedStrings()
+	input := strings.Join([]string{
+		getFixtureContent(t, "test_fixtures/simple_find.json"),
+		getFixtureContent(t, "test_fixtures/find_with_emails.json"),
+		"this is not a log line",
+	}, "\n")
+
+	report := processWithReport(t, input)
+
+	if report.LinesRead != 3 || report.LinesWritten != 2 || report.LinesDropped != 1 {
+		t.Errorf("Expected 3 lines read, 2 written and 1 dropped, but got %d, %d and %d",
+			report.LinesRead, report.LinesWritten, report.LinesDropped)
+	}
+	ns, ok := report.Namespaces["my_db.my_coll"]
+	if !ok {
+		t.Fatalf("Expected namespace my_db.my_coll in the report, but got %v", report.Namespaces)
+	}
+	if ns.ValuesRedacted != report.Totals.ValuesRedacted {
+		t.Errorf("Expected every redacted value to belong to my_db.my_coll (%d), but got %d",
+			report.Totals.ValuesRedacted, ns.ValuesRedacted)
+	}
+	if _, ok := report.Components["COMMAND"]; !ok {
+		t.Errorf("Expected component COMMAND in the report, but got %v", report.Components)
+	}
+	if got := report.FieldPaths["foo"]; got == nil || got.ValuesRedacted != 1 {
+		t.Errorf("Expected 1 redacted value for field foo, but got %+v", got)
+	}
+	if got := report.FieldPaths["username"]; got == nil || got.EmailsDetected == 0 {
+		t.Errorf("Expected emails detected in field username, but got %+v", got)
+	}
+	if report.Totals.FieldNamesHashed != 0 {
+		t.Errorf("Expected no hashed field names without eager redaction, but got %d", report.Totals.FieldNamesHashed)
+	}
+}
+
+func TestRedactionReport_HashedFieldNames(t *testing.T) {
+	setOptionsRedactedStringsWithEagerRedaction()
+	defer setOptionsRedactedStrings()
+
+	report := processWithReport(t, getFixtureContent(t, "test_fixtures/simple_find.json"))
+
+	if report.Totals.FieldNamesHashed == 0 {
+		t.Errorf("Expected hashed field names with eager redaction, but got none")
+	}
+	if got := report.FieldPaths["foo"]; got == nil || got.FieldNamesHashed == 0 {
+		t.Errorf("Expected field foo to be hashed, but got %+v", got)
+	}
+}
+
+func TestRedactionReport_IncludesUnknownOperators(t *testing.T) {
+	setOptionsRedactedStrings()
+
+	report := processWithReport(t, getFixtureContent(t, "test_fixtures/unknown_operators.json"))
+
+	if report.Totals.UnknownOperators != 2 {
+		t.Errorf("Expected 2 unknown operators, but got %d", report.Totals.UnknownOperators)
+	}
+	if len(report.UnknownOperators) != 2 {
+		t.Errorf("Expected 2 unknown operator summaries, but got %+v", report.UnknownOperators)
+	}
+}
+
+func TestRedactionReport_WriteJSON(t *testing.T) {
+	setOptionsRedactedStrings()
+	report := processWithReport(t, getFixtureContent(t, "test_fixtures/simple_find.json"))
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON returned an unexpected error: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON wrote invalid JSON: %v", err)
+	}
+	for _, key := range []string{"linesRead", "linesWritten", "linesDropped", "totals", "namespaces", "components", "fieldPaths", "unknownOperators"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected key %q in the report JSON", key)
+		}
+	}
+}
+
+func TestRedactionReport_DisabledByDefault(t *testing.T) {
+	setOptionsRedactedStrings()
+	DisableRedactionReport()
+	if err := ProcessMongoLogFileFromReader(strings.NewReader(getFixtureContent(t, "test_fixtures/simple_find.json")), io.Discard, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+	}
+	if report := GetRedactionReport(); report != nil {
+		t.Errorf("Expected no report when reporting is disabled, but got %+v", report)
+	}
+}
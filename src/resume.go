@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrRedactionInterrupted is returned when a resumable redaction is stopped before it completes.
+var ErrRedactionInterrupted = errors.New("redaction interrupted")
+
+// checkpointInterval is how often a resumable redaction records its progress.
+var checkpointInterval = 5 * time.Second
+
+// CheckpointPath returns where the checkpoint of a redaction writing to outputFile is kept.
+func CheckpointPath(outputFile string) string {
+	return outputFile + ".checkpoint"
+}
+
+// countingWriter counts the bytes written to w, and keeps the first write error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
+
+// RedactMongoLogFileResumable redacts inputFile to outputFile, recording its progress in a
+// checkpoint next to the output every few seconds, and when it fails or ctx is canceled. The
+// checkpoint is removed once the input is fully redacted.
+//
+// If resume is set, the output is validated against the checkpoint left by a previous run,
+// anything written after that checkpoint is discarded, and redaction continues from there.
+func RedactMongoLogFileResumable(ctx context.Context, inputFile, outputFile string, resume bool, progress *Progress) error {
+	path, err := filepath.Abs(inputFile)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	header := make([]byte, magicSize)
+	n, err := in.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	compressed := DetectCompression(header[:n]) != CompressionNone
+	checkpointFile := CheckpointPath(outputFile)
+
+	var cp *Checkpoint
+	var out *os.File
+	if resume {
+		cp, out, err = resumeFromCheckpoint(checkpointFile, path, in, outputFile)
+	} else {
+		if err := os.Remove(checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove stale checkpoint: %w", err)
+		}
+		out, err = os.OpenFile(outputFile, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	}
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	offset, lineNumber := int64(0), 0
+	if cp != nil {
+		offset, lineNumber = cp.Offset, cp.LineNumber
+		restoreHashKey(cp.HashKey)
+		if !compressed {
+			if _, err := in.Seek(offset, io.SeekStart); err != nil {
+				return fmt.Errorf("failed to skip to the checkpoint: %w", err)
+			}
+		}
+	}
+	var s *logStream
+	if cp == nil {
+		s, err = openLogInput(progress.Reader(in))
+	} else {
+		s, err = openLogStream(progress.Reader(in))
+	}
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if s.Format == FormatPrettyJSON {
+		// Entries spanning several lines have no offset to resume from, so no checkpoint is kept.
+		return redactLogStream(s, out, progress)
+	}
+	if cp != nil {
+		skipped := offset
+		if compressed {
+			// Compressed streams can't be seeked, so the data before the checkpoint is decompressed again.
+			if _, err := io.CopyN(io.Discard, s, offset); err != nil {
+				return fmt.Errorf("failed to skip to the checkpoint: %w", err)
+			}
+			skipped = 0
+		}
+		progress.Resume(skipped, int64(lineNumber))
+	}
+
+	cw := &countingWriter{w: out}
+	if cp != nil {
+		cw.n = cp.OutputSize
+	}
+	saveCheckpoint := func() error {
+		if err := out.Sync(); err != nil {
+			return fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+		fingerprint, err := fileFingerprint(in, offset)
+		if err != nil {
+			return fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+		outFingerprint, err := outputFingerprint(out, cw.n)
+		if err != nil {
+			return fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+		next := &Checkpoint{
+			Path:              path,
+			Offset:            offset,
+			Fingerprint:       fingerprint,
+			Compressed:        compressed,
+			LineNumber:        lineNumber,
+			OutputSize:        cw.n,
+			OutputFingerprint: outFingerprint,
+			HashKey:           checkpointHashKey(),
+		}
+		return next.Save(checkpointFile)
+	}
+	// failWith records the progress made before a failure, so the run can be resumed.
+	failWith := func(cause error) error {
+		if err := saveCheckpoint(); err != nil {
+			return fmt.Errorf("%w (%v)", cause, err)
+		}
+		return cause
+	}
+
+	reader := bufio.NewReader(s)
+	lastCheckpoint := time.Now()
+	for {
+		if ctx.Err() != nil {
+			return failWith(ErrRedactionInterrupted)
+		}
+		raw, readErr := reader.ReadBytes('\n')
+		if len(raw) > 0 {
+			line := strings.TrimSuffix(strings.TrimSuffix(string(raw), "\n"), "\r")
+			SetCurrentLineNumber(lineNumber + 1)
+			sizeBefore := cw.n
+			if line != "" {
+				redactLine(line, cw)
+			}
+			if cw.err != nil {
+				// The line may have been partially written; the next run truncates it.
+				cw.n = sizeBefore
+				return failWith(fmt.Errorf("failed to write output: %w", cw.err))
+			}
+			offset += int64(len(raw))
+			lineNumber++
+			progress.AddEntry()
+		}
+		if readErr != nil {
+			if !errors.Is(readErr, io.EOF) {
+				return failWith(fmt.Errorf("failed to read %s: %w", inputFile, readErr))
+			}
+			break
+		}
+		if time.Since(lastCheckpoint) >= checkpointInterval {
+			if err := saveCheckpoint(); err != nil {
+				return err
+			}
+			lastCheckpoint = time.Now()
+		}
+	}
+	progress.Finish()
+	if err := os.Remove(checkpointFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
+
+// resumeFromCheckpoint loads the checkpoint of a previous run, checks that it was taken on the
+// same input and that the output still holds what was written up to it, and reopens the output
+// for writing right after it.
+func resumeFromCheckpoint(checkpointFile, path string, in *os.File, outputFile string) (*Checkpoint, *os.File, error) {
+	cp, err := LoadCheckpoint(checkpointFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cp == nil {
+		return nil, nil, fmt.Errorf("no checkpoint found at %s", checkpointFile)
+	}
+	if !cp.Matches(path, in) {
+		return nil, nil, fmt.Errorf("checkpoint %s was taken on a different input file", checkpointFile)
+	}
+	out, err := os.OpenFile(outputFile, os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open partial output: %w", err)
+	}
+	info, err := out.Stat()
+	if err == nil && info.Size() < cp.OutputSize {
+		err = fmt.Errorf("it's shorter than recorded in the checkpoint")
+	}
+	if err == nil {
+		var fingerprint string
+		fingerprint, err = outputFingerprint(out, cp.OutputSize)
+		if err == nil && fingerprint != cp.OutputFingerprint {
+			err = fmt.Errorf("it doesn't match the checkpoint")
+		}
+	}
+	if err == nil {
+		err = out.Truncate(cp.OutputSize)
+	}
+	if err == nil {
+		_, err = out.Seek(cp.OutputSize, io.SeekStart)
+	}
+	if err != nil {
+		out.Close()
+		return nil, nil, fmt.Errorf("can't resume partial output %s: %w", outputFile, err)
+	}
+	return cp, out, nil
+}
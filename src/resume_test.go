@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeResumeInput(t *testing.T, dir string, gzipped bool) (string, []string) {
+	t.Helper()
+	lines := []string{
+		getFixtureContent(t, "test_fixtures/simple_find.json"),
+		getFixtureContent(t, "test_fixtures/find_with_emails.json"),
+		getFixtureContent(t, "test_fixtures/updateOne.json"),
+		getFixtureContent(t, "test_fixtures/simple_aggregation.json"),
+	}
+	content := strings.Join(lines, "\n") + "\n"
+	path := filepath.Join(dir, "mongod.log")
+	if !gzipped {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+		return path, lines
+	}
+	path += ".gz"
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create input: %v", err)
+	}
+	gz := gzip.NewWriter(f)
+	gz.Write([]byte(content))
+	gz.Close()
+	f.Close()
+	return path, lines
+}
+
+// writePartialRun leaves the output and checkpoint of a run interrupted after n lines, with part
+// of the next line written after the checkpoint.
+func writePartialRun(t *testing.T, input, output string, lines []string, fullOutput string, n int) {
+	t.Helper()
+	outLines := strings.SplitAfter(fullOutput, "\n")
+	written := strings.Join(outLines[:n], "")
+	if err := os.WriteFile(output, []byte(written+outLines[n][:10]), 0o644); err != nil {
+		t.Fatalf("Failed to write partial output: %v", err)
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		t.Fatalf("Failed to open input: %v", err)
+	}
+	defer in.Close()
+	offset := int64(len(strings.Join(lines[:n], "\n")) + 1)
+	fingerprint, _ := fileFingerprint(in, offset)
+	outFingerprint, _ := outputFingerprint(strings.NewReader(written), int64(len(written)))
+	cp := &Checkpoint{
+		Path:              input,
+		Offset:            offset,
+		Fingerprint:       fingerprint,
+		Compressed:        strings.HasSuffix(input, ".gz"),
+		LineNumber:        n,
+		OutputSize:        int64(len(written)),
+		OutputFingerprint: outFingerprint,
+		HashKey:           checkpointHashKey(),
+	}
+	if err := cp.Save(CheckpointPath(output)); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+}
+
+func TestRedactMongoLogFileResumable(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		name := "plain"
+		if gzipped {
+			name = "gzip"
+		}
+		t.Run(name, func(t *testing.T) {
+			setOptionsRedactedStrings()
+			dir := t.TempDir()
+			input, lines := writeResumeInput(t, dir, gzipped)
+			full := filepath.Join(dir, "full.log")
+			if err := RedactMongoLogFileResumable(context.Background(), input, full, false, nil); err != nil {
+				t.Fatalf("RedactMongoLogFileResumable returned an unexpected error: %v", err)
+			}
+			if FileExists(CheckpointPath(full)) {
+				t.Errorf("Expected the checkpoint to be removed after a complete run")
+			}
+			fullOutput, _ := os.ReadFile(full)
+			if got := strings.Count(string(fullOutput), "\n"); got != len(lines) {
+				t.Fatalf("Expected %d redacted lines, but got %d", len(lines), got)
+			}
+
+			output := filepath.Join(dir, "resumed.log")
+			writePartialRun(t, input, output, lines, string(fullOutput), 2)
+			if err := RedactMongoLogFileResumable(context.Background(), input, output, true, nil); err != nil {
+				t.Fatalf("RedactMongoLogFileResumable returned an unexpected error on resume: %v", err)
+			}
+			resumedOutput, _ := os.ReadFile(output)
+			if string(resumedOutput) != string(fullOutput) {
+				t.Errorf("Expected the resumed output to match a complete run:\n%s\nbut got:\n%s", fullOutput, resumedOutput)
+			}
+		})
+	}
+}
+
+// TestRedactMongoLogFileResumable_HashKey checks that values hashed by field rules get the same
+// hashes after resuming, though a new run has a new random key.
+func TestRedactMongoLogFileResumable_HashKey(t *testing.T) {
+	setOptionsRedactedStrings()
+	SetFieldRules(mustParseFieldRules("my_db.*:foo=hash"))
+	defer SetFieldRules(nil)
+	defer restoreHashKey(valueHashKey())
+
+	dir := t.TempDir()
+	input, lines := writeResumeInput(t, dir, false)
+	full := filepath.Join(dir, "full.log")
+	if err := RedactMongoLogFileResumable(context.Background(), input, full, false, nil); err != nil {
+		t.Fatalf("RedactMongoLogFileResumable returned an unexpected error: %v", err)
+	}
+	fullOutput, _ := os.ReadFile(full)
+	if !strings.Contains(string(fullOutput), HashValue("simple string")) {
+		t.Fatalf("Expected the output to hold a hashed value, but got:\n%s", fullOutput)
+	}
+
+	output := filepath.Join(dir, "resumed.log")
+	writePartialRun(t, input, output, lines, string(fullOutput), 1)
+	restoreHashKey(bytes.Repeat([]byte{9}, 32))
+	if err := RedactMongoLogFileResumable(context.Background(), input, output, true, nil); err != nil {
+		t.Fatalf("RedactMongoLogFileResumable returned an unexpected error on resume: %v", err)
+	}
+	resumedOutput, _ := os.ReadFile(output)
+	if string(resumedOutput) != string(fullOutput) {
+		t.Errorf("Expected the resumed output to match a complete run:\n%s\nbut got:\n%s", fullOutput, resumedOutput)
+	}
+}
+
+func TestRedactMongoLogFileResumable_Interrupted(t *testing.T) {
+	setOptionsRedactedStrings()
+	dir := t.TempDir()
+	input, lines := writeResumeInput(t, dir, false)
+	output := filepath.Join(dir, "out.log")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := RedactMongoLogFileResumable(ctx, input, output, false, nil)
+	if !errors.Is(err, ErrRedactionInterrupted) {
+		t.Fatalf("Expected ErrRedactionInterrupted, but got %v", err)
+	}
+	cp, err := LoadCheckpoint(CheckpointPath(output))
+	if err != nil || cp == nil {
+		t.Fatalf("Expected a checkpoint after an interruption, but got %v (error: %v)", cp, err)
+	}
+	// The checkpoint sits next to the output, so it's only readable by its owner, and holds no
+	// names from the input.
+	info, err := os.Stat(CheckpointPath(output))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the checkpoint to be written with mode 0600, got %v (error: %v)", info.Mode().Perm(), err)
+	}
+	if data, _ := os.ReadFile(CheckpointPath(output)); strings.Contains(string(data), "fieldMapping") {
+		t.Errorf("Expected the checkpoint to hold no field names, got %s", data)
+	}
+
+	if err := RedactMongoLogFileResumable(context.Background(), input, output, true, nil); err != nil {
+		t.Fatalf("RedactMongoLogFileResumable returned an unexpected error on resume: %v", err)
+	}
+	data, _ := os.ReadFile(output)
+	if got := strings.Count(string(data), "\n"); got != len(lines) {
+		t.Errorf("Expected %d redacted lines, but got %d", len(lines), got)
+	}
+}
+
+func TestRedactMongoLogFileResumable_ResumeValidation(t *testing.T) {
+	setOptionsRedactedStrings()
+	dir := t.TempDir()
+	input, lines := writeResumeInput(t, dir, false)
+	full := filepath.Join(dir, "full.log")
+	if err := RedactMongoLogFileResumable(context.Background(), input, full, false, nil); err != nil {
+		t.Fatalf("RedactMongoLogFileResumable returned an unexpected error: %v", err)
+	}
+	fullOutput, _ := os.ReadFile(full)
+	output := filepath.Join(dir, "out.log")
+
+	tests := []struct {
+		name    string
+		prepare func()
+		errText string
+	}{
+		{
+			name:    "no checkpoint",
+			prepare: func() { os.Remove(CheckpointPath(output)) },
+			errText: "no checkpoint found",
+		},
+		{
+			name:    "output modified",
+			prepare: func() { os.WriteFile(output, []byte(strings.Repeat("x", 4000)), 0o644) },
+			errText: "doesn't match the checkpoint",
+		},
+		{
+			name:    "output shorter",
+			prepare: func() { os.WriteFile(output, []byte("{}\n"), 0o644) },
+			errText: "shorter than recorded",
+		},
+		{
+			name: "input replaced",
+			prepare: func() {
+				os.WriteFile(input, []byte(strings.Join(lines[1:], "\n")+"\n"), 0o644)
+			},
+			errText: "different input file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writePartialRun(t, input, output, lines, string(fullOutput), 2)
+			tt.prepare()
+			err := RedactMongoLogFileResumable(context.Background(), input, output, true, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected an error containing %q, but got %v", tt.errText, err)
+			}
+		})
+	}
+}
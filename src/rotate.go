@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// RotatingFileWriter writes to a file, and rotates it once it reaches a maximum size: the current
+// file is renamed to '<path>.1', older files are shifted to '<path>.2', '<path>.3', and so on, and
+// files beyond the maximum count are removed.
+type RotatingFileWriter struct {
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// NewRotatingFileWriter opens path for writing. If appendToFile is set, writes continue at the end
+// of an existing file; otherwise it's truncated. A maxBytes of 0 disables rotation, and maxFiles is
+// the number of rotated files kept besides the current one.
+func NewRotatingFileWriter(path string, maxBytes int64, maxFiles int, appendToFile bool) (*RotatingFileWriter, error) {
+	w := &RotatingFileWriter{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendToFile {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	if err := w.open(flags); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotatingFileWriter) open(flags int) error {
+	f, err := os.OpenFile(w.path, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open output file: %w", err)
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// Write implements io.Writer. A single write is never split across files.
+func (w *RotatingFileWriter) Write(p []byte) (int, error) {
+	if w.maxBytes > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingFileWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to rotate output file: %w", err)
+	}
+	if w.maxFiles <= 0 {
+		if err := os.Remove(w.path); err != nil {
+			return fmt.Errorf("failed to rotate output file: %w", err)
+		}
+	} else {
+		if err := os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate output file: %w", err)
+		}
+		for i := w.maxFiles - 1; i >= 1; i-- {
+			if err := os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to rotate output file: %w", err)
+			}
+		}
+		if err := os.Rename(w.path, w.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate output file: %w", err)
+		}
+	}
+	return w.open(os.O_CREATE | os.O_WRONLY | os.O_TRUNC)
+}
+
+// Close closes the current file.
+func (w *RotatingFileWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFileOrEmpty(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+func TestRotatingFileWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	w, err := NewRotatingFileWriter(path, 10, 2, false)
+	if err != nil {
+		t.Fatalf("NewRotatingFileWriter returned an unexpected error: %v", err)
+	}
+	for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffffffffffffffff\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatalf("Write returned an unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned an unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		path:        "ffffffffffffffff\n",
+		path + ".1": "eeee\n",
+		path + ".2": "cccc\ndddd\n",
+		path + ".3": "",
+	}
+	for file, content := range expected {
+		if got := readFileOrEmpty(file); got != content {
+			t.Errorf("Expected %s to contain %q, but got %q", filepath.Base(file), content, got)
+		}
+	}
+}
+
+func TestRotatingFileWriter_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	if err := os.WriteFile(path, []byte("previous\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write output: %v", err)
+	}
+
+	w, err := NewRotatingFileWriter(path, 0, 0, true)
+	if err != nil {
+		t.Fatalf("NewRotatingFileWriter returned an unexpected error: %v", err)
+	}
+	w.Write([]byte("next\n"))
+	w.Close()
+	if got := readFileOrEmpty(path); got != "previous\nnext\n" {
+		t.Errorf("Expected the output to be appended to, but got %q", got)
+	}
+
+	w, err = NewRotatingFileWriter(path, 0, 0, false)
+	if err != nil {
+		t.Fatalf("NewRotatingFileWriter returned an unexpected error: %v", err)
+	}
+	w.Close()
+	if got := readFileOrEmpty(path); got != "" {
+		t.Errorf("Expected the output to be truncated, but got %q", got)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// LeakKind describes why a value found by the leak scanner looks sensitive.
+type LeakKind string
+
+const (
+	LeakUnredactedValue   LeakKind = "unredacted value"
+	LeakUnhashedFieldName LeakKind = "unhashed field name"
+	LeakEmail             LeakKind = "email address"
+	LeakIP                LeakKind = "IP address"
+	LeakKnownSecret       LeakKind = "known secret"
+)
+
+// LeakFinding is a potentially sensitive value found in a log entry.
+type LeakFinding struct {
+	Line  int
+	Path  string
+	Kind  LeakKind
+	Value string
+}
+
+// String describes the finding, with its value masked unless SetScanShowValues is set, so that the
+// scan output doesn't repeat the sensitive values it finds.
+func (f LeakFinding) String() string {
+	path := f.Path
+	if path == "" {
+		path = "(line)"
+	}
+	value := f.Value
+	if !scanShowValues {
+		value = maskLeakValue(f.Kind, f.Value)
+	}
+	return fmt.Sprintf("line %d: %s: %s %q", f.Line, path, f.Kind, value)
+}
+
+// maskLeakValue keeps just enough of a value to find it again: the first character of each part
+// of an email address and its top-level domain, the first part of an IP address, and the first
+// character and length of other values. Known secrets are already reported by their position.
+func maskLeakValue(kind LeakKind, value string) string {
+	if kind == LeakKnownSecret || value == "(object)" || value == "(array)" {
+		return value
+	}
+	switch kind {
+	case LeakEmail:
+		local, domain, _ := strings.Cut(value, "@")
+		tld := ""
+		if i := strings.LastIndex(domain, "."); i >= 0 {
+			tld = domain[i:]
+		}
+		return maskPrefix(local) + "@" + maskPrefix(domain) + tld
+	case LeakIP:
+		if first, _, ok := strings.Cut(value, "."); ok {
+			return first + ".*.*.*"
+		}
+		first, _, _ := strings.Cut(value, ":")
+		return first + ":*"
+	}
+	return fmt.Sprintf("%s (%d characters)", maskPrefix(value), utf8.RuneCountInString(value))
+}
+
+func maskPrefix(s string) string {
+	for _, r := range s {
+		return string(r) + "***"
+	}
+	return "***"
+}
+
+var (
+	knownSecrets []string
+	// scanShowValues prints the values of findings as they are, instead of masked.
+	scanShowValues bool
+	// emailInTextRegex finds email addresses anywhere in a string, unlike emailRegex which matches
+	// whole values only.
+	emailInTextRegex = regexp.MustCompile(`[a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+`)
+	ipv4InTextRegex  = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}\b`)
+	hashedNameRegex  *regexp.Regexp
+	hashedNamePrefix string
+)
+
+func SetKnownSecrets(secrets []string) { knownSecrets = secrets }
+
+func SetScanShowValues(show bool) { scanShowValues = show }
+
+// ReadSecretsFile reads a list of known secrets, one per line. Blank lines and lines starting with
+// '#' are ignored.
+func ReadSecretsFile(filePath string) ([]string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets file: %w", err)
+	}
+	defer f.Close()
+	var secrets []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		secrets = append(secrets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	return secrets, nil
+}
+
+// ScanMongoLog looks for sensitive values that survived in a single log line. It redacts the entry
+// again with the current redaction options and reports every value and field name that would
+// still change, then runs the email, IP address and known secret detectors over the whole entry.
+// Lines that aren't valid JSON are checked with the detectors only.
+func ScanMongoLog(line string, lineNumber int) []LeakFinding {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	SetCurrentLineNumber(lineNumber)
+	var findings []LeakFinding
+	add := func(path string, kind LeakKind, value string) {
+		findings = append(findings, LeakFinding{Line: lineNumber, Path: path, Kind: kind, Value: value})
+	}
+
+	original, err := UnmarshalOrdered([]byte(line))
+	if err != nil {
+		detectLeaksInString("", line, add)
+		return findings
+	}
+	if redacted, err := redactEntry(line); err == nil {
+		reportSurvivingValues(original, redacted, add)
+	}
+	detectLeaksInValue("", original, add)
+	return findings
+}
+
+// reportSurvivingValues adds a finding for every change between original and redacted that
+// wasn't already made by an earlier redaction run. Null values dropped by redaction hold nothing.
+func reportSurvivingValues(original, redacted any, add func(string, LeakKind, string)) {
+	for _, change := range DiffValues(original, redacted) {
+		if change.Removed && change.Old == nil {
+			continue
+		}
+		if change.FieldName {
+			oldName, newName := change.Old.(string), change.New.(string)
+			if !isAlreadyRedacted(oldName, newName) {
+				add(change.Path, LeakUnhashedFieldName, oldName)
+			}
+			continue
+		}
+		switch change.Old.(type) {
+		case *orderedmap.OrderedMap[string, any]:
+			add(change.Path, LeakUnredactedValue, "(object)")
+		case []any:
+			add(change.Path, LeakUnredactedValue, "(array)")
+		default:
+			oldValue := fmt.Sprint(change.Old)
+			if !isAlreadyRedacted(oldValue, fmt.Sprint(change.New)) {
+				add(change.Path, LeakUnredactedValue, oldValue)
+			}
+		}
+	}
+}
+
+// isAlreadyRedacted reports whether original was redacted by an earlier run, given the result of
+// redacting it again: it's unchanged, it's a redaction placeholder, it's made of hashed names only
+// or only differs in names that were hashed again, or it was encrypted with the configured key.
+func isAlreadyRedacted(original, redacted string) bool {
+	if original == redacted || slices.Contains(redactionPlaceholders(), original) {
+		return true
+	}
+	hashed := hashedNamePattern()
+	if hashed.MatchString(original) {
+		unhashed := hashed.ReplaceAllString(original, "")
+		if strings.Trim(unhashed, "$.") == "" || unhashed == hashed.ReplaceAllString(redacted, "") {
+			return true
+		}
+	}
+	return isDecryptable(original)
+}
+
+func redactionPlaceholders() []string {
+	return []string{redactedString, RedactedISODate, RedactedObjectId, RedactedUUID, RedactedUUIDString, "redacted@redacted.com", "255.255.255.255:65535"}
+}
+
+// hashedNamePattern matches the names and values hashed by HashName and HashValue.
+func hashedNamePattern() *regexp.Regexp {
+	if hashedNameRegex == nil || hashedNamePrefix != redactedString {
+		hashedNamePrefix = redactedString
+		hashedNameRegex = regexp.MustCompile(regexp.QuoteMeta(redactedString) + `_[0-9a-f]{16}`)
+	}
+	return hashedNameRegex
+}
+
+func isDecryptable(s string) bool {
+	if encryptionKey == nil {
+		return false
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return false
+	}
+	_, err = Decrypt(data, encryptionKey)
+	return err == nil
+}
+
+func detectLeaksInValue(path string, v any, add func(string, LeakKind, string)) {
+	switch val := v.(type) {
+	case *orderedmap.OrderedMap[string, any]:
+		for el := val.Front(); el != nil; el = el.Next() {
+			elPath := joinDiffPath(path, el.Key)
+			detectKnownSecrets(elPath, el.Key, add)
+			detectLeaksInValue(elPath, el.Value, add)
+		}
+	case []any:
+		for i, item := range val {
+			detectLeaksInValue(joinDiffPath(path, fmt.Sprint(i)), item, add)
+		}
+	case string:
+		detectLeaksInString(path, val, add)
+	}
+}
+
+func detectLeaksInString(path, s string, add func(string, LeakKind, string)) {
+	detectKnownSecrets(path, s, add)
+	for _, email := range emailInTextRegex.FindAllString(s, -1) {
+		if email != "redacted@redacted.com" {
+			add(path, LeakEmail, email)
+		}
+	}
+	for _, candidate := range ipv4InTextRegex.FindAllString(s, -1) {
+		if isSensitiveIP(candidate) {
+			add(path, LeakIP, candidate)
+		}
+	}
+	host := s
+	if h, _, err := net.SplitHostPort(s); err == nil {
+		host = h
+	}
+	if strings.Contains(host, ":") && isSensitiveIP(host) {
+		add(path, LeakIP, host)
+	}
+}
+
+func isSensitiveIP(s string) bool {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return false
+	}
+	return !ip.IsLoopback() && !ip.IsUnspecified() && !ip.Equal(net.IPv4bcast)
+}
+
+func detectKnownSecrets(path, s string, add func(string, LeakKind, string)) {
+	lower := strings.ToLower(s)
+	for i, secret := range knownSecrets {
+		if strings.Contains(lower, strings.ToLower(secret)) {
+			add(path, LeakKnownSecret, fmt.Sprintf("#%d", i+1))
+		}
+	}
+}
+
+func scanMongoLogStream(s *logStream, w io.Writer) (int, error) {
+	total := 0
+	err := forEachLogEntry(s, func(line string, lineNumber int) {
+		for _, finding := range ScanMongoLog(line, lineNumber) {
+			fmt.Fprintln(w, finding.String())
+			total++
+		}
+	})
+	SetCurrentLineNumber(0)
+	return total, err
+}
+
+// ScanMongoLogFile scans a (redacted or raw) MongoDB log file for sensitive values, writes one
+// line per finding to w, and returns the number of findings.
+func ScanMongoLogFile(fileReader FileReader, filePath string, w io.Writer) (int, error) {
+	s, err := openLogFileInput(fileReader, filePath, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	return scanMongoLogStream(s, w)
+}
+
+// ScanMongoLogFromReader scans MongoDB log lines from any io.Reader (such as stdin).
+func ScanMongoLogFromReader(r io.Reader, w io.Writer) (int, error) {
+	s, err := openLogInput(r)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Close()
+	return scanMongoLogStream(s, w)
+}
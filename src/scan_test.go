@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanMongoLog_FindsUnredactedValues(t *testing.T) {
+	setOptionsRedactedStrings()
+	SetKnownSecrets(nil)
+
+	findings := ScanMongoLog(getFixtureContent(t, "test_fixtures/find_with_emails.json"), 7)
+
+	expected := map[string]bool{
+		"unredacted value attr.command.filter.$or.0.username": false,
+		"email address attr.command.filter.$or.1.username":    false,
+		"IP address attr.remote":                              false,
+	}
+	for _, f := range findings {
+		if f.Line != 7 {
+			t.Errorf("Expected finding on line 7, but got line %d", f.Line)
+		}
+		key := string(f.Kind) + " " + f.Path
+		if _, ok := expected[key]; ok {
+			expected[key] = true
+		}
+	}
+	for key, found := range expected {
+		if !found {
+			t.Errorf("Expected finding %q, but got %v", key, findings)
+		}
+	}
+}
+
+func TestScanMongoLog_KnownSecrets(t *testing.T) {
+	setOptionsRedactedStrings()
+	SetKnownSecrets([]string{"does-not-appear", "MY_APP"})
+	defer SetKnownSecrets(nil)
+
+	findings := ScanMongoLog(`{"c":"NETWORK","msg":"hello","attr":{"appName":"my_app","my_app_key":1}}`, 1)
+
+	var got []string
+	for _, f := range findings {
+		if f.Kind == LeakKnownSecret {
+			got = append(got, f.Path+" "+f.Value)
+		}
+	}
+	expected := []string{"attr.appName #2", "attr.my_app_key #2"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected known secret findings %v, but got %v", expected, got)
+	}
+}
+
+func TestScanMongoLog_UnparseableLine(t *testing.T) {
+	setOptionsRedactedStrings()
+	SetKnownSecrets(nil)
+
+	findings := ScanMongoLog("connection from 10.1.2.3 by jane@example.com", 3)
+
+	if len(findings) != 2 {
+		t.Fatalf("Expected 2 findings, but got %v", findings)
+	}
+	if findings[0].Kind != LeakEmail || findings[0].Value != "jane@example.com" {
+		t.Errorf("Expected an email finding, but got %v", findings[0])
+	}
+	if findings[1].Kind != LeakIP || findings[1].Value != "10.1.2.3" {
+		t.Errorf("Expected an IP address finding, but got %v", findings[1])
+	}
+}
+
+func TestScanMongoLog_IgnoresPlaceholdersAndLoopback(t *testing.T) {
+	setOptionsRedactedStrings()
+	SetKnownSecrets(nil)
+
+	line := `{"c":"NETWORK","msg":"x","attr":{"remote":"255.255.255.255:65535","local":"127.0.0.1:27017","user":"redacted@redacted.com","host":"[::1]:27017"}}`
+	if findings := ScanMongoLog(line, 1); len(findings) != 0 {
+		t.Errorf("Expected no findings, but got %v", findings)
+	}
+}
+
+func TestScanMongoLog_NullValuedField(t *testing.T) {
+	setOptionsRedactedStringsWithEagerRedaction()
+	defer setOptionsRedactedStrings()
+	SetKnownSecrets(nil)
+
+	line := `{"c":"COMMAND","msg":"Slow query","attr":{"ns":"my_db.my_coll","command":{"find":"my_coll","filter":{"` +
+		hashedName("deletedAt") + `":null,"` + hashedName("email") + `":"REDACTED","` + hashedName("name") + `":"bob"}}}}`
+
+	var got []string
+	for _, f := range ScanMongoLog(line, 1) {
+		got = append(got, string(f.Kind)+" "+f.Path)
+	}
+	expected := []string{"unredacted value attr.command.filter." + hashedName("name")}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected findings %v, but got %v", expected, got)
+	}
+}
+
+// TestScanMongoLog_NoFindingsAfterRedaction checks that scanning the output of 'redact' with the
+// same options finds nothing, for every fixture.
+func TestScanMongoLog_NoFindingsAfterRedaction(t *testing.T) {
+	SetKnownSecrets(nil)
+	fixtures, err := filepath.Glob(filepath.Join("..", "test_fixtures", "*.json"))
+	if err != nil {
+		t.Fatalf("Failed to list fixtures: %v", err)
+	}
+	options := map[string]func(){
+		"strings":              setOptionsRedactedStrings,
+		"eager":                setOptionsRedactedStringsWithEagerRedaction,
+		"namespaces":           setOptionsRedactedStringsAndNamespaces,
+		"all with replacement": setOptionsRedactedAllWithOverride,
+	}
+	defer setOptionsRedactedStrings()
+	for name, setOptions := range options {
+		for _, fixture := range fixtures {
+			fixturePath := "test_fixtures/" + filepath.Base(fixture)
+			if strings.HasPrefix(filepath.Base(fixture), "cluster-info") || strings.HasPrefix(filepath.Base(fixture), "processes-response") {
+				continue
+			}
+			t.Run(name+"/"+filepath.Base(fixture), func(t *testing.T) {
+				setOptions()
+				SetRedactIPs(true)
+				SetEntryFormat(fixtureEntryFormat(filepath.Base(fixture)))
+				defer SetEntryFormat(EntryFormatLog)
+				var redacted bytes.Buffer
+				if err := ProcessMongoLogFileFromReader(strings.NewReader(getFixtureContent(t, fixturePath)), &redacted, nil); err != nil {
+					t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+				}
+				var out bytes.Buffer
+				n, err := ScanMongoLogFromReader(&redacted, &out)
+				if err != nil {
+					t.Fatalf("ScanMongoLogFromReader returned an unexpected error: %v", err)
+				}
+				if n != 0 {
+					t.Errorf("Expected no findings in redacted output, but got %d:\n%s", n, out.String())
+				}
+			})
+		}
+	}
+}
+
+func TestScanMongoLog_EncryptedValues(t *testing.T) {
+	setOptionsRedactedStrings()
+	SetRedactIPs(true)
+	defer SetRedactIPs(false)
+	SetKnownSecrets(nil)
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey returned an unexpected error: %v", err)
+	}
+	SetEncryptionKey(key)
+	SetShouldEncrypt(true)
+	var redacted bytes.Buffer
+	err = ProcessMongoLogFileFromReader(strings.NewReader(getFixtureContent(t, "test_fixtures/simple_find.json")), &redacted, nil)
+	SetShouldEncrypt(false)
+	if err != nil {
+		t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+	}
+	line := strings.TrimSpace(redacted.String())
+
+	if findings := ScanMongoLog(line, 1); len(findings) != 0 {
+		t.Errorf("Expected no findings with the encryption key, but got %v", findings)
+	}
+	SetEncryptionKey(nil)
+	if findings := ScanMongoLog(line, 1); len(findings) == 0 {
+		t.Errorf("Expected findings without the encryption key, but got none")
+	}
+}
+
+func TestReadSecretsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.txt")
+	if err := os.WriteFile(path, []byte("# customer names\nAcme Corp\n\n  hunter2  \n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secrets file: %v", err)
+	}
+	secrets, err := ReadSecretsFile(path)
+	if err != nil {
+		t.Fatalf("ReadSecretsFile returned an unexpected error: %v", err)
+	}
+	if strings.Join(secrets, ",") != "Acme Corp,hunter2" {
+		t.Errorf("Expected secrets [Acme Corp hunter2], but got %v", secrets)
+	}
+	if _, err := ReadSecretsFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Errorf("Expected an error for a missing secrets file")
+	}
+}
+
+func TestLeakFinding_String(t *testing.T) {
+	tests := []struct {
+		finding  LeakFinding
+		expected string
+	}{
+		{LeakFinding{Line: 1, Path: "attr.user", Kind: LeakEmail, Value: "jane.doe@example.com"}, `line 1: attr.user: email address "j***@e***.com"`},
+		{LeakFinding{Line: 2, Path: "attr.remote", Kind: LeakIP, Value: "20.40.131.128"}, `line 2: attr.remote: IP address "20.*.*.*"`},
+		{LeakFinding{Line: 3, Path: "attr.remote", Kind: LeakIP, Value: "2001:db8::1"}, `line 3: attr.remote: IP address "2001:*"`},
+		{LeakFinding{Line: 4, Path: "attr.filter.name", Kind: LeakUnredactedValue, Value: "jdoe"}, `line 4: attr.filter.name: unredacted value "j*** (4 characters)"`},
+		{LeakFinding{Line: 5, Path: "attr.filter", Kind: LeakUnredactedValue, Value: "(object)"}, `line 5: attr.filter: unredacted value "(object)"`},
+		{LeakFinding{Line: 6, Path: "attr.appName", Kind: LeakKnownSecret, Value: "#1"}, `line 6: attr.appName: known secret "#1"`},
+		{LeakFinding{Line: 7, Kind: LeakUnhashedFieldName, Value: "élan"}, `line 7: (line): unhashed field name "é*** (4 characters)"`},
+	}
+	for _, tt := range tests {
+		if got := tt.finding.String(); got != tt.expected {
+			t.Errorf("String() = %s, want %s", got, tt.expected)
+		}
+	}
+
+	SetScanShowValues(true)
+	defer SetScanShowValues(false)
+	finding := LeakFinding{Line: 1, Path: "attr.user", Kind: LeakEmail, Value: "jane.doe@example.com"}
+	if got, want := finding.String(), `line 1: attr.user: email address "jane.doe@example.com"`; got != want {
+		t.Errorf("String() with values shown = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+)
+
+// AtlasLogStreams is a FileReader streaming the logs listed by ListClusterLogs from the Atlas API,
+// so that they're redacted as they're downloaded, and never written to disk unredacted. The logs
+// are read one at a time, but up to the concurrency of the client are requested at once (see Open).
+type AtlasLogStreams struct {
+	ctx       context.Context
+	client    *AtlasClient
+	http      *http.Client
+	projectID string
+	startDate int
+	endDate   int
+	logs      map[string]AtlasLogFile
+	// paths are the logs in the order they're expected to be opened.
+	paths []string
+
+	mu sync.Mutex
+	// pending are the logs requested ahead of being opened, and requested the logs requested so far.
+	pending   map[string]chan openedLog
+	requested map[string]bool
+}
+
+// openedLog is the result of requesting a log.
+type openedLog struct {
+	body io.ReadCloser
+	err  error
+}
+
+// NewLogStreams returns a FileReader streaming the logs, by their Path, over the range from
+// startDate to endDate. They're expected to be opened in order. ctx cancels the streams opened.
+func (c *AtlasClient) NewLogStreams(ctx context.Context, publicKey, privateKey, projectID string, logFiles []AtlasLogFile, startDate, endDate int) *AtlasLogStreams {
+	logs := make(map[string]AtlasLogFile, len(logFiles))
+	var paths []string
+	for _, logFile := range logFiles {
+		logs[logFile.Path] = logFile
+		paths = append(paths, logFile.Path)
+	}
+	return &AtlasLogStreams{
+		ctx:       ctx,
+		client:    c,
+		http:      c.authenticatedClient(publicKey, privateKey),
+		projectID: projectID,
+		startDate: startDate,
+		endDate:   endDate,
+		logs:      logs,
+		paths:     paths,
+		pending:   map[string]chan openedLog{},
+		requested: map[string]bool{},
+	}
+}
+
+// Open requests the log, and returns its content once the response starts. The logs after it are
+// requested at the same time, up to the concurrency of the client, so that Atlas prepares them
+// while the log is read; their responses are only read once they're opened. A range that fits in
+// the chunk size of the client is returned gzipped, as served by Atlas. A longer range is streamed
+// in chunks, one after the other, which are decompressed to be concatenated without the lines they
+// share (see chunkMerger), so it's returned decompressed.
+func (s *AtlasLogStreams) Open(path string) (io.ReadCloser, error) {
+	if _, ok := s.logs[path]; !ok {
+		return nil, fmt.Errorf("no Atlas log %s", path)
+	}
+	s.mu.Lock()
+	result, ok := s.pending[path]
+	if !ok {
+		result = s.request(path)
+	}
+	delete(s.pending, path)
+	concurrency := s.client.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultDownloadConcurrency
+	}
+	next := slices.Index(s.paths, path) + 1
+	for _, ahead := range s.paths[next:min(next+concurrency-1, len(s.paths))] {
+		if !s.requested[ahead] {
+			s.pending[ahead] = s.request(ahead)
+		}
+	}
+	s.mu.Unlock()
+	opened := <-result
+	return opened.body, opened.err
+}
+
+// Close closes the logs requested ahead of being opened, which won't be anymore.
+func (s *AtlasLogStreams) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path, result := range s.pending {
+		if opened := <-result; opened.err == nil {
+			opened.body.Close()
+		}
+		delete(s.pending, path)
+	}
+	return nil
+}
+
+// request requests a log in the background. s.mu must be held.
+func (s *AtlasLogStreams) request(path string) chan openedLog {
+	s.requested[path] = true
+	result := make(chan openedLog, 1)
+	go func() {
+		body, err := s.open(s.logs[path])
+		result <- openedLog{body, err}
+	}()
+	return result
+}
+
+func (s *AtlasLogStreams) open(logFile AtlasLogFile) (io.ReadCloser, error) {
+	fmt.Fprintf(os.Stdout, "Streaming %s logs for host %s...\n", logFile.LogType, logFile.Host)
+	chunks := logChunks(s.startDate, s.endDate, s.client.chunkSize())
+	first, err := s.client.streamLog(s.ctx, s.http, s.client.logURL(s.projectID, logFile.Host, logFile.LogType, chunks[0]), atlasLogHeader())
+	if err != nil || len(chunks) == 1 {
+		return first, err
+	}
+
+	// The first chunk is requested before returning, so that a log that can't be downloaded is
+	// reported before anything is read from it.
+	pr, pw := io.Pipe()
+	go func() {
+		merger := newChunkMerger(pw)
+		err := merger.add(first)
+		first.Close()
+		for _, chunk := range chunks[1:] {
+			if err != nil {
+				break
+			}
+			err = s.client.downloadChunk(s.ctx, s.http, s.client.logURL(s.projectID, logFile.Host, logFile.LogType, chunk), atlasLogHeader(), merger)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// GetExtension implements FileReader. Streamed logs have no extension; their compression is
+// detected from their content.
+func (s *AtlasLogStreams) GetExtension(path string) string {
+	return ""
+}
+
+// streamLog requests url, retrying like downloadToFile until the response starts, and returns its
+// body. Reading a body interrupted midway resumes it with a range request, within the same
+// retries; a log that can't be resumed, because the server doesn't honor the range, fails, since
+// the part already read may have been redacted.
+func (c *AtlasClient) streamLog(ctx context.Context, client *http.Client, url string, header http.Header) (io.ReadCloser, error) {
+	b := &resumableBody{ctx: ctx, client: client, atlas: c, url: url, header: header}
+	err := c.retry(ctx, url, &b.retries, func() error {
+		resp, err := c.requestLog(ctx, client, url, header, 0)
+		if err != nil {
+			return err
+		}
+		b.body = resp.Body
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// resumableBody is the body of a log download, resumed from the last byte read when it's
+// interrupted.
+type resumableBody struct {
+	ctx     context.Context
+	client  *http.Client
+	atlas   *AtlasClient
+	url     string
+	header  http.Header
+	body    io.ReadCloser
+	read    int64
+	retries int
+	// err is the failure to resume the body, returned by further reads.
+	err error
+}
+
+func (b *resumableBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+	if err == nil || errors.Is(err, io.EOF) || b.ctx.Err() != nil {
+		return n, err
+	}
+	b.body.Close()
+	interrupted := fmt.Errorf("download interrupted after %d bytes: %w", b.read, err)
+	// The interruption counts as a failed attempt, retried after a delay.
+	failed := true
+	resumeErr := b.atlas.retry(b.ctx, b.url, &b.retries, func() error {
+		if failed {
+			failed = false
+			return interrupted
+		}
+		resp, err := b.atlas.requestLog(b.ctx, b.client, b.url, b.header, b.read)
+		if err != nil {
+			return err
+		}
+		// A body interrupted before its first byte can start over.
+		if resp.StatusCode != http.StatusPartialContent && b.read > 0 {
+			resp.Body.Close()
+			return &permanentDownloadError{fmt.Errorf("%w, and the server can't resume it", interrupted)}
+		}
+		b.body = resp.Body
+		return nil
+	})
+	if resumeErr != nil {
+		b.err, b.body = resumeErr, http.NoBody
+		return n, resumeErr
+	}
+	return n, nil
+}
+
+func (b *resumableBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// streamWithClient streams the root of the server, and returns what was read.
+func streamWithClient(t *testing.T, client *AtlasClient) (string, error) {
+	t.Helper()
+	body, err := client.streamLog(context.Background(), client.HTTPClient, client.BaseURL+"/log.gz", http.Header{})
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	return string(content), err
+}
+
+func TestStreamLog_Retries(t *testing.T) {
+	const log = "0123456789abcdefghij"
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(log))
+		}
+	}))
+	defer server.Close()
+
+	content, err := streamWithClient(t, newTestDownloadClient(server))
+	if err != nil || content != log {
+		t.Errorf("streamLog() = %q, %v, want %q", content, err, log)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if _, err := streamWithClient(t, newTestDownloadClient(missing)); err == nil || !strings.Contains(err.Error(), "unexpected status 404") {
+		t.Errorf("Expected a missing log to fail, got %v", err)
+	}
+}
+
+func TestStreamLog_Resume(t *testing.T) {
+	const log = "0123456789abcdefghij"
+
+	t.Run("range honored", func(t *testing.T) {
+		var gotRange string
+		server := httptest.NewServer(interruptedHandler(t, log, func(w http.ResponseWriter, r *http.Request) {
+			gotRange = r.Header.Get("Range")
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", len(log)/2, len(log)-1, len(log)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte(log[len(log)/2:]))
+		}))
+		defer server.Close()
+
+		content, err := streamWithClient(t, newTestDownloadClient(server))
+		if err != nil || content != log {
+			t.Errorf("streamLog() = %q, %v, want %q", content, err, log)
+		}
+		if want := fmt.Sprintf("bytes=%d-", len(log)/2); gotRange != want {
+			t.Errorf("Expected the stream to resume with Range %q, got %q", want, gotRange)
+		}
+	})
+
+	t.Run("range ignored", func(t *testing.T) {
+		server := httptest.NewServer(interruptedHandler(t, log, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(log))
+		}))
+		defer server.Close()
+
+		// What was read can't be read again, so the stream fails rather than starting over.
+		content, err := streamWithClient(t, newTestDownloadClient(server))
+		if err == nil || !strings.Contains(err.Error(), "the server can't resume it") {
+			t.Errorf("streamLog() error = %v, want the stream to fail", err)
+		}
+		if content != log[:len(log)/2] {
+			t.Errorf("streamLog() = %q, want the first half of the log", content)
+		}
+	})
+
+	t.Run("interrupted before the first byte", func(t *testing.T) {
+		// A log requested ahead of being read may be interrupted before anything is read from it.
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) > 1 {
+				w.Write([]byte(log))
+				return
+			}
+			w.Header().Set("Content-Length", fmt.Sprint(len(log)))
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				conn.Close()
+			}
+		}))
+		defer server.Close()
+
+		content, err := streamWithClient(t, newTestDownloadClient(server))
+		if err != nil || content != log {
+			t.Errorf("streamLog() = %q, %v, want %q", content, err, log)
+		}
+	})
+}
+
+func TestAtlasLogStreams(t *testing.T) {
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(36 * time.Hour)
+	var lines []string
+	for at := start; !at.After(end); at = at.Add(time.Hour) {
+		lines = append(lines, logLineAt(at, "line at "+at.Format(time.Kitchen)))
+	}
+	// The requests of host1 are counted, those of host2, requested ahead of being opened, aren't.
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/atlas/v2/groups/project1/clusters/host1/logs/mongodb.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		from, _ := strconv.Atoi(r.URL.Query().Get("startDate"))
+		to, _ := strconv.Atoi(r.URL.Query().Get("endDate"))
+		// The lines at the boundaries of the range are included.
+		var served []string
+		for i, line := range lines {
+			if at := int(start.Add(time.Duration(i) * time.Hour).Unix()); at >= from && at <= to {
+				served = append(served, line)
+			}
+		}
+		w.Write(gzipLines(t, served...))
+	}))
+	defer server.Close()
+
+	logFiles := []AtlasLogFile{
+		{Cluster: "cluster1", Host: "host1", LogType: AtlasLogMongod, Path: "host1.mongodb"},
+		{Cluster: "cluster1", Host: "host2", LogType: AtlasLogMongod, Path: "host2.mongodb"},
+	}
+	tests := []struct {
+		name         string
+		chunkSize    time.Duration
+		wantRequests int32
+	}{
+		{name: "one range", chunkSize: 48 * time.Hour, wantRequests: 1},
+		{name: "chunks", chunkSize: 12 * time.Hour, wantRequests: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			client := newTestDownloadClient(server)
+			client.ChunkSize = tt.chunkSize
+			streams := client.NewLogStreams(context.Background(), "pubKey", "privKey", "project1", logFiles, int(start.Unix()), int(end.Unix()))
+
+			input, err := openLogFileInput(streams, "host1.mongodb", nil)
+			if err != nil {
+				t.Fatalf("Failed to open the stream: %v", err)
+			}
+			var got []string
+			err = forEachLogEntry(input, func(line string, lineNumber int) {
+				got = append(got, line)
+			})
+			input.Close()
+			if err != nil {
+				t.Fatalf("Failed to read the stream: %v", err)
+			}
+			if !reflect.DeepEqual(got, lines) {
+				t.Errorf("Unexpected streamed log:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(lines, "\n"))
+			}
+			if n := requests.Load(); n != tt.wantRequests {
+				t.Errorf("Expected %d requests, got %d", tt.wantRequests, n)
+			}
+
+			// A log that can't be downloaded fails when it's opened.
+			if _, err := streams.Open("host2.mongodb"); err == nil || !strings.Contains(err.Error(), "unexpected status 404") {
+				t.Errorf("Expected the log of host2 to fail, got %v", err)
+			}
+		})
+	}
+}
+
+// TestAtlasLogStreams_Concurrency checks that the logs after the one opened are requested at the
+// same time, up to the concurrency of the client, and only once.
+func TestAtlasLogStreams_Concurrency(t *testing.T) {
+	var mu sync.Mutex
+	requested := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.Split(r.URL.Path, "/")[7]
+		mu.Lock()
+		requested[host]++
+		mu.Unlock()
+		w.Write(gzipLines(t, logLineAt(time.Unix(0, 0), host)))
+	}))
+	defer server.Close()
+	var logFiles []AtlasLogFile
+	for _, host := range []string{"host1", "host2", "host3", "host4"} {
+		logFiles = append(logFiles, AtlasLogFile{Host: host, LogType: AtlasLogMongod, Path: host + ".mongodb"})
+	}
+	requestedHosts := func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return maps.Clone(requested)
+	}
+	// waitForRequests waits until the hosts are requested, and checks that the others aren't.
+	waitForRequests := func(expected map[string]int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !reflect.DeepEqual(requestedHosts(), expected) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got := requestedHosts(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Requested hosts = %v, want %v", got, expected)
+		}
+	}
+
+	client := newTestDownloadClient(server)
+	client.Concurrency = 2
+	streams := client.NewLogStreams(context.Background(), "pubKey", "privKey", "project1", logFiles, 0, 60)
+	defer streams.Close()
+	for i, logFile := range logFiles {
+		body, err := streams.Open(logFile.Path)
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", logFile.Path, err)
+		}
+		expected := map[string]int{}
+		for _, requestedFile := range logFiles[:min(i+2, len(logFiles))] {
+			expected[requestedFile.Host] = 1
+		}
+		waitForRequests(expected)
+		content, err := io.ReadAll(body)
+		body.Close()
+		if err != nil || !strings.Contains(gunzipBytes(t, content), logFile.Host) {
+			t.Errorf("%s = %q, %v, want its log", logFile.Path, content, err)
+		}
+	}
+}
+
+func TestListClusterLogs(t *testing.T) {
+	server := newProcessesServer(t)
+	defer server.Close()
+	client := NewAtlasClient(server.Client())
+	client.BaseURL = server.URL
+
+	logFiles, err := client.ListClusterLogs(context.Background(), "pubKey", "privKey", "project1", []string{"othercluster"}, HostFilter{}, []AtlasLogType{AtlasLogMongod, AtlasLogMongodAudit})
+	if err != nil {
+		t.Fatalf("ListClusterLogs returned an unexpected error: %v", err)
+	}
+	expected := []AtlasLogFile{
+		{Cluster: "othercluster", Host: "othercluster-shard-00-00.abcde.mongodb.net", Role: HostRolePrimary, LogType: AtlasLogMongod, Path: "othercluster-shard-00-00.abcde.mongodb.net.mongodb"},
+		{Cluster: "othercluster", Host: "othercluster-shard-00-00.abcde.mongodb.net", Role: HostRolePrimary, LogType: AtlasLogMongodAudit, Path: "othercluster-shard-00-00.abcde.mongodb.net.mongodb-audit-log"},
+	}
+	if !reflect.DeepEqual(logFiles, expected) {
+		t.Errorf("ListClusterLogs() = %+v, want %+v", logFiles, expected)
+	}
+}
+
+func TestAtlasLogStreams_Redaction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(gzipLines(t, `{"t":{"$date":"2025-06-01T00:00:00.000Z"},"s":"I","c":"COMMAND","msg":"Slow query","attr":{"command":{"find":"users","filter":{"name":"alice"}}}}`))
+	}))
+	defer server.Close()
+	client := newTestDownloadClient(server)
+	logFiles := []AtlasLogFile{{Host: "host1", LogType: AtlasLogMongod, Path: "host1.mongodb"}}
+	streams := client.NewLogStreams(context.Background(), "pubKey", "privKey", "project1", logFiles, 0, 1)
+
+	var out bytes.Buffer
+	if err := ProcessMongoLogFile(streams, "host1.mongodb", &out, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFile returned an unexpected error: %v", err)
+	}
+	if strings.Contains(out.String(), "alice") || !strings.Contains(out.String(), "Slow query") {
+		t.Errorf("Expected the streamed log to be redacted, got %s", out.String())
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeFlagLayouts are the layouts accepted by --from and --to. Times without a time zone are UTC.
+var timeFlagLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseSince parses the duration of --since: a Go duration (e.g., '36h' or '90m'), or a number of
+// days (e.g., '7d').
+func ParseSince(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(value)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q (expected a positive duration, e.g., '36h' or '7d')", value)
+	}
+	return d, nil
+}
+
+// ParseTimeFlag parses the time of --from or --to, e.g., '2025-06-01T00:00Z' or '2025-06-01'.
+func ParseTimeFlag(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeFlagLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (expected, e.g., '2025-06-01T00:00Z' or '2025-06-01')", value)
+}
+
+// ResolveTimeRange returns the range of logs to download, in epoch seconds, given by --since, or by
+// --from and --to. since covers the duration up to now; a missing end is now, and a missing start
+// is left to GetStartAndEndDates (0). Both are 0 if no flag is set.
+func ResolveTimeRange(since, from, to string, now time.Time) (int, int, error) {
+	if since != "" {
+		if from != "" || to != "" {
+			return 0, 0, fmt.Errorf("--since cannot be used with --from or --to")
+		}
+		d, err := ParseSince(since)
+		if err != nil {
+			return 0, 0, err
+		}
+		return int(now.Add(-d).Unix()), int(now.Unix()), nil
+	}
+	var start, end int
+	if from != "" {
+		t, err := ParseTimeFlag(from)
+		if err != nil {
+			return 0, 0, err
+		}
+		start, end = int(t.Unix()), int(now.Unix())
+	}
+	if to != "" {
+		t, err := ParseTimeFlag(to)
+		if err != nil {
+			return 0, 0, err
+		}
+		end = int(t.Unix())
+	}
+	if start != 0 && start >= end {
+		return 0, 0, fmt.Errorf("the start of the time range must be before its end")
+	}
+	return start, end, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{value: "36h", expected: 36 * time.Hour},
+		{value: "90m", expected: 90 * time.Minute},
+		{value: "1h30m", expected: 90 * time.Minute},
+		{value: "3d", expected: 72 * time.Hour},
+		{value: " 7d ", expected: 7 * 24 * time.Hour},
+		{value: "0h", wantErr: true},
+		{value: "-2h", wantErr: true},
+		{value: "d", wantErr: true},
+		{value: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := ParseSince(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSince(%q) = %v, want an error", tt.value, d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSince(%q) returned an unexpected error: %v", tt.value, err)
+			}
+			if d != tt.expected {
+				t.Errorf("ParseSince(%q) = %v, want %v", tt.value, d, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseTimeFlag(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{value: "2025-06-01T00:00Z", expected: "2025-06-01T00:00:00Z"},
+		{value: "2025-06-01T12:30+02:00", expected: "2025-06-01T10:30:00Z"},
+		{value: "2025-06-01T12:30:15.5Z", expected: "2025-06-01T12:30:15.5Z"},
+		{value: "2025-06-01T12:30:15", expected: "2025-06-01T12:30:15Z"},
+		{value: "2025-06-01T12:30", expected: "2025-06-01T12:30:00Z"},
+		{value: "2025-06-01", expected: "2025-06-01T00:00:00Z"},
+		{value: "06/01/2025", wantErr: true},
+		{value: "1748736000", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			parsed, err := ParseTimeFlag(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseTimeFlag(%q) = %v, want an error", tt.value, parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTimeFlag(%q) returned an unexpected error: %v", tt.value, err)
+			}
+			if got := parsed.UTC().Format(time.RFC3339Nano); got != tt.expected {
+				t.Errorf("ParseTimeFlag(%q) = %s, want %s", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveTimeRange(t *testing.T) {
+	now := time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC)
+	june1 := int(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC).Unix())
+	june2 := int(time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC).Unix())
+
+	tests := []struct {
+		name          string
+		since         string
+		from          string
+		to            string
+		expectedStart int
+		expectedEnd   int
+		wantErr       string
+	}{
+		{name: "no flag"},
+		{name: "since", since: "36h", expectedStart: int(now.Add(-36 * time.Hour).Unix()), expectedEnd: int(now.Unix())},
+		{name: "from", from: "2025-06-01T00:00Z", expectedStart: june1, expectedEnd: int(now.Unix())},
+		{name: "from and to", from: "2025-06-01", to: "2025-06-02", expectedStart: june1, expectedEnd: june2},
+		{name: "to", to: "2025-06-02", expectedEnd: june2},
+		{name: "since and from", since: "36h", from: "2025-06-01", wantErr: "--since cannot be used with --from or --to"},
+		{name: "from after to", from: "2025-06-02", to: "2025-06-01", wantErr: "must be before its end"},
+		{name: "invalid since", since: "soon", wantErr: "invalid duration"},
+		{name: "invalid to", to: "tomorrow", wantErr: "invalid time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := ResolveTimeRange(tt.since, tt.from, tt.to, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ResolveTimeRange() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveTimeRange() returned an unexpected error: %v", err)
+			}
+			if start != tt.expectedStart || end != tt.expectedEnd {
+				t.Errorf("ResolveTimeRange() = (%d, %d), want (%d, %d)", start, end, tt.expectedStart, tt.expectedEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// maxUnknownOperatorSampleLines caps the number of line numbers kept per unknown operator.
+const maxUnknownOperatorSampleLines = 5
+
+// UnknownOperator summarizes the occurrences of a '$'-prefixed key missing from the operator catalog.
+type UnknownOperator struct {
+	Operator    string `json:"operator"`
+	Count       int    `json:"count"`
+	SampleLines []int  `json:"sampleLines"`
+}
+
+var (
+	strictOperators   = false
+	currentLineNumber = 0
+	unknownOperators  = map[string]*UnknownOperator{}
+)
+
+func SetStrictOperators(b bool)     { strictOperators = b }
+func SetCurrentLineNumber(line int) { currentLineNumber = line }
+
+// ResetUnknownOperators forgets every unknown operator recorded so far.
+func ResetUnknownOperators() { unknownOperators = map[string]*UnknownOperator{} }
+
+func isUnknownOperatorKey(key string) bool {
+	return strings.HasPrefix(key, "$") && !strings.HasPrefix(key, "$$")
+}
+
+// isUnknownOperator reports whether key looks like an operator but isn't in the operator catalog.
+// Callers check the catalog for the key's context first; this is the fallback for operators used
+// outside of it, such as query operators inside a search stage's filter.
+func isUnknownOperator(key string) bool {
+	if !isUnknownOperatorKey(key) {
+		return false
+	}
+	if _, known := CoreOperators.Get(key); known {
+		return false
+	}
+	_, known := SearchAggregationOperators.Get(key)
+	return !known
+}
+
+func recordUnknownOperator(op string) {
+	stats, ok := unknownOperators[op]
+	if !ok {
+		stats = &UnknownOperator{Operator: op}
+		unknownOperators[op] = stats
+	}
+	stats.Count++
+	reportUnknownOperator()
+	if currentLineNumber > 0 && len(stats.SampleLines) < maxUnknownOperatorSampleLines {
+		if n := len(stats.SampleLines); n == 0 || stats.SampleLines[n-1] != currentLineNumber {
+			stats.SampleLines = append(stats.SampleLines, currentLineNumber)
+		}
+	}
+}
+
+// UnknownOperators returns the unknown operators recorded so far, most frequent first.
+func UnknownOperators() []UnknownOperator {
+	ops := make([]UnknownOperator, 0, len(unknownOperators))
+	for _, stats := range unknownOperators {
+		ops = append(ops, *stats)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Count != ops[j].Count {
+			return ops[i].Count > ops[j].Count
+		}
+		return ops[i].Operator < ops[j].Operator
+	})
+	return ops
+}
+
+// PrintUnknownOperatorsSummary writes a warning listing the unknown operators recorded so far, if any.
+func PrintUnknownOperatorsSummary(w io.Writer) {
+	ops := UnknownOperators()
+	if len(ops) == 0 {
+		return
+	}
+	if strictOperators {
+		fmt.Fprintf(w, "Warning: found %d unknown operator(s); their entire values were redacted:\n", len(ops))
+	} else {
+		fmt.Fprintf(w, "Warning: found %d unknown operator(s); they were redacted like field names, which may misrepresent\n", len(ops))
+		fmt.Fprintln(w, "or leak their arguments. Use --strictOperators to redact their entire values, or --operatorCatalog to define them:")
+	}
+	for _, op := range ops {
+		lines := make([]string, len(op.SampleLines))
+		for i, line := range op.SampleLines {
+			lines[i] = fmt.Sprint(line)
+		}
+		fmt.Fprintf(w, "  %s: %d occurrence(s)", op.Operator, op.Count)
+		if len(lines) > 0 {
+			fmt.Fprintf(w, " (lines %s", strings.Join(lines, ", "))
+			if len(lines) == maxUnknownOperatorSampleLines {
+				fmt.Fprint(w, ", ...")
+			}
+			fmt.Fprint(w, ")")
+		}
+		fmt.Fprintln(w)
+	}
+}
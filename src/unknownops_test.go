@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnknownOperators_RecordedWithLineNumbers(t *testing.T) {
+	setOptionsRedactedStrings()
+	ResetUnknownOperators()
+	defer ResetUnknownOperators()
+
+	knownLine := getFixtureContent(t, "test_fixtures/simple_find.json")
+	unknownLine := getFixtureContent(t, "test_fixtures/unknown_operators.json")
+	input := strings.Join([]string{knownLine, unknownLine, knownLine, unknownLine}, "\n")
+
+	if err := ProcessMongoLogFileFromReader(strings.NewReader(input), io.Discard, nil); err != nil {
+		t.Fatalf("ProcessMongoLogFileFromReader returned an unexpected error: %v", err)
+	}
+
+	expected := []UnknownOperator{
+		{Operator: "$fuzzyEq", Count: 2, SampleLines: []int{2, 4}},
+		{Operator: "$newStageFromServer9", Count: 2, SampleLines: []int{2, 4}},
+	}
+	if got := UnknownOperators(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected unknown operators %+v, but got %+v", expected, got)
+	}
+}
+
+func TestUnknownOperators_SampleLinesAreCapped(t *testing.T) {
+	ResetUnknownOperators()
+	defer ResetUnknownOperators()
+	defer SetCurrentLineNumber(0)
+
+	for line := 1; line <= 8; line++ {
+		SetCurrentLineNumber(line)
+		recordUnknownOperator("$newOp")
+		recordUnknownOperator("$newOp")
+	}
+
+	ops := UnknownOperators()
+	if len(ops) != 1 {
+		t.Fatalf("Expected 1 unknown operator, but got %d", len(ops))
+	}
+	if ops[0].Count != 16 {
+		t.Errorf("Expected 16 occurrences, but got %d", ops[0].Count)
+	}
+	if !reflect.DeepEqual(ops[0].SampleLines, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("Expected sample lines [1 2 3 4 5], but got %v", ops[0].SampleLines)
+	}
+}
+
+func TestPrintUnknownOperatorsSummary(t *testing.T) {
+	ResetUnknownOperators()
+	defer ResetUnknownOperators()
+	defer SetCurrentLineNumber(0)
+	defer SetStrictOperators(false)
+
+	var empty bytes.Buffer
+	PrintUnknownOperatorsSummary(&empty)
+	if empty.Len() != 0 {
+		t.Errorf("Expected no summary without unknown operators, but got: %s", empty.String())
+	}
+
+	SetCurrentLineNumber(3)
+	recordUnknownOperator("$rare")
+	SetCurrentLineNumber(7)
+	recordUnknownOperator("$common")
+	recordUnknownOperator("$common")
+
+	var out bytes.Buffer
+	PrintUnknownOperatorsSummary(&out)
+	summary := out.String()
+	for _, want := range []string{"found 2 unknown operator(s)", "--strictOperators", "  $common: 2 occurrence(s) (lines 7)\n", "  $rare: 1 occurrence(s) (lines 3)\n"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Expected summary to contain %q, but got:\n%s", want, summary)
+		}
+	}
+	if strings.Index(summary, "$common") > strings.Index(summary, "$rare") {
+		t.Errorf("Expected the most frequent operator first, but got:\n%s", summary)
+	}
+
+	SetStrictOperators(true)
+	out.Reset()
+	PrintUnknownOperatorsSummary(&out)
+	if !strings.Contains(out.String(), "their entire values were redacted") {
+		t.Errorf("Expected the strict mode summary, but got:\n%s", out.String())
+	}
+}